@@ -1,10 +1,12 @@
 package main
 
 import (
+	"context"
 	"html/template"
 	"multipass/internal/config"
 	"multipass/internal/handlers"
 	"multipass/internal/middleware"
+	"multipass/internal/models"
 	"multipass/internal/services"
 	"net/http"
 	"path/filepath"
@@ -22,6 +24,10 @@ func createTemplateRenderer(logger *services.Logger) multitemplate.Renderer {
 	// Define template functions
 	funcMap := template.FuncMap{
 		"upper": strings.ToUpper,
+		// csrf_field renders the hidden form input carrying the request's CSRF token
+		"csrf_field": func(token string) template.HTML {
+			return template.HTML(`<input type="hidden" name="` + middleware.CSRFFormField + `" value="` + template.HTMLEscapeString(token) + `">`)
+		},
 	}
 
 	// Get all template files
@@ -83,6 +89,17 @@ func main() {
 	// Create Gin router
 	r := gin.Default()
 
+	// Only honor X-Forwarded-For from the trusted proxy networks
+	if err := r.SetTrustedProxies(cfg.TrustedProxyCIDRs); err != nil {
+		logger.Fatal("Invalid trusted proxy networks: %v", err)
+	}
+
+	// Create proxy trust checker for identity headers
+	proxyTrust, err := services.NewProxyTrust(cfg)
+	if err != nil {
+		logger.Fatal("Failed to configure trusted proxies: %v", err)
+	}
+
 	// Add template functions
 	r.SetFuncMap(template.FuncMap{
 		"upper": func(s string) string {
@@ -90,6 +107,109 @@ func main() {
 		},
 	})
 
+	// Set up native OIDC login when multipass is not behind an Authentik outpost
+	var sessions *services.SessionManager
+	var oidcClient *services.OIDCClient
+	if cfg.AuthMode == config.AuthModeOIDC {
+		sessions, err = services.NewSessionManager(cfg)
+		if err != nil {
+			logger.Fatal("Failed to configure sessions: %v", err)
+		}
+
+		oidcClient, err = services.NewOIDCClient(context.Background(), cfg)
+		if err != nil {
+			logger.Fatal("Failed to configure OIDC: %v", err)
+		}
+	}
+
+	// Load token revocations so they apply to the next request
+	revocations, err := services.NewRevocationStore(cfg.DataPath("token_revocations.json"))
+	if err != nil {
+		logger.Fatal("Failed to load token revocations: %v", err)
+	}
+
+	// Create token service with the signing keyring
+	tokens, err := services.NewTokenService(cfg, revocations)
+	if err != nil {
+		logger.Fatal("Failed to configure tokens: %v", err)
+	}
+
+	// Load share link options and view counts
+	shareLinks, err := services.NewShareLinkStore(cfg.DataPath("share_links.json"))
+	if err != nil {
+		logger.Fatal("Failed to load share links: %v", err)
+	}
+
+	// Shorten share links through Shlink when SHLINK_URL and SHLINK_API_KEY are set
+	shlink := services.NewShlinkClient(cfg)
+	if shlink != nil {
+		logger.Info("Shortening share links with Shlink at %s", cfg.ShlinkURL)
+	}
+
+	// Load guest passes and their visits
+	guests, err := services.NewGuestPassStore(cfg.DataPath("guest_passes.json"))
+	if err != nil {
+		logger.Fatal("Failed to load guest passes: %v", err)
+	}
+
+	// Load the browsers members registered for device-bound live cards
+	devices, err := services.NewDeviceStore(cfg.DataPath("devices.json"))
+	if err != nil {
+		logger.Fatal("Failed to load devices: %v", err)
+	}
+	if cfg.DeviceBinding && !cfg.LiveCardEnabled {
+		logger.Error("DEVICE_BINDING_ENABLED has no effect without LIVE_CARD_ENABLED")
+	}
+
+	// Load the check-in log
+	checkins, err := services.NewCheckinStore(cfg.DataPath("checkins.jsonl"), cfg.CheckinLocations, cfg.CheckinClosingTime)
+	if err != nil {
+		logger.Fatal("Failed to load check-ins: %v", err)
+	}
+
+	// Load the RFID cards bound to members
+	cards, err := services.NewRFIDCardStore(cfg.DataPath("rfid_cards.json"))
+	if err != nil {
+		logger.Fatal("Failed to load RFID cards: %v", err)
+	}
+
+	// Door access decisions: per-door levels, cached member lookups and the audit log
+	doors, err := services.NewDoorPolicy(cfg)
+	if err != nil {
+		logger.Fatal("Invalid ACCESS_DOORS: %v", err)
+	}
+	memberCache := services.NewMemberCache(cfg.AccessCacheFresh, cfg.AccessCacheMaxStale, cfg.AccessLookupTimeout)
+	accessLog, err := services.NewAccessLogStore(cfg.DataPath("access_log.jsonl"))
+	if err != nil {
+		logger.Fatal("Failed to load access log: %v", err)
+	}
+
+	// Load or create the Ed25519 keys that sign membership assertions
+	assertions, err := services.NewAssertionSigner(cfg.DataPath("assertion_keys.json"), cfg.AssertionIssuer, cfg.AssertionTTL)
+	if err != nil {
+		logger.Fatal("Failed to load assertion keys: %v", err)
+	}
+
+	// Load API keys for machine clients
+	apiKeys, err := services.NewAPIKeyStore(cfg.DataPath("api_keys.json"))
+	if err != nil {
+		logger.Fatal("Failed to load API keys: %v", err)
+	}
+
+	// Catch typos in the capability grants of group_mapping.yaml
+	if unknown := models.UnknownCapabilities(cfg.GroupMappingConfig); len(unknown) > 0 {
+		logger.Error("Ignoring unknown capabilities in %s: %v", cfg.GroupMappingPath, unknown)
+	}
+
+	// Create rate limiters with separate budgets for public, protected and API routes
+	rateStats := services.NewRateLimitStats()
+	publicLimiter := services.NewRateLimiter(cfg.RateLimitPublic)
+	protectedLimiter := services.NewRateLimiter(cfg.RateLimit)
+	apiLimiter := services.NewRateLimiter(cfg.RateLimitAPI)
+
+	// Create authenticator for protected routes
+	authenticator := middleware.NewAuthenticator(cfg, sessions)
+
 	// Load HTML templates with proper inheritance
 	r.HTMLRender = createTemplateRenderer(logger)
 
@@ -100,6 +220,12 @@ func main() {
 	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
 
+	// Strip identity headers that did not come through a trusted proxy
+	r.Use(middleware.TrustedProxyMiddleware(proxyTrust, logger))
+
+	// Protect state-changing requests from cross-site forgery
+	r.Use(middleware.CSRFMiddleware(cfg, logger))
+
 	// CORS middleware for development
 	if cfg.IsDevelopment() {
 		r.Use(func(c *gin.Context) {
@@ -125,24 +251,62 @@ func main() {
 		})
 	})
 
+	// Throttling counters for Prometheus
+	r.GET("/metrics", handlers.MetricsHandler(rateStats))
+
+	// Public keys for verifying membership assertions offline
+	r.GET("/.well-known/jwks.json", handlers.JWKSHandler(assertions))
+
 	// Public routes (no authentication required)
 	public := r.Group("/")
 	{
-		public.GET("/login", handlers.LoginHandler)
+		if oidcClient != nil {
+			public.GET("/login", handlers.OIDCLoginHandler(oidcClient, sessions))
+			public.GET("/callback", handlers.OIDCCallbackHandler(oidcClient, sessions, logger))
+			public.GET("/logout", handlers.OIDCLogoutHandler(oidcClient, sessions))
+		} else {
+			public.GET("/login", handlers.LoginHandler(cfg))
+			public.GET("/logout", handlers.LogoutHandler(cfg))
+		}
 
 		// Public token-based routes
 		publicToken := public.Group("/public")
-		publicToken.Use(middleware.DebugAuthMiddleware()) // Add debug middleware
-		publicToken.Use(middleware.TokenAuthMiddleware()) // Add token auth middleware
+		publicToken.Use(middleware.RateLimitMiddleware(publicLimiter, rateStats, "public", "ip", middleware.ClientIPKey))
+		publicToken.Use(middleware.RateLimitMiddleware(publicLimiter, rateStats, "public", "token", middleware.TokenKey))
+		publicToken.Use(middleware.DebugAuthMiddleware())                   // Add debug middleware
+		publicToken.Use(middleware.TokenAuthMiddleware(tokens, shareLinks)) // Add token auth middleware
+		{
+			publicToken.GET("/card", handlers.PublicCardHandler(tokens, devices))
+			publicToken.GET("/card/live-code", handlers.LiveCodeHandler(cfg, tokens, devices))
+		}
+
+		// Card links with the token in the path, which keeps QR codes small and tokens out of query logs
+		cardLinks := public.Group("/c")
+		cardLinks.Use(middleware.RateLimitMiddleware(publicLimiter, rateStats, "public", "ip", middleware.ClientIPKey))
+		cardLinks.Use(middleware.RateLimitMiddleware(publicLimiter, rateStats, "public", "token", middleware.TokenKey))
+		cardLinks.Use(middleware.DebugAuthMiddleware())
+		cardLinks.Use(middleware.TokenAuthMiddleware(tokens, shareLinks))
 		{
-			publicToken.GET("/card", handlers.PublicCardHandler)
+			cardLinks.GET("/:token", handlers.PublicCardHandler(tokens, devices))
+			cardLinks.GET("/:token/live-code", handlers.LiveCodeHandler(cfg, tokens, devices))
+		}
+
+		// Guest card opened from a guest pass; only staff verifying it at the front desk record the visit
+		guestCard := public.Group("/guest")
+		guestCard.Use(middleware.RateLimitMiddleware(publicLimiter, rateStats, "public", "ip", middleware.ClientIPKey))
+		guestCard.Use(middleware.RateLimitMiddleware(publicLimiter, rateStats, "public", "token", middleware.TokenKey))
+		{
+			guestCard.GET("", handlers.GuestCardHandler(cfg, tokens, guests, logger))
+			guestCard.GET("/:token", handlers.GuestCardHandler(cfg, tokens, guests, logger))
 		}
 	}
 
 	// Protected routes (require authentication)
 	protected := r.Group("/")
+	protected.Use(middleware.RateLimitMiddleware(protectedLimiter, rateStats, "protected", "ip", middleware.ClientIPKey))
 	protected.Use(middleware.DebugAuthMiddleware()) // Add debug middleware before auth
-	protected.Use(middleware.AuthMiddleware())
+	protected.Use(middleware.AuthMiddleware(authenticator))
+	protected.Use(middleware.RateLimitMiddleware(protectedLimiter, rateStats, "protected", "user", middleware.UserKey))
 	{
 		// Root route redirects to card
 		protected.GET("/", func(c *gin.Context) {
@@ -150,25 +314,86 @@ func main() {
 		})
 
 		// Card routes
-		protected.GET("/card", handlers.CardHandler)
+		protected.GET("/card", middleware.RequireCapability(models.CapCardView), handlers.CardHandler(tokens))
 
 		// Profile and API routes
 		protected.GET("/profile", handlers.ProfileHandler)
 
 		// Token generation route
-		protected.GET("/generate-token", middleware.GenerateTokenHandler)
-		protected.GET("/share", handlers.GenerateTokenLinkHandler)
+		protected.POST("/generate-token", middleware.RequireCapability(models.CapCardShare), middleware.GenerateTokenHandler(tokens, shareLinks, shlink, logger))
+		protected.GET("/share", middleware.RequireCapability(models.CapCardShare), handlers.SharePageHandler(shareLinks))
+		protected.POST("/share", middleware.RequireCapability(models.CapCardShare), handlers.GenerateTokenLinkHandler(tokens, shareLinks, shlink, logger))
+		protected.POST("/share/links/:id/revoke", middleware.RequireCapability(models.CapCardShare), handlers.RevokeShareLinkFormHandler(shareLinks, shlink, revocations, logger))
+
+		// Guest passes within the member's monthly quota
+		protected.GET("/guests", middleware.RequireCapability(models.CapGuestsIssue), handlers.GuestPassesPageHandler(cfg, guests))
+		protected.POST("/guests", middleware.RequireCapability(models.CapGuestsIssue), handlers.IssueGuestPassHandler(cfg, tokens, guests, logger))
+
+		// Front desk scanner for staff
+		protected.GET("/verify", middleware.RequireCapability(models.CapMembersSearch), handlers.VerifyPageHandler(cfg, checkins))
+		protected.POST("/verify", middleware.RequireCapability(models.CapMembersSearch), handlers.ScanHandler(cfg, tokens, shareLinks, guests, checkins, logger))
+
+		// Browsers registered to show the member's live card
+		protected.GET("/devices", middleware.RequireCapability(models.CapCardView), handlers.DevicesPageHandler(cfg, devices))
+		protected.POST("/devices", middleware.RequireCapability(models.CapCardView), handlers.RegisterDeviceHandler(devices, logger))
+		protected.POST("/devices/:id/remove", middleware.RequireCapability(models.CapCardView), handlers.RemoveDeviceFormHandler(cfg, devices, logger))
+
+		// "Invalidate all my links" after a lost phone or a leaked link
+		protected.POST("/share/invalidate", middleware.RequireCapability(models.CapCardShare), handlers.InvalidateMyTokensFormHandler(cfg, revocations, shareLinks, shlink, logger))
+
+		// Staff management of API keys
+		admin := protected.Group("/admin")
+		admin.Use(middleware.RequireCapability(models.CapAPIKeysManage))
+		{
+			admin.GET("/api-keys", handlers.APIKeysPageHandler(cfg, apiKeys))
+			admin.POST("/api-keys", handlers.APIKeysFormHandler(cfg, apiKeys, logger))
+			admin.POST("/api-keys/:id/revoke", handlers.APIKeysRevokeFormHandler(apiKeys, logger))
+		}
+
+		// Staff enrollment of RFID cards with a USB reader
+		protected.GET("/admin/cards", middleware.RequireCapability(models.CapRFIDManage), handlers.CardsPageHandler(cfg, cards))
+		protected.POST("/admin/cards", middleware.RequireCapability(models.CapRFIDManage), handlers.BindCardFormHandler(cfg, cards, logger))
+		protected.POST("/admin/cards/:uid/unbind", middleware.RequireCapability(models.CapRFIDManage), handlers.UnbindCardFormHandler(cfg, cards, logger))
 	}
 
 	// API routes
 	api := r.Group("/api/v1")
-	api.Use(middleware.DebugAuthMiddleware()) // Add debug middleware before auth
-	api.Use(middleware.AuthMiddleware())
+	api.Use(middleware.RateLimitMiddleware(apiLimiter, rateStats, "api", "ip", middleware.ClientIPKey))
+	api.Use(middleware.APIKeyMiddleware(apiKeys, logger)) // Machine clients authenticate with bearer keys
+	api.Use(middleware.DebugAuthMiddleware())             // Add debug middleware before auth
+	api.Use(middleware.AuthMiddleware(authenticator))
+	api.Use(middleware.RateLimitMiddleware(apiLimiter, rateStats, "api", "user", middleware.UserKey))
 	{
-		api.GET("/user", handlers.ProfileHandler)
+		api.GET("/user", middleware.RequireUser(), handlers.ProfileHandler)
+		api.GET("/me/permissions", middleware.RequireUser(), handlers.PermissionsHandler)
+		api.POST("/me/tokens/invalidate", middleware.RequireUser(), handlers.InvalidateMyTokensHandler(cfg, revocations, shareLinks, shlink, logger))
+		api.GET("/me/devices", middleware.RequireUser(), handlers.ListMyDevicesHandler(devices))
+		api.DELETE("/me/devices/:id", middleware.RequireUser(), handlers.RemoveMyDeviceHandler(devices, logger))
+		api.GET("/me/checkins", middleware.RequireUser(), handlers.MyCheckinsHandler(checkins))
+		api.GET("/me/assertion", middleware.RequireUser(), middleware.RequireCapability(models.CapCardView), handlers.MyAssertionHandler(assertions, logger))
+		api.POST("/assertions/rotate", middleware.RequireUser(), middleware.RequireCapability(models.CapAdminConfig), handlers.RotateAssertionKeyHandler(assertions, logger))
+		api.GET("/members/lookup", middleware.RequireScope(models.ScopeMembersRead, models.CapMembersSearch), handlers.MemberLookupHandler(cfg))
+		api.POST("/tokens/verify", middleware.RequireScope(models.ScopeVerifyRead, models.CapMembersSearch), handlers.VerifyTokenHandler(cfg, tokens, shareLinks, guests, logger))
+		api.GET("/guests", middleware.RequireScope(models.ScopeMembersRead, models.CapMembersSearch), handlers.ListGuestPassesHandler(guests))
+		api.POST("/checkins", middleware.RequireScope(models.ScopeCheckinWrite, models.CapMembersSearch), handlers.RecordCheckinHandler(cfg, tokens, shareLinks, checkins, logger))
+		api.GET("/checkins/present", middleware.RequireScope(models.ScopeMembersRead, models.CapMembersSearch), handlers.PresentMembersHandler(checkins))
+		api.GET("/checkins/history", middleware.RequireScope(models.ScopeMembersRead, models.CapMembersSearch), handlers.CheckinHistoryHandler(checkins))
+		api.GET("/checkins/daily", middleware.RequireScope(models.ScopeMembersRead, models.CapMembersSearch), handlers.DailyCheckinsHandler(checkins))
+		api.POST("/access/decide", middleware.RequireScope(models.ScopeAccessDecide, models.CapMembersSearch), handlers.DecideAccessHandler(cfg, tokens, cards, doors, memberCache, accessLog, logger))
+		api.GET("/cards/:uid/member", middleware.RequireScope(models.ScopeVerifyRead, models.CapMembersSearch), handlers.CardMemberHandler(cfg, cards, memberCache))
+		api.GET("/access/log", middleware.RequireScope(models.ScopeMembersRead, models.CapMembersSearch), handlers.AccessLogHandler(accessLog))
+		api.GET("/me/certifications", middleware.RequireUser(), handlers.MyCertificationsHandler(cfg))
+		api.GET("/members/certifications", middleware.RequireScope(models.ScopeMembersRead, models.CapMembersSearch), handlers.MemberCertificationsHandler(cfg, memberCache))
+		api.POST("/certifications/check", middleware.RequireScope(models.ScopeVerifyRead, models.CapMembersSearch), handlers.CheckCertificationHandler(cfg, tokens, cards, memberCache, logger))
 		api.GET("/health", func(c *gin.Context) {
 			user, exists := c.Get("user")
-			if exists {
+			if value, isKey := c.Get("api_key"); isKey {
+				key := value.(*models.APIKey)
+				c.JSON(http.StatusOK, gin.H{
+					"status":  "authenticated",
+					"api_key": gin.H{"id": key.ID, "name": key.Name, "scopes": key.Scopes},
+				})
+			} else if exists {
 				c.JSON(http.StatusOK, gin.H{
 					"status": "authenticated",
 					"user":   user,
@@ -179,13 +404,48 @@ func main() {
 				})
 			}
 		})
+
+		// API key management for staff
+		keys := api.Group("/keys")
+		keys.Use(middleware.RequireUser(), middleware.RequireCapability(models.CapAPIKeysManage))
+		{
+			keys.GET("", handlers.ListAPIKeysHandler(apiKeys))
+			keys.POST("", handlers.CreateAPIKeyHandler(apiKeys, logger))
+			keys.DELETE("/:id", handlers.RevokeAPIKeyHandler(apiKeys, logger))
+		}
+
+		// Token revocation for staff
+		revoked := api.Group("/tokens")
+		revoked.Use(middleware.RequireUser(), middleware.RequireCapability(models.CapTokensRevoke))
+		{
+			revoked.GET("/revoked", handlers.ListRevokedTokensHandler(revocations))
+			revoked.POST("/revoke", handlers.RevokeTokenHandler(cfg, tokens, revocations, shareLinks, shlink, logger))
+		}
+
+		// Device resets for staff, e.g. after a member loses their phone
+		memberDevices := api.Group("/devices")
+		memberDevices.Use(middleware.RequireUser(), middleware.RequireCapability(models.CapDevicesReset))
+		{
+			memberDevices.GET("", handlers.ListMemberDevicesHandler(devices))
+			memberDevices.POST("/reset", handlers.ResetDevicesHandler(devices, logger))
+		}
+
+		// RFID card bindings for staff
+		rfidCards := api.Group("/cards")
+		rfidCards.Use(middleware.RequireUser(), middleware.RequireCapability(models.CapRFIDManage))
+		{
+			rfidCards.GET("", handlers.ListCardsHandler(cards))
+			rfidCards.POST("", handlers.BindCardHandler(cfg, cards, logger))
+			rfidCards.DELETE("/:uid", handlers.UnbindCardHandler(cfg, cards, logger))
+		}
 	}
 
 	// 404 handler
 	r.NoRoute(func(c *gin.Context) {
-		c.HTML(http.StatusNotFound, "login.html", gin.H{
+		handlers.RenderHTML(c, http.StatusNotFound, "login.html", gin.H{
 			"title":           "Page Not Found - " + cfg.MakerspaceName,
 			"makerspace_name": cfg.MakerspaceName,
+			"logo_url":        cfg.LogoURL,
 			"error":           "The page you're looking for doesn't exist.",
 		})
 	})
@@ -4,15 +4,101 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
+// Supported authentication modes
+const (
+	AuthModeProxy = "proxy" // Identity from trusted reverse proxy headers
+	AuthModeOIDC  = "oidc"  // Multipass acts as an OIDC relying party itself
+	AuthModeJWT   = "jwt"   // Identity from the signed X-Authentik-Jwt header
+)
+
+// HeaderProfile names the identity headers set by a forward-auth proxy
+type HeaderProfile struct {
+	Name            string
+	EmailHeader     string
+	NameHeader      string
+	UsernameHeader  string
+	UIDHeader       string
+	GroupsHeader    string
+	GroupsDelimiter string // Falls back to comma when the delimiter is absent
+}
+
+// Headers returns all header names used by the profile
+func (p HeaderProfile) Headers() []string {
+	var headers []string
+	for _, header := range []string{p.EmailHeader, p.NameHeader, p.UsernameHeader, p.UIDHeader, p.GroupsHeader} {
+		if header != "" {
+			headers = append(headers, header)
+		}
+	}
+	return headers
+}
+
+// HeaderProfiles are the built-in forward-auth header profiles, selected with HEADER_PROFILE
+var HeaderProfiles = map[string]HeaderProfile{
+	"authentik": {
+		Name:            "authentik",
+		EmailHeader:     "X-Authentik-Email",
+		NameHeader:      "X-Authentik-Name",
+		UsernameHeader:  "X-Authentik-Username",
+		UIDHeader:       "X-Authentik-Uid",
+		GroupsHeader:    "X-Authentik-Groups",
+		GroupsDelimiter: "|",
+	},
+	"oauth2-proxy": {
+		Name:            "oauth2-proxy",
+		EmailHeader:     "X-Forwarded-Email",
+		UsernameHeader:  "X-Forwarded-Preferred-Username",
+		UIDHeader:       "X-Forwarded-User",
+		GroupsHeader:    "X-Forwarded-Groups",
+		GroupsDelimiter: ",",
+	},
+	"authelia": {
+		Name:            "authelia",
+		EmailHeader:     "Remote-Email",
+		NameHeader:      "Remote-Name",
+		UsernameHeader:  "Remote-User",
+		UIDHeader:       "Remote-User",
+		GroupsHeader:    "Remote-Groups",
+		GroupsDelimiter: ",",
+	},
+	"pomerium": {
+		Name:            "pomerium",
+		EmailHeader:     "X-Pomerium-Claim-Email",
+		NameHeader:      "X-Pomerium-Claim-Name",
+		UsernameHeader:  "X-Pomerium-Claim-Preferred-Username",
+		UIDHeader:       "X-Pomerium-Claim-Sub",
+		GroupsHeader:    "X-Pomerium-Claim-Groups",
+		GroupsDelimiter: ",",
+	},
+}
+
 // GroupMappingConfig defines the mapping between Authentik groups and access levels
 type GroupMappingConfig struct {
 	Mappings     map[string]string `yaml:"mappings"`      // Maps Authentik group names to access levels
 	DefaultLevel string            `yaml:"default_level"` // Default access level if no matching groups found
+	Capabilities CapabilityConfig  `yaml:"capabilities"`  // Named permissions granted to levels and groups
+	GuestPasses  GuestPassConfig   `yaml:"guest_passes"`  // Monthly guest pass quotas
+}
+
+// CapabilityConfig grants capabilities such as "members.search" to access levels and Authentik groups
+// Levels not listed fall back to the built-in defaults; group grants are added on top of the level's
+type CapabilityConfig struct {
+	Levels map[string][]string `yaml:"levels"` // Access level name (e.g. "Staff") to capabilities
+	Groups map[string][]string `yaml:"groups"` // Authentik group name to extra capabilities
+}
+
+// GuestPassConfig limits how many guest passes members may issue per calendar month
+// Levels not listed fall back to the built-in quotas
+type GuestPassConfig struct {
+	MonthlyQuota map[string]int `yaml:"monthly_quota"` // Access level name (e.g. "FullMember") to passes per month
 }
 
 // Config holds application configuration
@@ -21,23 +107,98 @@ type Config struct {
 	Port        string
 	BindAddress string
 	Environment string
-	DebugMode   bool   // Enable debug logging
+	DebugMode   bool // Enable debug logging
+
+	// Authentication mode (see AuthMode* constants)
+	AuthMode string
+
+	// Forward-auth sign-in and sign-out endpoints used when AUTH_MODE is not oidc
+	SignInURL  string // Browsers without an identity are sent here with ?rd=<return URL>
+	SignOutURL string // /logout redirects here after clearing local cookies
 
 	// Authentik integration
 	AuthentikURL        string
 	AuthentikAPIToken   string
 	TrustedProxyHeaders bool
+	HeaderProfile       HeaderProfile // Identity headers of the forward-auth proxy
 	GroupMappingPath    string
 	GroupMappingConfig  *GroupMappingConfig
 
+	// Trusted proxy settings
+	TrustedProxyCIDRs        []string // Networks allowed to send identity headers
+	TrustedProxySecret       string   // Optional shared secret the proxy must present
+	TrustedProxySecretHeader string   // Header carrying the shared secret
+
+	// OIDC relying party settings (AUTH_MODE=oidc)
+	OIDCIssuerURL    string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string // Must point at the /callback route
+	OIDCScopes       []string
+	OIDCGroupsClaim  string // Claim holding the user's groups
+
+	// Authentik outpost JWT settings (AUTH_MODE=jwt)
+	AuthentikJWTIssuer   string
+	AuthentikJWTJWKSURL  string
+	AuthentikJWTAudience string
+
+	// Cloudflare Access settings (accepted alongside any auth mode when set)
+	CloudflareTeamDomain string // e.g. sequoia.cloudflareaccess.com
+	CloudflareAudience   string // Application audience (AUD) tag
+
+	// Session cookie settings
+	SessionSecret string        // Key material for encrypting session cookies
+	SessionMaxAge time.Duration // Lifetime of a login session
+
 	// Application settings
 	MakerspaceName string
 	LogoURL        string
+	DataDir        string // Directory for persistent state such as API keys
 
 	// Security settings
-	CSRFEnabled bool
-	RateLimit   int
-	TokenSecret string // Secret key for HMAC token generation and verification
+	CSRFEnabled     bool
+	RateLimit       int    // Requests per minute for signed-in pages; 0 disables
+	RateLimitPublic int    // Requests per minute for public token routes; 0 disables
+	RateLimitAPI    int    // Requests per minute for /api/v1; 0 disables
+	TokenSecret     string // Secret key for HMAC token generation and verification
+
+	// Token keyring for zero-downtime secret rotation
+	TokenKeys      map[string]string // Key ID to secret; TOKEN_SECRET is always present as "default" unless overridden
+	TokenActiveKID string            // Key that signs new tokens; all others only verify
+	TokenV1Until   time.Time         // Legacy v1 tokens are rejected after this time; zero accepts them until they expire
+
+	// Rotating QR codes on the member's own card
+	LiveCardEnabled  bool          // Show a live code instead of the 30-day card link
+	LiveCodeInterval time.Duration // How often the card fetches a new code
+	LiveCodeSkew     time.Duration // Extra time a code stays valid, for slow scanners and clock drift
+	DeviceBinding    bool          // Live codes are only shown on browsers the member registered
+
+	// Ed25519-signed membership assertions for offline verifiers
+	AssertionIssuer string        // "iss" claim verifiers expect
+	AssertionTTL    time.Duration // How long an assertion may be trusted
+
+	// Shlink short URLs for share links; disabled unless both URL and API key are set
+	ShlinkURL     string        // Base URL of the Shlink REST API, e.g. http://shlink:8080
+	ShlinkAPIKey  string        // Sent as X-Api-Key
+	ShlinkDomain  string        // Short domain to use; empty uses Shlink's default domain
+	ShlinkTimeout time.Duration // After this the long URL is used instead
+
+	// Check-ins recorded by the front desk scanner and check-in API keys
+	CheckinLocations   []string      // Named places scans are recorded at; the first is the default
+	CheckinClosingTime time.Duration // Time after midnight when members still checked in are checked out
+
+	// Door controllers asking POST /api/v1/access/decide whether to unlock
+	AccessDoors          map[string]DoorConfig // Doors by ID, from ACCESS_DOORS
+	AccessUnlockDuration time.Duration         // How long a door stays unlocked, unless the door sets its own
+	AccessCacheFresh     time.Duration         // Member lookups younger than this are used without asking Authentik
+	AccessCacheMaxStale  time.Duration         // Older lookups are used while Authentik is asked in the background, up to this age
+	AccessLookupTimeout  time.Duration         // How long a decision waits for Authentik when nothing usable is cached
+}
+
+// DoorConfig is a door that access decisions can be asked for
+type DoorConfig struct {
+	MinLevel string        // Lowest user level let in, by group_mapping.yaml name such as "FullMember"
+	Unlock   time.Duration // Zero uses AccessUnlockDuration
 }
 
 // Load loads configuration from environment variables
@@ -48,17 +209,68 @@ func Load() *Config {
 		Environment: getEnv("ENVIRONMENT", "development"),
 		DebugMode:   getBoolEnv("DEBUG_MODE", false),
 
+		AuthMode: getEnv("AUTH_MODE", AuthModeProxy),
+
+		SignInURL:  getEnv("AUTH_SIGNIN_URL", "/outpost.goauthentik.io/start"),
+		SignOutURL: getEnv("AUTH_SIGNOUT_URL", "/outpost.goauthentik.io/sign_out"),
+
 		AuthentikURL:        getEnv("AUTHENTIK_URL", "https://login.sequoia.garden"),
 		AuthentikAPIToken:   getEnv("AUTHENTIK_API_TOKEN", ""),
 		TrustedProxyHeaders: getBoolEnv("TRUSTED_PROXY_HEADERS", true),
 		GroupMappingPath:    getEnv("GROUP_MAPPING_CONFIG", "./config/group_mapping.yaml"),
 
+		TrustedProxyCIDRs:        getListEnv("TRUSTED_PROXY_CIDRS", []string{"127.0.0.1/32", "::1/128"}),
+		TrustedProxySecret:       getEnv("TRUSTED_PROXY_SECRET", ""),
+		TrustedProxySecretHeader: getEnv("TRUSTED_PROXY_SECRET_HEADER", "X-Multipass-Proxy-Secret"),
+
+		OIDCIssuerURL:    getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:  getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:       getListEnv("OIDC_SCOPES", []string{"openid", "profile", "email"}),
+		OIDCGroupsClaim:  getEnv("OIDC_GROUPS_CLAIM", "groups"),
+
+		AuthentikJWTIssuer:   getEnv("AUTHENTIK_JWT_ISSUER", ""),
+		AuthentikJWTJWKSURL:  getEnv("AUTHENTIK_JWT_JWKS_URL", ""),
+		AuthentikJWTAudience: getEnv("AUTHENTIK_JWT_AUDIENCE", ""),
+
+		CloudflareTeamDomain: getEnv("CF_ACCESS_TEAM_DOMAIN", ""),
+		CloudflareAudience:   getEnv("CF_ACCESS_AUD", ""),
+
+		SessionSecret: getEnv("SESSION_SECRET", ""),
+		SessionMaxAge: getDurationEnv("SESSION_MAX_AGE", 12*time.Hour),
+
 		MakerspaceName: getEnv("MAKERSPACE_NAME", "Sequoia Fabrica"),
 		LogoURL:        getEnv("MAKERSPACE_LOGO_URL", "/static/images/logo.png"),
+		DataDir:        getEnv("DATA_DIR", "./data"),
+
+		CSRFEnabled:     getBoolEnv("CSRF_ENABLED", true),
+		RateLimit:       getIntEnv("RATE_LIMIT", 100),
+		RateLimitPublic: getIntEnv("RATE_LIMIT_PUBLIC", 30),
+		RateLimitAPI:    getIntEnv("RATE_LIMIT_API", 300),
+		TokenSecret:     getEnv("TOKEN_SECRET", ""),
+
+		TokenActiveKID: getEnv("TOKEN_ACTIVE_KID", "default"),
 
-		CSRFEnabled: getBoolEnv("CSRF_ENABLED", true),
-		RateLimit:   getIntEnv("RATE_LIMIT", 100),
-		TokenSecret: getEnv("TOKEN_SECRET", ""),
+		LiveCardEnabled:  getBoolEnv("LIVE_CARD_ENABLED", false),
+		LiveCodeInterval: getDurationEnv("LIVE_CODE_INTERVAL", 30*time.Second),
+		LiveCodeSkew:     getDurationEnv("LIVE_CODE_SKEW", 30*time.Second),
+		DeviceBinding:    getBoolEnv("DEVICE_BINDING_ENABLED", false),
+
+		AssertionIssuer: getEnv("ASSERTION_ISSUER", "multipass"),
+		AssertionTTL:    getDurationEnv("ASSERTION_TTL", 24*time.Hour),
+
+		ShlinkURL:     strings.TrimSuffix(getEnv("SHLINK_URL", ""), "/"),
+		ShlinkAPIKey:  getEnv("SHLINK_API_KEY", ""),
+		ShlinkDomain:  getEnv("SHLINK_DOMAIN", ""),
+		ShlinkTimeout: getDurationEnv("SHLINK_TIMEOUT", 3*time.Second),
+
+		CheckinLocations: getListEnv("CHECKIN_LOCATIONS", []string{"front-desk"}),
+
+		AccessUnlockDuration: getDurationEnv("ACCESS_UNLOCK_DURATION", 5*time.Second),
+		AccessCacheFresh:     getDurationEnv("ACCESS_CACHE_FRESH", time.Minute),
+		AccessCacheMaxStale:  getDurationEnv("ACCESS_CACHE_MAX_STALE", 15*time.Minute),
+		AccessLookupTimeout:  getDurationEnv("ACCESS_LOOKUP_TIMEOUT", 2*time.Second),
 	}
 
 	// Check if Authentik API token is specified
@@ -71,6 +283,96 @@ func Load() *Config {
 		log.Fatalf("Error: TOKEN_SECRET environment variable not specified")
 	}
 
+	// Build the token keyring from TOKEN_SECRET and TOKEN_KEYRING ("kid:secret,kid:secret")
+	cfg.TokenKeys = map[string]string{"default": cfg.TokenSecret}
+	for _, entry := range getListEnv("TOKEN_KEYRING", nil) {
+		kid, secret, found := strings.Cut(entry, ":")
+		if !found || kid == "" || secret == "" {
+			log.Fatalf("Error: TOKEN_KEYRING entries must look like kid:secret")
+		}
+		cfg.TokenKeys[kid] = secret
+	}
+	if _, ok := cfg.TokenKeys[cfg.TokenActiveKID]; !ok {
+		log.Fatalf("Error: TOKEN_ACTIVE_KID %q is not in TOKEN_KEYRING", cfg.TokenActiveKID)
+	}
+
+	// Parse the end of the legacy token migration window
+	if until := getEnv("TOKEN_V1_UNTIL", ""); until != "" {
+		parsed, err := time.Parse(time.RFC3339, until)
+		if err != nil {
+			parsed, err = time.Parse("2006-01-02", until)
+		}
+		if err != nil {
+			log.Fatalf("Error: TOKEN_V1_UNTIL must be an RFC 3339 time or a date: %v", err)
+		}
+		cfg.TokenV1Until = parsed
+	}
+
+	// Parse the closing time at which open check-ins are closed
+	closing, err := time.Parse("15:04", getEnv("CHECKIN_CLOSING_TIME", "23:00"))
+	if err != nil {
+		log.Fatalf("Error: CHECKIN_CLOSING_TIME must be a time of day such as 23:00: %v", err)
+	}
+	cfg.CheckinClosingTime = time.Duration(closing.Hour())*time.Hour + time.Duration(closing.Minute())*time.Minute
+
+	// Parse the doors ("door:Level" or "door:Level:unlock", comma-separated)
+	cfg.AccessDoors = map[string]DoorConfig{}
+	for _, entry := range getListEnv("ACCESS_DOORS", nil) {
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+			log.Fatalf("Error: ACCESS_DOORS entries must look like door:Level or door:Level:unlock")
+		}
+		door := DoorConfig{MinLevel: parts[1]}
+		if len(parts) == 3 {
+			unlock, err := time.ParseDuration(parts[2])
+			if err != nil || unlock <= 0 {
+				log.Fatalf("Error: ACCESS_DOORS unlock time of %s must be a positive duration such as 10s", parts[0])
+			}
+			door.Unlock = unlock
+		}
+		cfg.AccessDoors[parts[0]] = door
+	}
+	if cfg.AccessCacheMaxStale < cfg.AccessCacheFresh {
+		log.Fatalf("Error: ACCESS_CACHE_MAX_STALE must not be shorter than ACCESS_CACHE_FRESH")
+	}
+
+	// Resolve the forward-auth header profile
+	profileName := getEnv("HEADER_PROFILE", "authentik")
+	profile, ok := HeaderProfiles[profileName]
+	if !ok {
+		log.Fatalf("Error: unknown HEADER_PROFILE %q", profileName)
+	}
+	cfg.HeaderProfile = profile
+
+	// Sessions fall back to the token secret if no dedicated secret is set
+	if cfg.SessionSecret == "" {
+		cfg.SessionSecret = cfg.TokenSecret
+	}
+
+	// Check the settings required by the selected authentication mode
+	switch cfg.AuthMode {
+	case AuthModeProxy:
+	case AuthModeOIDC:
+		if cfg.OIDCIssuerURL == "" || cfg.OIDCClientID == "" || cfg.OIDCRedirectURL == "" {
+			log.Fatalf("Error: AUTH_MODE=oidc requires OIDC_ISSUER_URL, OIDC_CLIENT_ID and OIDC_REDIRECT_URL")
+		}
+	case AuthModeJWT:
+		if cfg.AuthentikJWTIssuer == "" || cfg.AuthentikJWTAudience == "" {
+			log.Fatalf("Error: AUTH_MODE=jwt requires AUTHENTIK_JWT_ISSUER and AUTHENTIK_JWT_AUDIENCE")
+		}
+		// Authentik publishes provider keys next to the issuer
+		if cfg.AuthentikJWTJWKSURL == "" {
+			cfg.AuthentikJWTJWKSURL = strings.TrimSuffix(cfg.AuthentikJWTIssuer, "/") + "/jwks/"
+		}
+	default:
+		log.Fatalf("Error: unsupported AUTH_MODE %q", cfg.AuthMode)
+	}
+
+	// Cloudflare Access needs both the team domain and the audience tag
+	if (cfg.CloudflareTeamDomain == "") != (cfg.CloudflareAudience == "") {
+		log.Fatalf("Error: CF_ACCESS_TEAM_DOMAIN and CF_ACCESS_AUD must be set together")
+	}
+
 	// Check if group mapping path is specified
 	if cfg.GroupMappingPath == "" {
 		log.Fatalf("Error: GROUP_MAPPING_CONFIG environment variable not specified")
@@ -115,6 +417,37 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getDurationEnv gets a duration environment variable (e.g. "12h") with default fallback
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
+
+// getListEnv gets a comma-separated environment variable with default fallback
+func getListEnv(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// CloudflareAccessEnabled returns true if Cloudflare Access assertions should be accepted
+func (c *Config) CloudflareAccessEnabled() bool {
+	return c.CloudflareTeamDomain != "" && c.CloudflareAudience != ""
+}
+
 // IsDevelopment returns true if running in development mode
 func (c *Config) IsDevelopment() bool {
 	return c.Environment == "development"
@@ -125,6 +458,11 @@ func (c *Config) IsProduction() bool {
 	return c.Environment == "production"
 }
 
+// DataPath returns the path of a file inside the data directory
+func (c *Config) DataPath(name string) string {
+	return filepath.Join(c.DataDir, name)
+}
+
 // GetServerAddress returns the full server bind address
 func (c *Config) GetServerAddress() string {
 	return c.BindAddress + ":" + c.Port
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"errors"
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"multipass/internal/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// accessRequest is the body door controllers send to ask whether to unlock
+type accessRequest struct {
+	Credential string `json:"credential" binding:"required"` // Scanned card or live code (or its URL), or a card UID
+	Type       string `json:"type"`                          // "qr" (default) or "rfid"
+	Door       string `json:"door" binding:"required"`       // One of the doors in ACCESS_DOORS
+}
+
+// maxAccessLogDays limits how far back the audit log can be queried
+const maxAccessLogDays = 90
+
+// DecideAccessHandler answers a door controller with allow or deny, the reason and how long to unlock
+// Every decision is written to the audit log. Like the verification API, a denial is a 200 response.
+func DecideAccessHandler(cfg *config.Config, tokens *services.TokenService, cards *services.RFIDCardStore, doors *services.DoorPolicy, members *services.MemberCache, accessLog *services.AccessLogStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req accessRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "credential and door are required"})
+			return
+		}
+		if req.Type == "" {
+			req.Type = models.CredentialQR
+		}
+		if req.Type != models.CredentialQR && req.Type != models.CredentialRFID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "type must be \"qr\" or \"rfid\""})
+			return
+		}
+
+		decision := &models.AccessDecision{
+			Door:           req.Door,
+			CredentialType: req.Type,
+			DecidedFor:     requestActor(c),
+			At:             time.Now().UTC(),
+		}
+		lookup := decideAccess(cfg, tokens, cards, doors, members, req, decision)
+
+		// A failed audit write is logged but does not keep the door shut
+		if err := accessLog.Record(decision); err != nil {
+			logger.Error("Failed to write access decision for %s to the audit log: %v", req.Door, err)
+		}
+		logger.Info("Access to %s for %s via %s: %s (%s)", decision.Door, decision.Email, decision.DecidedFor, decisionName(decision.Allowed), decision.Reason)
+
+		result := gin.H{
+			"decision":       decisionName(decision.Allowed),
+			"allowed":        decision.Allowed,
+			"reason":         decision.Reason,
+			"door":           decision.Door,
+			"unlock_seconds": decision.UnlockSeconds,
+			"decision_id":    decision.ID,
+		}
+		if lookup != nil {
+			result["member"] = gin.H{
+				"display_name":      lookup.User.GetFullName(),
+				"access_level_name": lookup.User.AccessLevel.String(),
+			}
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// AccessLogHandler returns audit log entries, filtered by ?door=, ?email=, ?days= (default 7) and ?limit= (default 100)
+func AccessLogHandler(accessLog *services.AccessLogStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		days, ok := queryInt(c, "days", 7, maxAccessLogDays)
+		if !ok {
+			return
+		}
+		limit, ok := queryInt(c, "limit", 100, 1000)
+		if !ok {
+			return
+		}
+
+		decisions := accessLog.List(services.AccessLogFilter{
+			Door:  c.Query("door"),
+			Email: strings.TrimSpace(c.Query("email")),
+			Since: time.Now().AddDate(0, 0, -days),
+			Limit: limit,
+		})
+		c.JSON(http.StatusOK, gin.H{"count": len(decisions), "decisions": decisions})
+	}
+}
+
+// decideAccess fills in the decision for a request and returns the member's lookup if the credential belonged to one
+func decideAccess(cfg *config.Config, tokens *services.TokenService, cards *services.RFIDCardStore, doors *services.DoorPolicy, members *services.MemberCache, req accessRequest, decision *models.AccessDecision) *services.MemberLookup {
+	minLevel, unlock, ok := doors.Door(req.Door)
+	if !ok {
+		decision.Reason = models.AccessReasonUnknownDoor
+		return nil
+	}
+
+	lookup, credentialID, reason := credentialMember(cfg, tokens, cards, members, req.Type, req.Credential)
+	decision.CredentialID = credentialID
+	if lookup != nil {
+		decision.Email = lookup.User.Email
+		decision.MemberName = lookup.User.GetFullName()
+		decision.CacheAge = int(time.Since(lookup.FetchedAt).Seconds())
+	}
+	if reason != "" {
+		decision.Reason = reason
+		return lookup
+	}
+
+	decision.Allowed, decision.Reason = models.DecideAccess(lookup.User, lookup.Membership, minLevel, time.Now())
+	if decision.Allowed {
+		decision.UnlockSeconds = int(unlock.Seconds())
+	}
+	return lookup
+}
+
+// credentialMember finds the member behind a scanned code or a card UID through the member cache
+// reason is empty if the credential is good; the lookup is also returned for a revoked code, whose member is known
+func credentialMember(cfg *config.Config, tokens *services.TokenService, cards *services.RFIDCardStore, members *services.MemberCache, credentialType, credential string) (*services.MemberLookup, string, string) {
+	var tokenData *utils.TokenData
+	var credentialID string
+	var lookup *services.MemberLookup
+	var err error
+	if credentialType == models.CredentialRFID {
+		card := cards.Lookup(credential)
+		if card == nil {
+			return nil, strings.ToUpper(strings.TrimSpace(credential)), models.AccessReasonUnknownCredential
+		}
+		credentialID = card.UID
+		lookup, err = lookupCardMember(cfg, members, card)
+	} else {
+		// Only the member's own card or live code identifies them, not share links or guest passes
+		tokenData, err = tokens.Verify(tokenFromInput(credential), utils.PurposeCard, utils.PurposeLive)
+		if err != nil {
+			return nil, "", services.TokenFailureReason(err)
+		}
+		credentialID = tokenData.ID
+
+		lookup, err = members.Get(memberCacheKey(tokenData.UserID, tokenData.Email), func() (*models.UserProfile, *models.MembershipInfo, error) {
+			return fetchMember(cfg, tokenData.UserID, tokenData.Email)
+		})
+	}
+	if errors.Is(err, services.ErrLookupTimeout) {
+		return nil, credentialID, models.AccessReasonUnavailable
+	}
+	if err != nil {
+		return nil, credentialID, services.TokenReasonUserMissing
+	}
+
+	// The member may have invalidated their links; the epoch is as fresh as the cached profile
+	if tokenData != nil && tokens.CheckOwner(tokenData, lookup.User) != nil {
+		return lookup, credentialID, services.TokenReasonRevoked
+	}
+	return lookup, credentialID, ""
+}
+
+// fetchMember looks up a member and their membership in Authentik, by ID if it is known and otherwise by email
+func fetchMember(cfg *config.Config, userID, email string) (*models.UserProfile, *models.MembershipInfo, error) {
+	user, err := services.NewAuthentikClient(cfg).GetUserForToken(&utils.TokenData{UserID: userID, Email: email})
+	if err != nil {
+		return nil, nil, err
+	}
+	if user == nil {
+		return nil, nil, errors.New("user not found")
+	}
+
+	membership, err := services.NewMembershipService().GetMembershipInfo(user)
+	if err != nil {
+		return nil, nil, err
+	}
+	return user, membership, nil
+}
+
+// memberCacheKey identifies a member in the member cache
+func memberCacheKey(userID, email string) string {
+	return userID + "|" + strings.ToLower(email)
+}
+
+// decisionName is the decision as door controllers read it
+func decisionName(allowed bool) string {
+	if allowed {
+		return "allow"
+	}
+	return "deny"
+}
+
+// queryInt reads an integer query parameter between 1 and max, writing a 400 response if it is invalid
+func queryInt(c *gin.Context, name string, defaultValue, max int) (int, bool) {
+	value := c.Query(name)
+	if value == "" {
+		return defaultValue, true
+	}
+
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 1 || parsed > max {
+		c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be between 1 and " + strconv.Itoa(max)})
+		return 0, false
+	}
+	return parsed, true
+}
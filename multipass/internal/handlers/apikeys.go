@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"errors"
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// createAPIKeyRequest is the body accepted when minting an API key
+type createAPIKeyRequest struct {
+	Name      string   `json:"name" form:"name"`
+	Scopes    []string `json:"scopes" form:"scopes"`
+	ExpiresIn string   `json:"expires_in" form:"expires_in"` // Go duration such as "720h"; empty never expires
+}
+
+// ListAPIKeysHandler returns all API keys without their hashes
+func ListAPIKeysHandler(store *services.APIKeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"keys": publicAPIKeys(store.List())})
+	}
+}
+
+// CreateAPIKeyHandler mints a key and returns the plaintext once
+func CreateAPIKeyHandler(store *services.APIKeyStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req createAPIKeyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+
+		plaintext, key, err := createAPIKey(store, req, currentUserEmail(c))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		logger.Audit("API key %s (%s) created by %s with scopes %v", key.ID, key.Name, key.CreatedBy, key.Scopes)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"key":     plaintext,
+			"api_key": publicAPIKey(key),
+		})
+	}
+}
+
+// RevokeAPIKeyHandler revokes a key by ID
+func RevokeAPIKeyHandler(store *services.APIKeyStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		revokedBy := currentUserEmail(c)
+
+		if err := store.Revoke(id, revokedBy); err != nil {
+			if errors.Is(err, services.ErrAPIKeyNotFound) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+			return
+		}
+
+		logger.Audit("API key %s revoked by %s", id, revokedBy)
+		c.JSON(http.StatusOK, gin.H{"status": "revoked", "id": id})
+	}
+}
+
+// APIKeysPageHandler renders the staff page for managing API keys
+func APIKeysPageHandler(cfg *config.Config, store *services.APIKeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderAPIKeysPage(c, cfg, store, http.StatusOK, gin.H{})
+	}
+}
+
+// APIKeysFormHandler handles the create form on the staff page and shows the new key once
+func APIKeysFormHandler(cfg *config.Config, store *services.APIKeyStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req createAPIKeyRequest
+		if err := c.ShouldBind(&req); err != nil {
+			renderAPIKeysPage(c, cfg, store, http.StatusBadRequest, gin.H{"error": "Invalid form submission"})
+			return
+		}
+
+		plaintext, key, err := createAPIKey(store, req, currentUserEmail(c))
+		if err != nil {
+			renderAPIKeysPage(c, cfg, store, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		logger.Audit("API key %s (%s) created by %s with scopes %v", key.ID, key.Name, key.CreatedBy, key.Scopes)
+		renderAPIKeysPage(c, cfg, store, http.StatusCreated, gin.H{"new_key": plaintext, "new_key_name": key.Name})
+	}
+}
+
+// APIKeysRevokeFormHandler handles the revoke buttons on the staff page
+func APIKeysRevokeFormHandler(store *services.APIKeyStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.Param("id")
+		revokedBy := currentUserEmail(c)
+
+		if err := store.Revoke(id, revokedBy); err == nil {
+			logger.Audit("API key %s revoked by %s", id, revokedBy)
+		}
+
+		c.Redirect(http.StatusSeeOther, "/admin/api-keys")
+	}
+}
+
+// renderAPIKeysPage renders api_keys.html with the current keys merged into data
+func renderAPIKeysPage(c *gin.Context, cfg *config.Config, store *services.APIKeyStore, status int, data gin.H) {
+	user, _ := c.Get("user")
+
+	data["title"] = "API Keys - " + cfg.MakerspaceName
+	data["makerspace_name"] = cfg.MakerspaceName
+	data["user"] = user
+	data["keys"] = store.List()
+	data["scopes"] = models.ValidScopes
+	data["now"] = time.Now()
+
+	RenderHTML(c, status, "api_keys.html", data)
+}
+
+// createAPIKey validates the request and mints the key
+func createAPIKey(store *services.APIKeyStore, req createAPIKeyRequest, createdBy string) (string, *models.APIKey, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return "", nil, errors.New("name is required")
+	}
+	if len(req.Scopes) == 0 {
+		return "", nil, errors.New("at least one scope is required")
+	}
+
+	var validFor time.Duration
+	if req.ExpiresIn != "" {
+		d, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || d <= 0 {
+			return "", nil, errors.New("expires_in must be a positive duration such as 720h")
+		}
+		validFor = d
+	}
+
+	return store.Create(name, req.Scopes, validFor, createdBy)
+}
+
+// currentUserEmail returns the email of the signed-in user, if any
+func currentUserEmail(c *gin.Context) string {
+	if user, exists := c.Get("user"); exists {
+		return user.(*models.UserProfile).Email
+	}
+	return ""
+}
+
+// publicAPIKey strips the hash before a key is returned to clients
+func publicAPIKey(key *models.APIKey) gin.H {
+	return gin.H{
+		"id":           key.ID,
+		"name":         key.Name,
+		"scopes":       key.Scopes,
+		"created_by":   key.CreatedBy,
+		"created_at":   key.CreatedAt,
+		"expires_at":   key.ExpiresAt,
+		"last_used_at": key.LastUsedAt,
+		"revoked_at":   key.RevokedAt,
+		"revoked_by":   key.RevokedBy,
+	}
+}
+
+// publicAPIKeys strips the hashes from a list of keys
+func publicAPIKeys(keys []*models.APIKey) []gin.H {
+	result := make([]gin.H, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, publicAPIKey(key))
+	}
+	return result
+}
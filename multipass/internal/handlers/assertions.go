@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"multipass/internal/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler publishes the public keys membership assertions are signed with
+func JWKSHandler(signer *services.AssertionSigner) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Verifiers cache the set; a short max-age lets them pick up rotated keys quickly
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, signer.JWKS())
+	}
+}
+
+// MyAssertionHandler issues a signed membership assertion for the signed-in member, with a QR code carrying it
+func MyAssertionHandler(signer *services.AssertionSigner, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*models.UserProfile)
+
+		// Get membership info from the membership service
+		membershipInfo, err := services.NewMembershipService().GetMembershipInfo(user)
+		if err != nil {
+			logger.Error("Failed to retrieve membership info: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve membership info"})
+			return
+		}
+
+		raw, assertion, err := signer.Issue(user, membershipInfo)
+		if err != nil {
+			logger.Error("Failed to issue assertion for %s: %v", user.Email, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue assertion"})
+			return
+		}
+
+		// The QR code holds the assertion itself so door controllers can check it without calling multipass
+		qrCode, err := utils.GenerateQRCodeBase64(raw, 300)
+		if err != nil {
+			logger.Error("Failed to generate QR code: %v", err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"assertion":  raw,
+			"expires_at": assertion.ExpiresAt,
+			"qr_code":    qrCode,
+		})
+	}
+}
+
+// RotateAssertionKeyHandler generates a new signing key; older keys stay published
+func RotateAssertionKeyHandler(signer *services.AssertionSigner, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kid, err := signer.Rotate()
+		if err != nil {
+			logger.Error("Failed to rotate assertion key: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rotate assertion key"})
+			return
+		}
+
+		logger.Audit("Assertion signing key rotated to %s by %s", kid, currentUserEmail(c))
+		c.JSON(http.StatusOK, gin.H{"status": "rotated", "kid": kid})
+	}
+}
@@ -1,20 +1,46 @@
 package handlers
 
 import (
+	"multipass/internal/config"
 	"multipass/internal/models"
+	"multipass/internal/services"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/oauth2"
 )
 
 // LoginHandler handles SSO login requests
-func LoginHandler(c *gin.Context) {
-	// Since we're using reverse proxy authentication,
-	// this handler mainly serves the login page for users not authenticated
-	c.HTML(http.StatusOK, "login.html", gin.H{
-		"title":          "Sequoia Fabrica - Login",
-		"makerspace_name": "Sequoia Fabrica",
-	})
+func LoginHandler(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Since we're using reverse proxy authentication,
+		// this handler mainly serves the login page for users not authenticated
+		RenderHTML(c, http.StatusOK, "login.html", gin.H{
+			"title":           cfg.MakerspaceName + " - Login",
+			"makerspace_name": cfg.MakerspaceName,
+			"logo_url":        cfg.LogoURL,
+		})
+	}
+}
+
+// LogoutHandler clears local cookies and signs out at the forward-auth proxy
+func LogoutHandler(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Expire any local session cookies
+		for _, name := range []string{services.SessionCookieName, services.LoginStateCookieName} {
+			http.SetCookie(c.Writer, &http.Cookie{
+				Name:     name,
+				Value:    "",
+				Path:     "/",
+				MaxAge:   -1,
+				HttpOnly: true,
+				Secure:   !cfg.IsDevelopment(),
+			})
+		}
+
+		c.Redirect(http.StatusFound, cfg.SignOutURL)
+	}
 }
 
 // ProfileHandler displays user profile information
@@ -29,9 +55,9 @@ func ProfileHandler(c *gin.Context) {
 
 	// Create membership info
 	membership := &models.MembershipInfo{
-		MembershipType:    userProfile.AccessLevel.String(),
-		Status:           models.StatusActive,
-		UserLevel:        userProfile.AccessLevel,
+		MembershipType: userProfile.AccessLevel.String(),
+		Status:         models.StatusActive,
+		UserLevel:      userProfile.AccessLevel,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -39,3 +65,117 @@ func ProfileHandler(c *gin.Context) {
 		"membership": membership,
 	})
 }
+
+// PermissionsHandler returns the signed-in user's access level and capabilities
+func PermissionsHandler(c *gin.Context) {
+	user, exists := c.Get("user")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return
+	}
+
+	userProfile := user.(*models.UserProfile)
+
+	c.JSON(http.StatusOK, gin.H{
+		"email":        userProfile.Email,
+		"access_level": userProfile.AccessLevel.String(),
+		"groups":       userProfile.Groups,
+		"capabilities": userProfile.Capabilities,
+	})
+}
+
+// OIDCLoginHandler starts the authorization-code + PKCE flow by redirecting to the provider
+func OIDCLoginHandler(oidcClient *services.OIDCClient, sessions *services.SessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Generate one-time values binding the callback to this browser
+		state := oauth2.GenerateVerifier()
+		nonce := oauth2.GenerateVerifier()
+		verifier := oauth2.GenerateVerifier()
+
+		// Remember the state along with where to send the user afterwards
+		loginState := &services.LoginState{
+			State:    state,
+			Nonce:    nonce,
+			Verifier: verifier,
+			ReturnTo: safeReturnPath(c.Query("rd")),
+		}
+		if err := sessions.SaveLoginState(c.Writer, loginState); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start login"})
+			return
+		}
+
+		c.Redirect(http.StatusFound, oidcClient.AuthCodeURL(state, nonce, verifier))
+	}
+}
+
+// OIDCCallbackHandler completes the login, verifies the ID token and creates the session
+func OIDCCallbackHandler(oidcClient *services.OIDCClient, sessions *services.SessionManager, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// The provider reports errors such as a denied consent as query parameters
+		if errParam := c.Query("error"); errParam != "" {
+			logger.Error("OIDC provider returned error: %s (%s)", errParam, c.Query("error_description"))
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+			return
+		}
+
+		// Load and consume the state saved by OIDCLoginHandler
+		loginState, err := sessions.TakeLoginState(c.Writer, c.Request)
+		if err != nil {
+			logger.Error("OIDC callback without valid login state: %v", err)
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Login session expired, please try again"})
+			return
+		}
+
+		if c.Query("state") != loginState.State {
+			logger.Audit("OIDC callback state mismatch from %s", c.ClientIP())
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid login state"})
+			return
+		}
+
+		// Exchange the code and verify the returned ID token
+		identity, err := oidcClient.Exchange(c.Request.Context(), c.Query("code"), loginState.Verifier, loginState.Nonce)
+		if err != nil {
+			logger.Error("OIDC code exchange failed: %v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Login failed"})
+			return
+		}
+
+		// Store the identity in the encrypted session cookie
+		session := &services.Session{
+			Email:    identity.Email,
+			Name:     identity.Name,
+			Username: identity.Username,
+			UID:      identity.Subject,
+			Groups:   identity.Groups,
+		}
+		if err := sessions.Save(c.Writer, session); err != nil {
+			logger.Error("Failed to save session: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create session"})
+			return
+		}
+
+		c.Redirect(http.StatusFound, loginState.ReturnTo)
+	}
+}
+
+// OIDCLogoutHandler clears the local session and ends the session at the provider if supported
+func OIDCLogoutHandler(oidcClient *services.OIDCClient, sessions *services.SessionManager) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessions.Clear(c.Writer)
+
+		if endSessionURL := oidcClient.EndSessionURL(); endSessionURL != "" {
+			c.Redirect(http.StatusFound, endSessionURL)
+			return
+		}
+
+		c.Redirect(http.StatusFound, "/login")
+	}
+}
+
+// safeReturnPath only allows local paths as post-login redirect targets
+func safeReturnPath(path string) string {
+	if path == "" || !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") || strings.HasPrefix(path, "/\\") {
+		return "/card"
+	}
+	return path
+}
@@ -0,0 +1,219 @@
+package handlers
+
+import (
+	"errors"
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// bindCardRequest is the body and form accepted when binding a card to a member
+type bindCardRequest struct {
+	UID   string `json:"uid" form:"uid" binding:"required"` // As typed by the reader, in any format NormalizeCardUID accepts
+	Email string `json:"email" form:"email" binding:"required"`
+	Label string `json:"label" form:"label"`
+}
+
+// errCardMemberNotFound is returned when the member a card is bound to does not exist in Authentik
+var errCardMemberNotFound = errors.New("member not found")
+
+// CardsPageHandler shows the staff page for enrolling cards; ?uid= shows who a tapped card belongs to
+func CardsPageHandler(cfg *config.Config, cards *services.RFIDCardStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data := gin.H{}
+		if uid := c.Query("uid"); uid != "" {
+			data["lookup_uid"] = uid
+			data["lookup_card"] = cards.Lookup(uid)
+		}
+		renderCardsPage(c, cfg, cards, http.StatusOK, data)
+	}
+}
+
+// BindCardFormHandler handles the enrollment form on the staff page
+// The reader types the UID followed by Enter, which submits the form, so the email is kept for the next card
+func BindCardFormHandler(cfg *config.Config, cards *services.RFIDCardStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req bindCardRequest
+		if err := c.ShouldBind(&req); err != nil {
+			renderCardsPage(c, cfg, cards, http.StatusBadRequest, gin.H{"error": "Member email and card UID are required", "email": req.Email})
+			return
+		}
+
+		card, err := bindCard(cfg, cards, logger, req, currentUserEmail(c))
+		if err != nil {
+			status, message := bindCardError(cards, req.UID, err)
+			renderCardsPage(c, cfg, cards, status, gin.H{"error": message, "email": req.Email})
+			return
+		}
+
+		renderCardsPage(c, cfg, cards, http.StatusCreated, gin.H{"bound": card, "email": req.Email})
+	}
+}
+
+// UnbindCardFormHandler handles the unbind buttons on the staff page
+func UnbindCardFormHandler(cfg *config.Config, cards *services.RFIDCardStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := unbindCard(cfg, cards, logger, c.Param("uid"), currentUserEmail(c)); err != nil && !errors.Is(err, services.ErrCardNotFound) {
+			renderCardsPage(c, cfg, cards, http.StatusInternalServerError, gin.H{"error": "Failed to unbind card"})
+			return
+		}
+
+		c.Redirect(http.StatusSeeOther, "/admin/cards")
+	}
+}
+
+// ListCardsHandler returns the cards bound to ?email=, or every card
+func ListCardsHandler(cards *services.RFIDCardStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"cards": cards.List(strings.TrimSpace(c.Query("email")))})
+	}
+}
+
+// BindCardHandler binds a card to a member
+func BindCardHandler(cfg *config.Config, cards *services.RFIDCardStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req bindCardRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "uid and email are required"})
+			return
+		}
+
+		card, err := bindCard(cfg, cards, logger, req, currentUserEmail(c))
+		if err != nil {
+			status, message := bindCardError(cards, req.UID, err)
+			c.JSON(status, gin.H{"error": message})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"card": card})
+	}
+}
+
+// UnbindCardHandler removes a card from its member
+func UnbindCardHandler(cfg *config.Config, cards *services.RFIDCardStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		card, err := unbindCard(cfg, cards, logger, c.Param("uid"), currentUserEmail(c))
+		switch {
+		case errors.Is(err, services.ErrCardUID):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrCardNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Card not found"})
+		case err != nil:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unbind card"})
+		default:
+			c.JSON(http.StatusOK, gin.H{"status": "unbound", "card": card})
+		}
+	}
+}
+
+// CardMemberHandler returns the member a card is bound to, so readers can identify members without a phone
+// The member comes from the same cache as access decisions and may be up to ACCESS_CACHE_MAX_STALE old
+func CardMemberHandler(cfg *config.Config, cards *services.RFIDCardStore, members *services.MemberCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		card := cards.Lookup(c.Param("uid"))
+		if card == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Card is not bound to a member"})
+			return
+		}
+
+		lookup, err := lookupCardMember(cfg, members, card)
+		if errors.Is(err, services.ErrLookupTimeout) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Authentik did not answer in time"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"card":       card,
+			"user":       lookup.User,
+			"membership": lookup.Membership,
+		})
+	}
+}
+
+// lookupCardMember finds the member a card is bound to through the member cache
+func lookupCardMember(cfg *config.Config, members *services.MemberCache, card *models.RFIDCard) (*services.MemberLookup, error) {
+	return members.Get(memberCacheKey(card.AuthentikID, card.Email), func() (*models.UserProfile, *models.MembershipInfo, error) {
+		return fetchMember(cfg, card.AuthentikID, card.Email)
+	})
+}
+
+// bindCard looks the member up in Authentik and binds the card to them
+func bindCard(cfg *config.Config, cards *services.RFIDCardStore, logger *services.Logger, req bindCardRequest, boundBy string) (*models.RFIDCard, error) {
+	user, err := services.NewAuthentikClient(cfg).GetUserByEmail(strings.TrimSpace(req.Email))
+	if err != nil || user == nil {
+		return nil, errCardMemberNotFound
+	}
+
+	card, err := cards.Bind(req.UID, user, req.Label, boundBy)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Audit("Card %s bound to %s by %s", card.UID, card.Email, boundBy)
+	syncCardAttribute(cfg, cards, logger, card)
+	return card, nil
+}
+
+// unbindCard removes a card from its member
+func unbindCard(cfg *config.Config, cards *services.RFIDCardStore, logger *services.Logger, uid, unboundBy string) (*models.RFIDCard, error) {
+	card, err := cards.Unbind(uid)
+	if err != nil {
+		return nil, err
+	}
+
+	logger.Audit("Card %s unbound from %s by %s", card.UID, card.Email, unboundBy)
+	syncCardAttribute(cfg, cards, logger, card)
+	return card, nil
+}
+
+// syncCardAttribute copies the member's card UIDs to their Authentik account
+// The local store decides; the attribute lets other systems that read Authentik see the cards
+func syncCardAttribute(cfg *config.Config, cards *services.RFIDCardStore, logger *services.Logger, card *models.RFIDCard) {
+	if cfg.AuthentikAPIToken == "" || card.AuthentikID == "" {
+		return
+	}
+
+	authentikClient := services.NewAuthentikClient(cfg)
+	if err := authentikClient.SetUserAttribute(card.AuthentikID, services.RFIDUIDsAttribute, cards.UIDs(card.Email)); err != nil {
+		logger.Error("Failed to store card UIDs in Authentik for %s: %v", card.Email, err)
+	}
+}
+
+// bindCardError maps an error from bindCard to a status and a message for staff
+func bindCardError(cards *services.RFIDCardStore, uid string, err error) (int, string) {
+	switch {
+	case errors.Is(err, errCardMemberNotFound):
+		return http.StatusNotFound, "No member with that email"
+	case errors.Is(err, services.ErrCardTaken):
+		if owner := cards.Lookup(uid); owner != nil {
+			return http.StatusConflict, "Card is already bound to " + owner.MemberName + " (" + owner.Email + "); unbind it first"
+		}
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, services.ErrCardLimit):
+		return http.StatusConflict, err.Error()
+	case errors.Is(err, services.ErrCardUID), errors.Is(err, services.ErrCardLabelLength):
+		return http.StatusBadRequest, err.Error()
+	default:
+		return http.StatusInternalServerError, "Failed to bind card"
+	}
+}
+
+// renderCardsPage renders cards.html with every bound card merged into data
+func renderCardsPage(c *gin.Context, cfg *config.Config, cards *services.RFIDCardStore, status int, data gin.H) {
+	user, _ := c.Get("user")
+
+	data["title"] = "RFID Cards - " + cfg.MakerspaceName
+	data["makerspace_name"] = cfg.MakerspaceName
+	data["user"] = user
+	data["cards"] = cards.List("")
+
+	RenderHTML(c, status, "cards.html", data)
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// certificationCheckRequest is the body tool controllers send to ask whether a member may use a tool
+type certificationCheckRequest struct {
+	Credential string `json:"credential" binding:"required"` // Scanned card or live code (or its URL), or a card UID
+	Type       string `json:"type"`                          // "qr" (default) or "rfid"
+	Tool       string `json:"tool" binding:"required"`       // Certification slug, e.g. "laser"
+}
+
+// MyCertificationsHandler returns the current user's certifications, including expired ones
+func MyCertificationsHandler(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*models.UserProfile)
+
+		// Header-authenticated profiles only know cert- groups, so prefer the full profile from Authentik
+		certifications := user.Certifications
+		if profile, err := services.NewAuthentikClient(cfg).GetUserByEmail(user.Email); err == nil && profile != nil {
+			certifications = profile.Certifications
+		}
+
+		c.JSON(http.StatusOK, gin.H{"certifications": nonNilCertifications(certifications)})
+	}
+}
+
+// MemberCertificationsHandler returns a member's certifications by ?email=
+// With ?tool= it also says whether the member may use that tool right now
+func MemberCertificationsHandler(cfg *config.Config, members *services.MemberCache) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := strings.TrimSpace(c.Query("email"))
+		if email == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+			return
+		}
+
+		lookup, err := members.Get(memberCacheKey("", email), func() (*models.UserProfile, *models.MembershipInfo, error) {
+			return fetchMember(cfg, "", email)
+		})
+		if errors.Is(err, services.ErrLookupTimeout) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Authentik did not answer in time"})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+			return
+		}
+
+		result := gin.H{
+			"email":          lookup.User.Email,
+			"display_name":   lookup.User.GetFullName(),
+			"certifications": nonNilCertifications(lookup.User.Certifications),
+		}
+		if tool := strings.TrimSpace(c.Query("tool")); tool != "" {
+			certified, reason, _ := models.CheckCertification(lookup.User, lookup.Membership, tool, time.Now())
+			result["tool"] = strings.ToLower(tool)
+			result["certified"] = certified
+			result["reason"] = reason
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// CheckCertificationHandler answers a tool controller or kiosk whether the member behind a credential may use a tool
+// Like door decisions, the member comes from the member cache and a denial is a 200 response
+func CheckCertificationHandler(cfg *config.Config, tokens *services.TokenService, cards *services.RFIDCardStore, members *services.MemberCache, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req certificationCheckRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "credential and tool are required"})
+			return
+		}
+		if req.Type == "" {
+			req.Type = models.CredentialQR
+		}
+		if req.Type != models.CredentialQR && req.Type != models.CredentialRFID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "type must be \"qr\" or \"rfid\""})
+			return
+		}
+		tool := strings.ToLower(strings.TrimSpace(req.Tool))
+
+		result := gin.H{"tool": tool, "certified": false}
+		lookup, _, reason := credentialMember(cfg, tokens, cards, members, req.Type, req.Credential)
+		if reason == "" {
+			var certification *models.Certification
+			var certified bool
+			certified, reason, certification = models.CheckCertification(lookup.User, lookup.Membership, tool, time.Now())
+			result["certified"] = certified
+			if certification != nil {
+				result["certification"] = certification
+			}
+		}
+		result["reason"] = reason
+
+		if lookup != nil {
+			result["member"] = gin.H{
+				"display_name":      lookup.User.GetFullName(),
+				"access_level_name": lookup.User.AccessLevel.String(),
+			}
+			logger.Info("Certification check for %s on %s via %s: %s", lookup.User.Email, tool, requestActor(c), reason)
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// nonNilCertifications makes an empty list encode as [] rather than null
+func nonNilCertifications(certifications []models.Certification) []models.Certification {
+	if certifications == nil {
+		return []models.Certification{}
+	}
+	return certifications
+}
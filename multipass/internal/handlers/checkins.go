@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"errors"
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"multipass/internal/utils"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkinRequest is the body accepted by the check-in API
+type checkinRequest struct {
+	Token     string `json:"token" binding:"required"` // Bare token or the scanned card URL
+	Location  string `json:"location"`                 // One of CHECKIN_LOCATIONS; empty uses the first
+	Direction string `json:"direction"`                // "in", "out", or empty to toggle
+	Device    string `json:"device"`                   // Scanner name; defaults to the API key or user
+}
+
+// maxHistoryDays limits how far back history and daily count queries reach
+const maxHistoryDays = 400
+
+// RecordCheckinHandler verifies a scanned token and records a check-in or check-out for its member
+// Like the verification API it answers 200 with recorded set to false when the scan is refused
+func RecordCheckinHandler(cfg *config.Config, tokens *services.TokenService, shareLinks *services.ShareLinkStore, checkins *services.CheckinStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req checkinRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+			return
+		}
+
+		scan, err := scanToken(c, cfg, tokens, shareLinks, nil, logger, req.Token)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve membership info"})
+			return
+		}
+		if scan.reason != "" {
+			c.JSON(http.StatusOK, gin.H{"recorded": false, "reason": scan.reason})
+			return
+		}
+
+		// A forwarded share link identifies the member but must not mark them present
+		if !ownCredential(scan.data) {
+			c.JSON(http.StatusOK, gin.H{"recorded": false, "reason": services.TokenReasonWrongPurpose})
+			return
+		}
+
+		// Members who would be turned away at the desk are not checked in
+		color, status, _ := models.EvaluateMembership(scan.user, scan.membership, time.Now())
+		if color == models.VerificationRed {
+			c.JSON(http.StatusOK, gin.H{"recorded": false, "reason": "no_access", "status": strings.ToLower(status.String())})
+			return
+		}
+
+		event, err := recordCheckin(c, checkins, scan.user, req.Location, req.Direction, req.Device)
+		if errors.Is(err, services.ErrCheckinLocation) || errors.Is(err, services.ErrCheckinDirection) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to record check-in of %s: %v", scan.user.Email, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record check-in"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"recorded":  true,
+			"event":     event,
+			"occupancy": len(checkins.Present()),
+		})
+	}
+}
+
+// ownCredential reports whether a token is the member's own card or live code, the only ones that check them in
+func ownCredential(data *utils.TokenData) bool {
+	return data.Purpose == utils.PurposeCard || data.Purpose == utils.PurposeLive
+}
+
+// PresentMembersHandler returns the members in the space right now
+func PresentMembersHandler(checkins *services.CheckinStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		present := checkins.Present()
+		c.JSON(http.StatusOK, gin.H{"count": len(present), "members": present})
+	}
+}
+
+// CheckinHistoryHandler returns a member's check-ins, given as ?email= and ?days= (default 30)
+func CheckinHistoryHandler(checkins *services.CheckinStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := strings.TrimSpace(c.Query("email"))
+		if email == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+			return
+		}
+
+		renderCheckinHistory(c, checkins, email)
+	}
+}
+
+// MyCheckinsHandler returns the signed-in member's own check-ins, given ?days= (default 30)
+func MyCheckinsHandler(checkins *services.CheckinStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*models.UserProfile)
+		renderCheckinHistory(c, checkins, user.Email)
+	}
+}
+
+// DailyCheckinsHandler returns check-in counts per day between ?from= and ?to= (YYYY-MM-DD), defaulting to the last 30 days
+func DailyCheckinsHandler(checkins *services.CheckinStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		to := time.Now()
+		from := to.AddDate(0, 0, -29)
+
+		for param, target := range map[string]*time.Time{"from": &from, "to": &to} {
+			if value := c.Query(param); value != "" {
+				parsed, err := time.ParseInLocation(models.GuestPassDateLayout, value, time.Local)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be in YYYY-MM-DD format"})
+					return
+				}
+				*target = parsed
+			}
+		}
+		if to.Before(from) || to.Sub(from) > maxHistoryDays*24*time.Hour {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be before to and at most 400 days earlier"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"days": checkins.DailyCounts(from, to)})
+	}
+}
+
+// recordCheckin records a scan, naming the scanner after the API key or staff member if it sent no name
+func recordCheckin(c *gin.Context, checkins *services.CheckinStore, user *models.UserProfile, location, direction, device string) (*models.CheckinEvent, error) {
+	actor := requestActor(c)
+	device = strings.TrimSpace(device)
+	if device == "" {
+		device = actor
+	}
+	return checkins.Record(user, location, direction, device, actor)
+}
+
+// renderCheckinHistory writes the member's check-ins for the last ?days= days
+func renderCheckinHistory(c *gin.Context, checkins *services.CheckinStore, email string) {
+	days := 30
+	if value := c.Query("days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxHistoryDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "days must be between 1 and 400"})
+			return
+		}
+		days = parsed
+	}
+
+	since := time.Now().AddDate(0, 0, -days)
+	c.JSON(http.StatusOK, gin.H{
+		"email":  email,
+		"days":   days,
+		"events": checkins.History(email, since),
+	})
+}
@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"errors"
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers the card page sends with each live code request when device binding is on
+const (
+	deviceIDHeader        = "X-Device-ID"
+	deviceChallengeHeader = "X-Device-Challenge"
+	deviceSignatureHeader = "X-Device-Signature"
+)
+
+// registerDeviceRequest is the body the card page sends after creating a device key
+type registerDeviceRequest struct {
+	Name      string                 `json:"name"`
+	PublicKey models.DevicePublicKey `json:"public_key" binding:"required"`
+}
+
+// resetDevicesRequest is the body accepted by the staff device reset endpoint
+type resetDevicesRequest struct {
+	Email  string `json:"email" binding:"required"`
+	Reason string `json:"reason"` // Note for the audit trail
+}
+
+// DevicesPageHandler shows the member's registered devices
+func DevicesPageHandler(cfg *config.Config, devices *services.DeviceStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderDevicesPage(c, cfg, devices, http.StatusOK, gin.H{})
+	}
+}
+
+// RegisterDeviceHandler records the public key of a browser the signed-in member is using
+// The card page calls it on first use; a forwarded card link cannot register because it carries no session
+func RegisterDeviceHandler(devices *services.DeviceStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*models.UserProfile)
+
+		var req registerDeviceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "public_key is required"})
+			return
+		}
+
+		device, err := devices.Register(user.Email, req.Name, req.PublicKey)
+		if errors.Is(err, services.ErrDeviceLimit) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		logger.Audit("Device %s (%s) registered by %s", device.ID, device.Name, user.Email)
+		c.JSON(http.StatusCreated, gin.H{"device": device})
+	}
+}
+
+// RemoveDeviceFormHandler handles the remove buttons on the devices page
+func RemoveDeviceFormHandler(cfg *config.Config, devices *services.DeviceStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*models.UserProfile)
+		id := c.Param("id")
+
+		err := devices.Remove(id, user.Email)
+		if errors.Is(err, services.ErrDeviceNotFound) {
+			renderDevicesPage(c, cfg, devices, http.StatusNotFound, gin.H{"error": "Device not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to remove device %s: %v", id, err)
+			renderDevicesPage(c, cfg, devices, http.StatusInternalServerError, gin.H{"error": "Failed to remove device"})
+			return
+		}
+
+		logger.Audit("Device %s removed by %s", id, user.Email)
+		c.Redirect(http.StatusSeeOther, "/devices")
+	}
+}
+
+// ListMyDevicesHandler returns the signed-in member's devices
+func ListMyDevicesHandler(devices *services.DeviceStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*models.UserProfile)
+		c.JSON(http.StatusOK, gin.H{"devices": devices.List(user.Email)})
+	}
+}
+
+// RemoveMyDeviceHandler removes one of the signed-in member's devices
+func RemoveMyDeviceHandler(devices *services.DeviceStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*models.UserProfile)
+		id := c.Param("id")
+
+		err := devices.Remove(id, user.Email)
+		if errors.Is(err, services.ErrDeviceNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to remove device %s: %v", id, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove device"})
+			return
+		}
+
+		logger.Audit("Device %s removed by %s", id, user.Email)
+		c.JSON(http.StatusOK, gin.H{"status": "removed"})
+	}
+}
+
+// ListMemberDevicesHandler lets staff see a member's devices, given as ?email=
+func ListMemberDevicesHandler(devices *services.DeviceStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := strings.TrimSpace(c.Query("email"))
+		if email == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"email": email, "devices": devices.List(email)})
+	}
+}
+
+// ResetDevicesHandler lets staff remove every device of a member, e.g. after a lost phone
+// The member's next visit to their card registers the browser they are using
+func ResetDevicesHandler(devices *services.DeviceStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req resetDevicesRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+			return
+		}
+
+		removed, err := devices.Reset(req.Email)
+		if err != nil {
+			logger.Error("Failed to reset devices of %s: %v", req.Email, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset devices"})
+			return
+		}
+
+		logger.Audit("Devices of %s reset by %s (%d removed): %s", req.Email, currentUserEmail(c), removed, req.Reason)
+		c.JSON(http.StatusOK, gin.H{"status": "reset", "email": req.Email, "removed": removed})
+	}
+}
+
+// checkDeviceSignature verifies the signed challenge a live code request carries
+// On failure it writes a 403 with a fresh challenge, so the card can register or retry, and returns false
+func checkDeviceSignature(c *gin.Context, devices *services.DeviceStore, user *models.UserProfile) bool {
+	deviceID := c.GetHeader(deviceIDHeader)
+	challenge := c.GetHeader(deviceChallengeHeader)
+	signature := c.GetHeader(deviceSignatureHeader)
+
+	reason := ""
+	if deviceID == "" || challenge == "" || signature == "" {
+		reason = "device_required"
+	} else if _, err := devices.VerifyChallenge(user.Email, deviceID, challenge, signature); err != nil {
+		switch {
+		case errors.Is(err, services.ErrDeviceNotFound):
+			reason = "device_unknown"
+		case errors.Is(err, services.ErrDeviceChallenge):
+			reason = "challenge_expired"
+		default:
+			reason = "bad_signature"
+		}
+	}
+	if reason == "" {
+		return true
+	}
+
+	response := gin.H{"error": "A registered device is required to show this card", "reason": reason}
+	if next, err := devices.Challenge(user.Email); err == nil {
+		response["challenge"] = next
+	}
+	c.JSON(http.StatusForbidden, response)
+	return false
+}
+
+// renderDevicesPage renders devices.html with the member's devices merged into data
+func renderDevicesPage(c *gin.Context, cfg *config.Config, devices *services.DeviceStore, status int, data gin.H) {
+	user := c.MustGet("user").(*models.UserProfile)
+
+	data["title"] = "Your Devices - " + cfg.MakerspaceName
+	data["makerspace_name"] = cfg.MakerspaceName
+	data["logo_url"] = cfg.LogoURL
+	data["devices"] = devices.List(user.Email)
+	data["device_binding"] = cfg.DeviceBinding
+
+	RenderHTML(c, status, "devices.html", data)
+}
@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"multipass/internal/config"
+	"multipass/internal/middleware"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"multipass/internal/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// guestPassRequest is the form on the guest pass page
+type guestPassRequest struct {
+	GuestName string `form:"guest_name"`
+	Date      string `form:"date"` // YYYY-MM-DD; empty means today
+}
+
+// GuestPassesPageHandler shows the guest pass form and the member's passes this month
+func GuestPassesPageHandler(cfg *config.Config, guests *services.GuestPassStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderGuestPassesPage(c, cfg, guests, http.StatusOK, gin.H{})
+	}
+}
+
+// IssueGuestPassHandler issues a guest pass within the member's monthly quota and shows its QR code
+func IssueGuestPassHandler(cfg *config.Config, tokens *services.TokenService, guests *services.GuestPassStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*models.UserProfile)
+
+		var req guestPassRequest
+		if err := c.ShouldBind(&req); err != nil {
+			renderGuestPassesPage(c, cfg, guests, http.StatusBadRequest, gin.H{"error": "Invalid form submission"})
+			return
+		}
+
+		visitDate := time.Now()
+		if req.Date != "" {
+			parsed, err := time.ParseInLocation(models.GuestPassDateLayout, req.Date, time.Local)
+			if err != nil {
+				renderGuestPassesPage(c, cfg, guests, http.StatusBadRequest, gin.H{"error": "Invalid visit date"})
+				return
+			}
+			visitDate = parsed
+		}
+
+		// The token names the sponsor, so revoking the member's tokens also cancels their guest passes
+		quota := models.GuestPassQuota(user.AccessLevel, cfg.GroupMappingConfig)
+		token, pass, err := guests.Issue(tokens, user, req.GuestName, visitDate, quota)
+		if errors.Is(err, services.ErrGuestPassQuota) {
+			renderGuestPassesPage(c, cfg, guests, http.StatusTooManyRequests, gin.H{"error": "You have used all your guest passes for that month"})
+			return
+		}
+		if err != nil {
+			renderGuestPassesPage(c, cfg, guests, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		logger.Audit("Guest pass %s issued by %s for %s on %s", pass.ID, user.Email, pass.GuestName, pass.VisitDate)
+
+		// Create guest card URL with properly encoded token
+		guestURL := middleware.RequestBaseURL(c) + "/guest/" + token
+
+		// Generate QR code as base64 data URI
+		qrCodeBase64, err := utils.GenerateQRCodeBase64(guestURL, 250)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
+			return
+		}
+
+		renderGuestPassesPage(c, cfg, guests, http.StatusCreated, gin.H{
+			"new_pass":     pass,
+			"guest_url":    guestURL,
+			"qr_code_html": template.HTML("<img src=\"" + qrCodeBase64 + "\" alt=\"QR Code\" class=\"qr-code\">"),
+		})
+	}
+}
+
+// GuestCardHandler verifies a guest pass and renders the guest card
+// Opening the card does not count as a visit; the front desk records it when staff verify the pass
+func GuestCardHandler(cfg *config.Config, tokens *services.TokenService, guests *services.GuestPassStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		data := gin.H{
+			"title":           "Guest Pass - " + cfg.MakerspaceName,
+			"makerspace_name": cfg.MakerspaceName,
+			"logo_url":        cfg.LogoURL,
+		}
+
+		token := c.Param("token")
+		if token == "" {
+			token = c.Query("token")
+		}
+		if token == "" {
+			data["error"] = "Token required"
+			RenderHTML(c, http.StatusUnauthorized, "guest_card.html", data)
+			return
+		}
+
+		tokenData, err := tokens.Verify(token, utils.PurposeGuest)
+		if errors.Is(err, services.ErrTokenRevoked) {
+			logger.Info("Revoked guest pass %s presented for %s", tokenData.ID, tokenData.Email)
+			data["error"] = "This guest pass has been cancelled"
+			RenderHTML(c, http.StatusUnauthorized, "guest_card.html", data)
+			return
+		}
+		if err != nil {
+			logger.Debug("Guest pass verification failed: %v", err)
+			data["error"] = "Invalid or expired guest pass"
+			RenderHTML(c, http.StatusUnauthorized, "guest_card.html", data)
+			return
+		}
+
+		// Compact tokens carry no email, so the sponsor's "invalidate all" is checked against the pass
+		pass, err := guests.Get(tokenData.ID)
+		if err == nil && tokens.CheckOwner(tokenData, &models.UserProfile{Email: pass.SponsorEmail}) != nil {
+			data["error"] = "This guest pass has been cancelled"
+			RenderHTML(c, http.StatusUnauthorized, "guest_card.html", data)
+			return
+		}
+
+		if err != nil {
+			data["error"] = "Invalid or expired guest pass"
+			RenderHTML(c, http.StatusUnauthorized, "guest_card.html", data)
+			return
+		}
+
+		data["pass"] = pass
+		if !pass.ValidOn(time.Now()) {
+			data["error"] = "This guest pass is only valid on " + pass.VisitDate
+			RenderHTML(c, http.StatusForbidden, "guest_card.html", data)
+			return
+		}
+
+		RenderHTML(c, http.StatusOK, "guest_card.html", data)
+	}
+}
+
+// ListGuestPassesHandler returns the guest passes for a month, given as ?month=YYYY-MM, defaulting to this one
+func ListGuestPassesHandler(guests *services.GuestPassStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		month := time.Now()
+		if value := c.Query("month"); value != "" {
+			parsed, err := time.ParseInLocation("2006-01", value, time.Local)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "month must be in YYYY-MM format"})
+				return
+			}
+			month = parsed
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"month":  month.Format("2006-01"),
+			"passes": guests.ListMonth(month),
+		})
+	}
+}
+
+// renderGuestPassesPage renders guests.html with the member's quota and passes merged into data
+func renderGuestPassesPage(c *gin.Context, cfg *config.Config, guests *services.GuestPassStore, status int, data gin.H) {
+	user := c.MustGet("user").(*models.UserProfile)
+	now := time.Now()
+	quota := models.GuestPassQuota(user.AccessLevel, cfg.GroupMappingConfig)
+	used := guests.Used(user.Email, now)
+
+	remaining := quota - used
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	data["title"] = "Guest Passes - " + cfg.MakerspaceName
+	data["makerspace_name"] = cfg.MakerspaceName
+	data["logo_url"] = cfg.LogoURL
+	data["quota"] = quota
+	data["remaining"] = remaining
+	data["today"] = now.Format(models.GuestPassDateLayout)
+	data["passes"] = guests.ListBySponsor(user.Email)
+
+	RenderHTML(c, status, "guests.html", data)
+}
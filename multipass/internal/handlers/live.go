@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"multipass/internal/config"
+	"multipass/internal/middleware"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"multipass/internal/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// LiveCodeHandler returns a fresh live code and its QR for the member's own card
+// The card page polls it every LIVE_CODE_INTERVAL; share links cannot mint live codes
+// With device binding each request must carry a challenge signed by one of the member's registered devices
+func LiveCodeHandler(cfg *config.Config, tokens *services.TokenService, devices *services.DeviceStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.LiveCardEnabled {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Live card codes are disabled"})
+			return
+		}
+
+		// Only the member's card token may mint live codes
+		cardToken, ok := c.MustGet("token_data").(*utils.TokenData)
+		if !ok || cardToken.Purpose != utils.PurposeCard {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Live codes are only available on your own card"})
+			return
+		}
+
+		user := c.MustGet("user").(*models.UserProfile)
+		if cfg.DeviceBinding && !checkDeviceSignature(c, devices, user) {
+			return
+		}
+
+		liveURL, qrCode, liveToken, err := issueLiveCode(c, tokens, user)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate live code"})
+			return
+		}
+
+		response := gin.H{
+			"url":        liveURL,
+			"qr_code":    qrCode,
+			"issued_at":  liveToken.Timestamp,
+			"expires_at": liveToken.ExpiresAt,
+			"refresh_in": int(tokens.LiveCodeInterval().Seconds()),
+		}
+
+		// The device signs this challenge for the next refresh
+		if cfg.DeviceBinding {
+			challenge, err := devices.Challenge(user.Email)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate device challenge"})
+				return
+			}
+			response["challenge"] = challenge
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// issueLiveCode signs a live code for the card's owner and renders its public URL as a QR code
+func issueLiveCode(c *gin.Context, tokens *services.TokenService, user *models.UserProfile) (string, string, *utils.TokenData, error) {
+	token, data, err := tokens.IssueLiveCode(user)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	liveURL := middleware.RequestBaseURL(c) + cardPath(token)
+	qrCode, err := utils.GenerateQRCodeBase64(liveURL, 250)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	return liveURL, qrCode, data, nil
+}
+
+// blankQRCode is a transparent pixel shown in place of the QR code until a registered device unlocks the card
+const blankQRCode = "data:image/gif;base64,R0lGODlhAQABAIAAAAAAAP///yH5BAEAAAAALAAAAAABAAEAAAIBRAA7"
+
+// cardPath returns the path of the public card for a token
+// Tokens are base64url, so they go in the path rather than the query string
+func cardPath(token string) string {
+	return "/c/" + token
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MemberLookupHandler returns a member's profile and membership by email for staff and machine clients
+func MemberLookupHandler(cfg *config.Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		email := c.Query("email")
+		if email == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "email is required"})
+			return
+		}
+
+		// Look up the member in Authentik
+		authentikClient := services.NewAuthentikClient(cfg)
+		userProfile, err := authentikClient.GetUserByEmail(email)
+		if err != nil || userProfile == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+			return
+		}
+
+		// Create membership info
+		membership := &models.MembershipInfo{
+			MembershipType: userProfile.AccessLevel.String(),
+			Status:         models.StatusActive,
+			UserLevel:      userProfile.AccessLevel,
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"user":       userProfile,
+			"membership": membership,
+		})
+	}
+}
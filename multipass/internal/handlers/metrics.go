@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"multipass/internal/services"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsHandler exposes the throttling counters to Prometheus on private networks only
+func MetricsHandler(stats *services.RateLimitStats) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// The client IP honors X-Forwarded-For from trusted proxies, so proxied internet clients are refused
+		ip := net.ParseIP(c.ClientIP())
+		if ip == nil || !(ip.IsLoopback() || ip.IsPrivate()) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Metrics are only available on private networks"})
+			return
+		}
+
+		c.Header("Content-Type", "text/plain; version=0.0.4")
+		c.Status(http.StatusOK)
+		stats.WritePrometheus(c.Writer)
+	}
+}
@@ -0,0 +1,10 @@
+package handlers
+
+import "github.com/gin-gonic/gin"
+
+// RenderHTML renders a page template with the request's CSRF token added to its data
+// Every page goes through it, so the csrf-token meta tag in base.html and csrf_field always get the token
+func RenderHTML(c *gin.Context, status int, name string, data gin.H) {
+	data["csrf_token"] = c.GetString("csrf_token")
+	c.HTML(status, name, data)
+}
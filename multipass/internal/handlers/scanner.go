@@ -0,0 +1,165 @@
+package handlers
+
+import (
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"multipass/internal/utils"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// scanHeadlines explains why a scanned code was refused, by token failure reason
+var scanHeadlines = map[string]string{
+	services.TokenReasonExpired:      "Card link expired",
+	services.TokenReasonBadSignature: "Not a valid multipass code",
+	services.TokenReasonMalformed:    "Not a multipass code",
+	services.TokenReasonWrongPurpose: "Not a member card",
+	services.TokenReasonNotYetValid:  "Card link not valid yet",
+	services.TokenReasonRevoked:      "Card link was revoked",
+	services.TokenReasonExhausted:    "Share link used up",
+	services.TokenReasonUserMissing:  "Member not found",
+	services.GuestReasonNotFound:     "Unknown guest pass",
+	services.GuestReasonNotToday:     "Guest pass is for another day",
+}
+
+// scanRequest is a code scanned on the front desk page
+type scanRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Location string `json:"location"` // Records a check-in or check-out here; empty only verifies
+	Device   string `json:"device"`   // Name the scanner page was given, for the check-in log
+}
+
+// VerifyPageHandler shows the front desk scanner
+func VerifyPageHandler(cfg *config.Config, checkins *services.CheckinStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		RenderHTML(c, http.StatusOK, "verify.html", gin.H{
+			"title":           "Verify Members - " + cfg.MakerspaceName,
+			"makerspace_name": cfg.MakerspaceName,
+			"logo_url":        cfg.LogoURL,
+			"user":            c.MustGet("user"),
+			"locations":       checkins.Locations(),
+		})
+	}
+}
+
+// ScanHandler verifies a code scanned on the front desk page and returns a green, yellow or red result
+// Unlike the verification API it decides for staff: it adds the member's photo, effective status and warnings
+// With a location, members who may come in are also checked in, or out if they were already in
+// Guest passes are accepted on their visit date and record the guest's visit; guests are not checked in
+func ScanHandler(cfg *config.Config, tokens *services.TokenService, shareLinks *services.ShareLinkStore, guests *services.GuestPassStore, checkins *services.CheckinStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req scanRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+			return
+		}
+
+		scan, err := scanToken(c, cfg, tokens, shareLinks, guests, logger, req.Token)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve membership info"})
+			return
+		}
+
+		now := time.Now()
+		result := gin.H{"scanned_at": now.Format("15:04:05")}
+
+		if scan.reason != "" {
+			headline, ok := scanHeadlines[scan.reason]
+			if !ok {
+				headline = "Card not accepted"
+			}
+			if scan.reason == services.GuestReasonNotToday {
+				headline = "Guest pass is for " + scan.guest.VisitDate
+			}
+			result["result"] = models.VerificationRed
+			result["reason"] = scan.reason
+			result["headline"] = headline
+			result["warnings"] = []string{}
+			c.JSON(http.StatusOK, result)
+			return
+		}
+
+		if scan.guest != nil {
+			result["result"] = models.VerificationGreen
+			result["headline"] = "Welcome, " + scan.guest.GuestName
+			result["warnings"] = []string{}
+			result["member"] = gin.H{
+				"display_name":      scan.guest.GuestName,
+				"status":            "guest",
+				"access_level_name": "Guest of " + scan.guest.SponsorName,
+			}
+			c.JSON(http.StatusOK, result)
+			return
+		}
+
+		color, status, warnings := models.EvaluateMembership(scan.user, scan.membership, now)
+
+		// How the code was presented matters at the desk too, but never turns a red result green
+		if scan.data.Purpose == utils.PurposeShare {
+			warnings = append(warnings, "Shared link, not the member's own card")
+		}
+		if scan.data.Purpose == utils.PurposeCard && cfg.LiveCardEnabled {
+			warnings = append(warnings, "Static card link, not a live code")
+		}
+		if scan.data.Version < utils.TokenVersion2 {
+			warnings = append(warnings, "Legacy card link")
+		}
+
+		headline := "Welcome, " + scan.user.GetFullName()
+		if color == models.VerificationRed {
+			headline = "Do not admit"
+		}
+
+		// Log the visit at the scanner's location
+		if req.Location != "" && color != models.VerificationRed && !ownCredential(scan.data) {
+			warnings = append(warnings, "Not checked in: only the member's own card checks them in")
+		} else if req.Location != "" && color != models.VerificationRed {
+			event, err := recordCheckin(c, checkins, scan.user, req.Location, "", req.Device)
+			if err != nil {
+				logger.Error("Failed to record check-in of %s: %v", scan.user.Email, err)
+				warnings = append(warnings, "Check-in was not recorded")
+			} else {
+				result["checkin"] = event
+				if event.Direction == models.CheckinOut {
+					headline = "Goodbye, " + scan.user.GetFullName()
+				}
+			}
+			result["occupancy"] = len(checkins.Present())
+		}
+
+		// Any warning, including a failed check-in, makes a green result yellow
+		if color == models.VerificationGreen && len(warnings) > 0 {
+			color = models.VerificationYellow
+		}
+
+		member := gin.H{
+			"display_name":      scan.user.GetFullName(),
+			"initials":          scan.user.GetInitials(),
+			"status":            strings.ToLower(status.String()),
+			"access_level_name": scan.user.AccessLevel.String(),
+			"membership_type":   scan.membership.MembershipType,
+		}
+		if scan.user.Avatar != nil {
+			member["photo_url"] = *scan.user.Avatar
+		}
+		if scan.membership.ExpiryDate != nil {
+			member["membership_expires"] = scan.membership.ExpiryDate.Format("Jan 2, 2006")
+		}
+		// Staff at the desk see which tools the member may use
+		tools := make([]string, 0)
+		for _, cert := range scan.user.CurrentCertifications(time.Now()) {
+			tools = append(tools, cert.Tool)
+		}
+		member["certifications"] = tools
+
+		result["result"] = color
+		result["headline"] = headline
+		result["warnings"] = warnings
+		result["member"] = member
+		c.JSON(http.StatusOK, result)
+	}
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"multipass/internal/config"
+	"multipass/internal/middleware"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"multipass/internal/utils"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// shareLifetime is a lifetime members can pick for a share link
+type shareLifetime struct {
+	Value    string // Form value
+	Label    string
+	Duration time.Duration
+}
+
+// shareLifetimes lists the lifetimes offered on the share page; the last one is the default
+var shareLifetimes = []shareLifetime{
+	{Value: "1h", Label: "1 hour", Duration: time.Hour},
+	{Value: "1d", Label: "1 day", Duration: 24 * time.Hour},
+	{Value: "7d", Label: "7 days", Duration: 7 * 24 * time.Hour},
+	{Value: "30d", Label: "30 days", Duration: utils.TokenValidityDuration},
+}
+
+// shareLinkRequest is the form on the share page
+type shareLinkRequest struct {
+	Lifetime string `form:"lifetime"`
+	Scope    string `form:"scope"`
+	MaxViews int    `form:"max_views"` // 0 allows unlimited views
+}
+
+// SharePageHandler shows the share options and the member's active links
+func SharePageHandler(shareLinks *services.ShareLinkStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		renderSharePage(c, shareLinks, http.StatusOK, gin.H{})
+	}
+}
+
+// GenerateTokenLinkHandler creates a share link with the chosen options and shows its QR code
+// With Shlink configured the page and QR code show a short URL instead of the long card URL
+func GenerateTokenLinkHandler(tokens *services.TokenService, shareLinks *services.ShareLinkStore, shlink *services.ShlinkClient, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*models.UserProfile)
+
+		var req shareLinkRequest
+		if err := c.ShouldBind(&req); err != nil {
+			renderSharePage(c, shareLinks, http.StatusBadRequest, gin.H{"error": "Invalid form submission"})
+			return
+		}
+
+		// Resolve the options, defaulting to the full card for 30 days
+		lifetime := shareLifetimes[len(shareLifetimes)-1]
+		for _, option := range shareLifetimes {
+			if option.Value == req.Lifetime {
+				lifetime = option
+			}
+		}
+		scope := req.Scope
+		if scope == "" {
+			scope = models.ShareScopeFull
+		}
+
+		// Generate token
+		token, link, err := shareLinks.Issue(tokens, user, scope, lifetime.Duration, req.MaxViews)
+		if err != nil {
+			renderSharePage(c, shareLinks, http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Create public card URL with properly encoded token
+		publicCardURL := middleware.RequestBaseURL(c) + cardPath(token)
+
+		// Shorten it when Shlink is available; the long URL still works if it is not
+		shareURL, err := shareLinks.Shorten(shlink, link.ID, publicCardURL)
+		if err != nil {
+			logger.Error("Failed to shorten share link %s, using the long URL: %v", link.ID, err)
+		}
+
+		// Generate QR code as base64 data URI
+		qrCodeBase64, err := utils.GenerateQRCodeBase64(shareURL, 250)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate QR code"})
+			return
+		}
+
+		// Convert to template.HTML to prevent escaping
+		qrCodeHTML := template.HTML("<img src=\"" + qrCodeBase64 + "\" alt=\"QR Code\" class=\"qr-code\">")
+
+		renderSharePage(c, shareLinks, http.StatusCreated, gin.H{
+			"token":        token,
+			"public_url":   shareURL,
+			"qr_code_html": qrCodeHTML,
+			"new_link":     link,
+			"new_lifetime": lifetime.Label,
+		})
+	}
+}
+
+// RevokeShareLinkFormHandler handles the revoke buttons in the member's list of active links
+func RevokeShareLinkFormHandler(shareLinks *services.ShareLinkStore, shlink *services.ShlinkClient, revocations *services.RevocationStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*models.UserProfile)
+		id := c.Param("id")
+
+		link, err := shareLinks.Revoke(id, user.Email)
+		if errors.Is(err, services.ErrShareLinkNotFound) {
+			renderSharePage(c, shareLinks, http.StatusNotFound, gin.H{"error": "Share link not found"})
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to revoke share link %s: %v", id, err)
+			renderSharePage(c, shareLinks, http.StatusInternalServerError, gin.H{"error": "Failed to revoke share link"})
+			return
+		}
+
+		// The revocation list is what TokenAuthMiddleware checks
+		if _, err := revocations.Revoke(link.ID, link.Email, link.ExpiresAt, user.Email, "revoked by owner"); err != nil {
+			logger.Error("Failed to revoke share token %s: %v", id, err)
+			renderSharePage(c, shareLinks, http.StatusInternalServerError, gin.H{"error": "Failed to revoke share link"})
+			return
+		}
+
+		deleteShortURLs(shareLinks, shlink, logger, link)
+
+		logger.Audit("Share link %s revoked by %s", id, user.Email)
+		c.Redirect(http.StatusSeeOther, "/share")
+	}
+}
+
+// deleteShortURLs deletes the Shlink short URLs of revoked links
+// The token is already revoked, so a failure is logged and the short URL is left to expire with it
+func deleteShortURLs(shareLinks *services.ShareLinkStore, shlink *services.ShlinkClient, logger *services.Logger, links ...*models.ShareLink) {
+	for _, link := range links {
+		if err := shareLinks.DeleteShortURL(shlink, link.ID); err != nil {
+			logger.Error("Failed to delete short URL of share link %s: %v", link.ID, err)
+		}
+	}
+}
+
+// renderSharePage renders token_link.html with the share options and the member's active links merged into data
+func renderSharePage(c *gin.Context, shareLinks *services.ShareLinkStore, status int, data gin.H) {
+	cfg := config.Load()
+	user := c.MustGet("user").(*models.UserProfile)
+
+	data["title"] = "Share Your Digital ID - " + cfg.MakerspaceName
+	data["makerspace_name"] = cfg.MakerspaceName
+	data["logo_url"] = cfg.LogoURL
+	data["lifetimes"] = shareLifetimes
+	data["links"] = shareLinks.ListActive(user.Email)
+
+	RenderHTML(c, status, "token_link.html", data)
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"multipass/internal/utils"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// revokeTokenRequest is the body accepted by the staff revocation endpoint
+// Exactly one of Token, ID or Email identifies what to revoke
+type revokeTokenRequest struct {
+	Token  string `json:"token"`  // A leaked token or link; its ID is revoked
+	ID     string `json:"id"`     // A token ID, e.g. from the audit log
+	Email  string `json:"email"`  // Invalidate every token of this member
+	Reason string `json:"reason"` // Note for the audit trail
+}
+
+// InvalidateMyTokensHandler invalidates every card token and share link of the signed-in user
+func InvalidateMyTokensHandler(cfg *config.Config, revocations *services.RevocationStore, shareLinks *services.ShareLinkStore, shlink *services.ShlinkClient, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*models.UserProfile)
+
+		epoch, err := invalidateUserTokens(cfg, revocations, logger, user.Email, authentikPK(user))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invalidate tokens"})
+			return
+		}
+		revokeShareLinks(shareLinks, shlink, logger, user.Email)
+
+		logger.Audit("All tokens of %s invalidated by %s", user.Email, user.Email)
+		c.JSON(http.StatusOK, gin.H{"status": "invalidated", "epoch": epoch})
+	}
+}
+
+// InvalidateMyTokensFormHandler handles the "invalidate all my links" button and returns to the card
+func InvalidateMyTokensFormHandler(cfg *config.Config, revocations *services.RevocationStore, shareLinks *services.ShareLinkStore, shlink *services.ShlinkClient, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := c.MustGet("user").(*models.UserProfile)
+
+		if _, err := invalidateUserTokens(cfg, revocations, logger, user.Email, authentikPK(user)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invalidate tokens"})
+			return
+		}
+		revokeShareLinks(shareLinks, shlink, logger, user.Email)
+
+		logger.Audit("All tokens of %s invalidated by %s", user.Email, user.Email)
+		c.Redirect(http.StatusSeeOther, "/card")
+	}
+}
+
+// RevokeTokenHandler lets staff revoke a single token, or every token of a member
+// Share links that are revoked also lose their Shlink short URL
+func RevokeTokenHandler(cfg *config.Config, tokens *services.TokenService, revocations *services.RevocationStore, shareLinks *services.ShareLinkStore, shlink *services.ShlinkClient, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req revokeTokenRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body"})
+			return
+		}
+		revokedBy := currentUserEmail(c)
+
+		switch {
+		case req.Token != "":
+			data, err := tokens.Parse(tokenFromInput(req.Token))
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid or expired token"})
+				return
+			}
+			if data.ID == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Legacy tokens have no ID; invalidate the member's tokens by email instead"})
+				return
+			}
+
+			entry, err := revocations.Revoke(data.ID, data.Email, data.ExpiresAt, revokedBy, req.Reason)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+				return
+			}
+			if link := shareLinks.Get(data.ID); link != nil {
+				if _, err := shareLinks.Revoke(link.ID, link.Email); err != nil {
+					logger.Error("Failed to mark share link %s revoked: %v", link.ID, err)
+				}
+				deleteShortURLs(shareLinks, shlink, logger, link)
+			}
+
+			logger.Audit("Token %s of %s revoked by %s: %s", entry.ID, entry.Email, revokedBy, req.Reason)
+			c.JSON(http.StatusOK, gin.H{"status": "revoked", "token": entry})
+
+		case req.ID != "":
+			// Without the token its expiry is unknown, so keep the entry for the longest token lifetime
+			entry, err := revocations.Revoke(req.ID, "", time.Now().Add(utils.TokenValidityDuration), revokedBy, req.Reason)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+				return
+			}
+			if link := shareLinks.Get(req.ID); link != nil {
+				if _, err := shareLinks.Revoke(link.ID, link.Email); err != nil {
+					logger.Error("Failed to mark share link %s revoked: %v", link.ID, err)
+				}
+				deleteShortURLs(shareLinks, shlink, logger, link)
+			}
+
+			logger.Audit("Token %s revoked by %s: %s", entry.ID, revokedBy, req.Reason)
+			c.JSON(http.StatusOK, gin.H{"status": "revoked", "token": entry})
+
+		case req.Email != "":
+			// Find the member's Authentik PK so the epoch also lands on their account
+			pk := ""
+			if cfg.AuthentikAPIToken != "" {
+				if member, err := services.NewAuthentikClient(cfg).GetUserByEmail(req.Email); err == nil {
+					pk = authentikPK(member)
+				}
+			}
+
+			epoch, err := invalidateUserTokens(cfg, revocations, logger, req.Email, pk)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to invalidate tokens"})
+				return
+			}
+			revokeShareLinks(shareLinks, shlink, logger, req.Email)
+
+			logger.Audit("All tokens of %s invalidated by %s: %s", req.Email, revokedBy, req.Reason)
+			c.JSON(http.StatusOK, gin.H{"status": "invalidated", "email": req.Email, "epoch": epoch})
+
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token, id or email is required"})
+		}
+	}
+}
+
+// ListRevokedTokensHandler returns the tokens on the revocation list
+func ListRevokedTokensHandler(revocations *services.RevocationStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"tokens": revocations.List()})
+	}
+}
+
+// revokeShareLinks marks the member's share links revoked so the share page stops listing them, and deletes their short URLs
+func revokeShareLinks(shareLinks *services.ShareLinkStore, shlink *services.ShlinkClient, logger *services.Logger, email string) {
+	links, err := shareLinks.RevokeAll(email)
+	if err != nil {
+		logger.Error("Failed to save revoked share links of %s: %v", email, err)
+	}
+	deleteShortURLs(shareLinks, shlink, logger, links...)
+}
+
+// invalidateUserTokens moves the user's token epoch to now
+// The local store takes effect immediately; the Authentik attribute carries the epoch to other instances and survives a lost data directory
+func invalidateUserTokens(cfg *config.Config, revocations *services.RevocationStore, logger *services.Logger, email, pk string) (time.Time, error) {
+	epoch, err := revocations.InvalidateUser(email)
+	if err != nil {
+		logger.Error("Failed to store token epoch for %s: %v", email, err)
+		return time.Time{}, err
+	}
+
+	if cfg.AuthentikAPIToken != "" && pk != "" {
+		authentikClient := services.NewAuthentikClient(cfg)
+		if err := authentikClient.SetUserAttribute(pk, services.TokenEpochAttribute, epoch.Format(time.RFC3339)); err != nil {
+			logger.Error("Failed to store token epoch in Authentik for %s: %v", email, err)
+		}
+	}
+
+	return epoch, nil
+}
+
+// tokenFromInput accepts a full card or share URL as well as the bare token
+func tokenFromInput(input string) string {
+	input = strings.TrimSpace(input)
+
+	// Compact card and guest URLs carry the token in the path, e.g. https://multipass.example.com/c/<token>
+	if strings.Contains(input, "://") || strings.HasPrefix(input, "/") {
+		if parsed, err := url.Parse(input); err == nil {
+			for _, prefix := range []string{"/c/", "/guest/"} {
+				if rest, ok := strings.CutPrefix(parsed.Path, prefix); ok && rest != "" {
+					token, _, _ := strings.Cut(rest, "/")
+					return token
+				}
+			}
+		}
+	}
+
+	i := strings.Index(input, "token=")
+	if i < 0 {
+		return input
+	}
+
+	token := input[i+len("token="):]
+	if end := strings.IndexByte(token, '&'); end >= 0 {
+		token = token[:end]
+	}
+	if unescaped, err := url.QueryUnescape(token); err == nil {
+		token = unescaped
+	}
+	return token
+}
+
+// authentikPK returns the user's numeric Authentik primary key, or "" if it is not known
+func authentikPK(user *models.UserProfile) string {
+	for _, candidate := range []string{user.MemberID, user.AuthentikID} {
+		if _, err := strconv.Atoi(candidate); err == nil {
+			return candidate
+		}
+	}
+	return ""
+}
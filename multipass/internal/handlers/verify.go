@@ -0,0 +1,179 @@
+package handlers
+
+import (
+	"errors"
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"multipass/internal/utils"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// verifyTokenRequest is the body accepted by the token verification API
+type verifyTokenRequest struct {
+	Token string `json:"token" binding:"required"` // Bare token or the scanned card URL
+}
+
+// tokenScan is the outcome of checking a scanned token; reason is empty if it is valid
+// A guest pass sets guest instead of user and membership
+type tokenScan struct {
+	reason     string
+	data       *utils.TokenData
+	user       *models.UserProfile
+	membership *models.MembershipInfo
+	guest      *models.GuestPass
+}
+
+// VerifyTokenHandler checks a scanned card token and returns the result as JSON for scanners and door hardware
+// Invalid tokens are not an error: the response is 200 with valid set to false and a reason code
+// Share links are refused as wrong_purpose; only the staff scanner shows them, with a warning
+// A guest pass verified on its visit date is valid and counts as the guest's visit
+func VerifyTokenHandler(cfg *config.Config, tokens *services.TokenService, shareLinks *services.ShareLinkStore, guests *services.GuestPassStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req verifyTokenRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+			return
+		}
+
+		scan, err := scanToken(c, cfg, tokens, shareLinks, guests, logger, req.Token)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve membership info"})
+			return
+		}
+
+		// Whoever holds a forwarded share link is not necessarily the member, and door hardware has no staff to notice
+		if scan.reason == "" && scan.data.Purpose == utils.PurposeShare {
+			c.JSON(http.StatusOK, verifyResult(services.TokenReasonWrongPurpose, scan.data, nil, nil))
+			return
+		}
+
+		result := verifyResult(scan.reason, scan.data, scan.user, scan.membership)
+		if scan.guest != nil {
+			result["guest"] = gin.H{
+				"name":         scan.guest.GuestName,
+				"sponsor_name": scan.guest.SponsorName,
+				"visit_date":   scan.guest.VisitDate,
+				"visits":       scan.guest.Visits,
+			}
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// scanToken verifies a scanned token and looks up the member it belongs to
+// Guest passes are only accepted, and their visit recorded, when guests is given; otherwise they are the wrong purpose
+// The error is only set if the membership lookup failed; an invalid token is reported through reason
+func scanToken(c *gin.Context, cfg *config.Config, tokens *services.TokenService, shareLinks *services.ShareLinkStore, guests *services.GuestPassStore, logger *services.Logger, input string) (*tokenScan, error) {
+	// Card, share and live card tokens identify a member
+	purposes := []utils.TokenPurpose{utils.PurposeCard, utils.PurposeShare, utils.PurposeLive}
+	if guests != nil {
+		purposes = append(purposes, utils.PurposeGuest)
+	}
+	tokenData, err := tokens.Verify(tokenFromInput(input), purposes...)
+	if err != nil {
+		reason := services.TokenFailureReason(err)
+		logger.Info("Token verification by %s failed: %s", requestActor(c), reason)
+		return &tokenScan{reason: reason, data: tokenData}, nil
+	}
+	if tokenData.Purpose == utils.PurposeGuest {
+		return scanGuestPass(c, tokens, guests, logger, tokenData), nil
+	}
+
+	// Verification does not count as a view, but a used-up share link is reported
+	if tokenData.Purpose == utils.PurposeShare && tokenData.ID != "" {
+		if link := shareLinks.Get(tokenData.ID); link != nil && link.ViewsLeft() == 0 {
+			return &tokenScan{reason: services.TokenReasonExhausted, data: tokenData}, nil
+		}
+	}
+
+	// A missing member or an invalidated token becomes the scan's reason
+	user, err := tokens.Owner(cfg, tokenData)
+	if err != nil {
+		reason := services.TokenFailureReason(err)
+		logger.Info("Token %s verified by %s failed the owner check: %s", tokenData.ID, requestActor(c), reason)
+		return &tokenScan{reason: reason, data: tokenData}, nil
+	}
+
+	membershipInfo, err := services.NewMembershipService().GetMembershipInfo(user)
+	if err != nil {
+		logger.Error("Failed to retrieve membership info: %v", err)
+		return nil, err
+	}
+
+	logger.Info("Token %s of %s verified by %s", tokenData.ID, user.Email, requestActor(c))
+	return &tokenScan{data: tokenData, user: user, membership: membershipInfo}, nil
+}
+
+// scanGuestPass checks a verified guest token against its pass and records the guest's visit
+func scanGuestPass(c *gin.Context, tokens *services.TokenService, guests *services.GuestPassStore, logger *services.Logger, tokenData *utils.TokenData) *tokenScan {
+	pass, err := guests.Get(tokenData.ID)
+	if err != nil {
+		return &tokenScan{reason: services.GuestReasonNotFound, data: tokenData}
+	}
+
+	// Compact tokens carry no email, so the sponsor's "invalidate all" is checked against the pass
+	if tokens.CheckOwner(tokenData, &models.UserProfile{Email: pass.SponsorEmail}) != nil {
+		return &tokenScan{reason: services.TokenReasonRevoked, data: tokenData}
+	}
+
+	pass, err = guests.RecordVisit(tokenData.ID)
+	switch {
+	case errors.Is(err, services.ErrGuestPassNotFound):
+		return &tokenScan{reason: services.GuestReasonNotFound, data: tokenData}
+	case errors.Is(err, services.ErrGuestPassNotToday):
+		return &tokenScan{reason: services.GuestReasonNotToday, data: tokenData, guest: pass}
+	case err != nil:
+		logger.Error("Failed to record guest visit for pass %s: %v", tokenData.ID, err)
+	}
+
+	if pass.Visits == 1 {
+		logger.Audit("Guest %s visited on pass %s sponsored by %s, verified by %s", pass.GuestName, pass.ID, pass.SponsorEmail, requestActor(c))
+	}
+	return &tokenScan{data: tokenData, guest: pass}
+}
+
+// verifyResult builds the verification response; reason is empty for a valid token
+func verifyResult(reason string, data *utils.TokenData, user *models.UserProfile, membership *models.MembershipInfo) gin.H {
+	result := gin.H{"valid": reason == ""}
+	if reason != "" {
+		result["reason"] = reason
+	}
+
+	// Token details are only known once the signature checked out
+	if data != nil {
+		result["token"] = gin.H{
+			"id":         data.ID,
+			"purpose":    data.Purpose.String(),
+			"version":    data.Version,
+			"issued_at":  data.Timestamp,
+			"expires_at": data.ExpiresAt,
+		}
+	}
+
+	if user != nil && membership != nil {
+		member := gin.H{
+			"display_name":      user.GetFullName(),
+			"status":            strings.ToLower(membership.Status.String()),
+			"access_level":      user.AccessLevel,
+			"access_level_name": user.AccessLevel.String(),
+		}
+		if membership.ExpiryDate != nil {
+			member["membership_expires"] = membership.ExpiryDate.Format("2006-01-02")
+		}
+		result["member"] = member
+	}
+
+	return result
+}
+
+// requestActor names who made the request for logs: the signed-in user's email or the API key
+func requestActor(c *gin.Context) string {
+	if value, exists := c.Get("api_key"); exists {
+		return "API key " + value.(*models.APIKey).Name
+	}
+	return currentUserEmail(c)
+}
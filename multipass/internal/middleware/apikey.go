@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"errors"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyMiddleware authenticates machine clients presenting "Authorization: Bearer <key>"
+// Requests without a bearer token fall through to the regular user authentication
+func APIKeyMiddleware(store *services.APIKeyStore, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Only bearer credentials are handled here
+		authorization := c.GetHeader("Authorization")
+		scheme, plaintext, found := strings.Cut(authorization, " ")
+		if !found || !strings.EqualFold(scheme, "Bearer") {
+			c.Next()
+			return
+		}
+
+		key, err := store.Authenticate(strings.TrimSpace(plaintext))
+		if key != nil && err != nil {
+			logger.Error("Failed to record use of API key %s: %v", key.ID, err)
+		} else if err != nil {
+			logger.Audit("Rejected API key from %s: %v", c.ClientIP(), err)
+
+			message := "Invalid API key"
+			if errors.Is(err, services.ErrAPIKeyInactive) {
+				message = "API key revoked or expired"
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": message})
+			c.Abort()
+			return
+		}
+
+		// Store the key in context; AuthMiddleware skips requests that already carry one
+		c.Set("api_key", key)
+		c.Set("auth_method", "api_key")
+		c.Next()
+	}
+}
+
+// RequireScope ensures API keys hold the scope and people hold the capability
+func RequireScope(scope, capability string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Machine clients are limited to the scopes they were granted
+		if key, exists := c.Get("api_key"); exists {
+			if !key.(*models.APIKey).HasScope(scope) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "API key lacks required scope: " + scope})
+				c.Abort()
+				return
+			}
+
+			c.Next()
+			return
+		}
+
+		// Everyone else needs the capability
+		RequireCapability(capability)(c)
+	}
+}
+
+// RequireUser rejects API keys on routes that act on behalf of a signed-in person
+func RequireUser() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, exists := c.Get("api_key"); exists {
+			c.JSON(http.StatusForbidden, gin.H{"error": "This endpoint is not available to API keys"})
+			c.Abort()
+			return
+		}
+
+		RequireAuth()(c)
+	}
+}
@@ -7,84 +7,257 @@ import (
 	"multipass/internal/services"
 	"multipass/internal/utils"
 	"net/http"
+	"net/url"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-// AuthMiddleware extracts user information from Authentik reverse proxy headers
-func AuthMiddleware() gin.HandlerFunc {
+// cloudflareAccessHeader carries the Cloudflare Access application token
+const cloudflareAccessHeader = "Cf-Access-Jwt-Assertion"
+
+// Authenticator resolves the identity of a request according to the configured AUTH_MODE
+type Authenticator struct {
+	cfg         *config.Config
+	logger      *services.Logger
+	sessions    *services.SessionManager
+	jwtVerifier *services.JWTVerifier
+	cfVerifier  *services.JWTVerifier
+}
+
+// NewAuthenticator creates an authenticator; sessions may be nil when AUTH_MODE=proxy
+func NewAuthenticator(cfg *config.Config, sessions *services.SessionManager) *Authenticator {
+	auth := &Authenticator{
+		cfg:      cfg,
+		logger:   services.NewLogger(cfg),
+		sessions: sessions,
+	}
+
+	// Verify the outpost JWT against the provider's signing keys
+	if cfg.AuthMode == config.AuthModeJWT {
+		auth.jwtVerifier = services.NewJWTVerifier(cfg.AuthentikJWTIssuer, cfg.AuthentikJWTJWKSURL, cfg.AuthentikJWTAudience)
+	}
+
+	// Accept Cloudflare Access assertions when the application is configured
+	if cfg.CloudflareAccessEnabled() {
+		auth.cfVerifier = services.NewCloudflareAccessVerifier(cfg.CloudflareTeamDomain, cfg.CloudflareAudience)
+	}
+
+	return auth
+}
+
+// AuthMiddleware authenticates the request and stores the user profile in the context
+func AuthMiddleware(auth *Authenticator) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Extract user data from Authentik headers
-		email := c.GetHeader("X-Authentik-Email")
-		fullName := c.GetHeader("X-Authentik-Name")
-		groupsHeader := c.GetHeader("X-Authentik-Groups")
-		authentikUID := c.GetHeader("X-Authentik-Uid") // Extract the Authentik UID
-
-		// If no email, user is not authenticated
-		if email == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
-			c.Abort()
+		// Machine clients were already authenticated by APIKeyMiddleware
+		if _, exists := c.Get("api_key"); exists {
+			c.Next()
 			return
 		}
 
-		// Parse groups
-		var groups []string
-		if groupsHeader != "" {
-			// First try splitting by pipe, which is what we see in the actual headers
-			if strings.Contains(groupsHeader, "|") {
-				groups = strings.Split(groupsHeader, "|")
-			} else {
-				// Fall back to comma if no pipes found
-				groups = strings.Split(groupsHeader, ",")
-			}
+		// Refuse requests whose identity headers were stripped by TrustedProxyMiddleware
+		if auth.cfg.AuthMode == config.AuthModeProxy && c.GetBool("spoofed_identity") {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Identity headers are not accepted from untrusted sources"})
+			c.Abort()
+			return
+		}
 
-			// Trim whitespace from group names
-			for i, group := range groups {
-				groups[i] = strings.TrimSpace(group)
+		// A Cloudflare Access assertion takes precedence when present
+		if auth.cfVerifier != nil && c.GetHeader(cloudflareAccessHeader) != "" {
+			userProfile := auth.fromCloudflareAccess(c)
+			if userProfile == nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Cloudflare Access token"})
+				c.Abort()
+				return
 			}
 
-			// Log the parsed groups for debugging
-			fmt.Printf("[AUTH] Parsed groups: %v\n", groups)
+			// Resolve what the user may do from their level and groups
+			userProfile.Capabilities = models.ResolveCapabilities(userProfile.AccessLevel, userProfile.Groups, auth.cfg.GroupMappingConfig)
+			c.Set("user", userProfile)
+			c.Next()
+			return
 		}
 
-		// Generate Gravatar URL for the user's email
-		gravatarURL := utils.GenerateGravatarURL(email, 256, "identicon")
-
-		// Create initial user profile
-		userProfile := &models.UserProfile{
-			Email:       email,
-			FullName:    fullName,
-			Groups:      groups,
-			MemberID:    "TBD", // Will be updated when we fetch from Authentik API
-			AccessLevel: models.DetermineUserLevel(groups),
-			Avatar:      &gravatarURL,
-			AuthentikID: authentikUID,
+		// Resolve the identity from the source matching the auth mode
+		var userProfile *models.UserProfile
+		switch auth.cfg.AuthMode {
+		case config.AuthModeOIDC:
+			userProfile = auth.fromSession(c)
+		case config.AuthModeJWT:
+			userProfile = auth.fromJWT(c)
+		default:
+			userProfile = auth.fromHeaders(c)
 		}
 
-		// Try to get the user's PK from Authentik API
-		if email != "" {
-			// Create Authentik client using config
-			cfg := config.Load()
-			authentikClient := services.NewAuthentikClient(cfg)
-
-			// Look up user by email
-			apiUserProfile, err := authentikClient.GetUserByEmail(email)
-			if err == nil && apiUserProfile != nil {
-				// Update the Member ID with the PK from the API
-				userProfile.MemberID = apiUserProfile.MemberID
-				fmt.Printf("[AUTH] Updated Member ID to %s from Authentik API\n", apiUserProfile.MemberID)
-			} else if err != nil {
-				fmt.Printf("[AUTH] Error getting user from Authentik API: %v\n", err)
+		// If no identity, send browsers to sign in and give API clients JSON
+		if userProfile == nil {
+			if isBrowserRequest(c) {
+				c.Redirect(http.StatusFound, auth.signInURL(c))
+			} else {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
 			}
+			c.Abort()
+			return
 		}
 
+		// Try to get the user's PK from Authentik API
+		auth.lookupMemberID(userProfile)
+
+		// Resolve what the user may do from their level and groups
+		userProfile.Capabilities = models.ResolveCapabilities(userProfile.AccessLevel, userProfile.Groups, auth.cfg.GroupMappingConfig)
+
 		// Store user profile in context
 		c.Set("user", userProfile)
 		c.Next()
 	}
 }
 
+// fromHeaders extracts user information from the forward-auth proxy headers of the configured profile
+func (a *Authenticator) fromHeaders(c *gin.Context) *models.UserProfile {
+	// Normalize the headers of the configured profile
+	headerUser := models.ParseUserFromHeaders(c.Request.Header, a.cfg.HeaderProfile)
+
+	// If no email, user is not authenticated
+	if headerUser == nil {
+		return nil
+	}
+
+	// Log the parsed groups for debugging
+	if len(headerUser.Groups) > 0 {
+		fmt.Printf("[AUTH] Parsed groups: %v\n", headerUser.Groups)
+	}
+
+	return newUserProfile(headerUser.Email, headerUser.FullName, headerUser.UserID, headerUser.Groups)
+}
+
+// fromSession extracts user information from the encrypted OIDC session cookie
+func (a *Authenticator) fromSession(c *gin.Context) *models.UserProfile {
+	if a.sessions == nil {
+		return nil
+	}
+
+	session, err := a.sessions.Load(c.Request)
+	if err != nil || session.Email == "" {
+		return nil
+	}
+
+	return newUserProfile(session.Email, session.Name, session.UID, session.Groups)
+}
+
+// fromJWT extracts user information from the signed X-Authentik-Jwt header
+func (a *Authenticator) fromJWT(c *gin.Context) *models.UserProfile {
+	rawToken := c.GetHeader("X-Authentik-Jwt")
+	if rawToken == "" || a.jwtVerifier == nil {
+		return nil
+	}
+
+	identity, err := a.jwtVerifier.Verify(c.Request.Context(), rawToken)
+	if err != nil {
+		a.logger.Audit("Rejected X-Authentik-Jwt from %s: %v", c.ClientIP(), err)
+		return nil
+	}
+
+	if identity.Email == "" {
+		a.logger.Error("X-Authentik-Jwt for subject %s has no email claim", identity.Subject)
+		return nil
+	}
+
+	return newUserProfile(identity.Email, identity.Name, identity.Subject, identity.Groups)
+}
+
+// fromCloudflareAccess verifies the Cloudflare Access assertion and loads the user from Authentik by email
+func (a *Authenticator) fromCloudflareAccess(c *gin.Context) *models.UserProfile {
+	identity, err := a.cfVerifier.Verify(c.Request.Context(), c.GetHeader(cloudflareAccessHeader))
+	if err != nil {
+		a.logger.Audit("Rejected Cloudflare Access token from %s: %v", c.ClientIP(), err)
+		return nil
+	}
+
+	if identity.Email == "" {
+		a.logger.Error("Cloudflare Access token for subject %s has no email claim", identity.Subject)
+		return nil
+	}
+
+	// Cloudflare only vouches for the email; groups and IDs come from Authentik
+	authentikClient := services.NewAuthentikClient(a.cfg)
+	userProfile, err := authentikClient.GetUserByEmail(identity.Email)
+	if err != nil || userProfile == nil {
+		a.logger.Error("Cloudflare Access user %s not found in Authentik: %v", identity.Email, err)
+		return nil
+	}
+
+	// Fall back to Gravatar if Authentik has no avatar
+	if userProfile.Avatar == nil {
+		gravatarURL := utils.GenerateGravatarURL(userProfile.Email, 256, "identicon")
+		userProfile.Avatar = &gravatarURL
+	}
+
+	return userProfile
+}
+
+// signInURL returns where to send a browser to sign in, returning to the requested page afterwards
+func (a *Authenticator) signInURL(c *gin.Context) string {
+	// Native OIDC login only accepts local return paths
+	if a.cfg.AuthMode == config.AuthModeOIDC {
+		return "/login?rd=" + url.QueryEscape(c.Request.URL.RequestURI())
+	}
+
+	// The outpost expects an absolute return URL
+	returnURL := RequestBaseURL(c) + c.Request.URL.RequestURI()
+
+	return a.cfg.SignInURL + "?rd=" + url.QueryEscape(returnURL)
+}
+
+// isBrowserRequest returns true for page navigations, which should be redirected rather than get JSON
+func isBrowserRequest(c *gin.Context) bool {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		return false
+	}
+
+	// API routes and htmx fragments always get JSON
+	if strings.HasPrefix(c.Request.URL.Path, "/api/") || c.GetHeader("HX-Request") != "" {
+		return false
+	}
+
+	return strings.Contains(c.GetHeader("Accept"), "text/html")
+}
+
+// lookupMemberID replaces the placeholder member ID with the user's PK from the Authentik API
+func (a *Authenticator) lookupMemberID(userProfile *models.UserProfile) {
+	// Create Authentik client using config
+	authentikClient := services.NewAuthentikClient(a.cfg)
+
+	// Look up user by email
+	apiUserProfile, err := authentikClient.GetUserByEmail(userProfile.Email)
+	if err == nil && apiUserProfile != nil {
+		// Update the Member ID with the PK from the API
+		userProfile.MemberID = apiUserProfile.MemberID
+		fmt.Printf("[AUTH] Updated Member ID to %s from Authentik API\n", apiUserProfile.MemberID)
+	} else if err != nil {
+		fmt.Printf("[AUTH] Error getting user from Authentik API: %v\n", err)
+	}
+}
+
+// newUserProfile creates the initial user profile for an authenticated identity
+func newUserProfile(email, fullName, authentikUID string, groups []string) *models.UserProfile {
+	// Generate Gravatar URL for the user's email
+	gravatarURL := utils.GenerateGravatarURL(email, 256, "identicon")
+
+	// Only cert- groups are known here; the details in the certifications attribute need the Authentik API
+	certifications, _ := models.ParseCertifications(groups, nil)
+
+	return &models.UserProfile{
+		Email:          email,
+		FullName:       fullName,
+		Groups:         groups,
+		MemberID:       "TBD", // Will be updated when we fetch from Authentik API
+		AccessLevel:    models.DetermineUserLevel(groups),
+		Avatar:         &gravatarURL,
+		AuthentikID:    authentikUID,
+		Certifications: certifications,
+	}
+}
+
 // RequireAuth ensures user is authenticated
 func RequireAuth() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -127,3 +300,24 @@ func RequireLevel(minLevel models.UserLevel) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// RequireCapability ensures the user holds the named capability
+func RequireCapability(capability string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			c.Abort()
+			return
+		}
+
+		userProfile := user.(*models.UserProfile)
+		if !userProfile.Can(capability) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient privileges", "capability": capability})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"multipass/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthMiddleware_Unauthenticated(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Test cases
+	testCases := []struct {
+		name             string
+		authMode         string
+		path             string
+		accept           string
+		expectedStatus   int
+		expectedLocation string
+	}{
+		{
+			name:             "Browser is sent to the outpost",
+			authMode:         config.AuthModeProxy,
+			path:             "/card?tab=back",
+			accept:           "text/html,application/xhtml+xml",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "/outpost.goauthentik.io/start?rd=http%3A%2F%2Fmultipass.example.com%2Fcard%3Ftab%3Dback",
+		},
+		{
+			name:             "Browser is sent to the OIDC login",
+			authMode:         config.AuthModeOIDC,
+			path:             "/card",
+			accept:           "text/html",
+			expectedStatus:   http.StatusFound,
+			expectedLocation: "/login?rd=%2Fcard",
+		},
+		{
+			name:           "API client gets JSON",
+			authMode:       config.AuthModeProxy,
+			path:           "/api/v1/user",
+			accept:         "text/html",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Non-browser client gets JSON",
+			authMode:       config.AuthModeProxy,
+			path:           "/card",
+			accept:         "application/json",
+			expectedStatus: http.StatusUnauthorized,
+		},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{
+				AuthMode:      tc.authMode,
+				HeaderProfile: config.HeaderProfiles["authentik"],
+				SignInURL:     "/outpost.goauthentik.io/start",
+			}
+
+			r := gin.New()
+			r.Use(AuthMiddleware(NewAuthenticator(cfg, nil)))
+			r.GET("/card", func(c *gin.Context) { c.Status(http.StatusOK) })
+			r.GET("/api/v1/user", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			req := httptest.NewRequest(http.MethodGet, "http://multipass.example.com"+tc.path, nil)
+			req.Header.Set("Accept", tc.accept)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if location := w.Header().Get("Location"); location != tc.expectedLocation {
+				t.Errorf("Expected redirect to %q, got %q", tc.expectedLocation, location)
+			}
+		})
+	}
+}
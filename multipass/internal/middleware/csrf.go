@@ -0,0 +1,111 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"multipass/internal/config"
+	"multipass/internal/services"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// csrfCookieName holds the per-browser CSRF token
+	csrfCookieName = "multipass_csrf"
+
+	// CSRFHeaderName carries the token on fetch and htmx requests
+	CSRFHeaderName = "X-CSRF-Token"
+
+	// CSRFFormField carries the token on HTML form posts
+	CSRFFormField = "csrf_token"
+
+	// csrfTokenBytes is the amount of randomness in a token
+	csrfTokenBytes = 32
+
+	// apiKeyPathPrefix is where APIKeyMiddleware runs and rejects bearer credentials that are not valid keys
+	apiKeyPathPrefix = "/api/v1/"
+)
+
+// CSRFMiddleware implements double-submit CSRF protection when CSRF_ENABLED is set
+// Every response gets a token cookie, exposed to templates as "csrf_token"; state-changing
+// requests must echo it back in the X-CSRF-Token header or the csrf_token form field
+func CSRFMiddleware(cfg *config.Config, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !cfg.CSRFEnabled {
+			c.Next()
+			return
+		}
+
+		// Reuse the browser's token, or issue one if it has none
+		token, err := c.Cookie(csrfCookieName)
+		if err != nil || len(token) != base64.RawURLEncoding.EncodedLen(csrfTokenBytes) {
+			token, err = newCSRFToken()
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate CSRF token"})
+				c.Abort()
+				return
+			}
+
+			http.SetCookie(c.Writer, &http.Cookie{
+				Name:     csrfCookieName,
+				Value:    token,
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   !cfg.IsDevelopment(),
+				SameSite: http.SameSiteStrictMode,
+			})
+		}
+
+		// Make the token available to templates
+		c.Set("csrf_token", token)
+
+		// Safe methods never change state
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+
+		// Browsers never attach Authorization headers on their own, so bearer-key requests cannot be forged
+		// Elsewhere nothing checks the header, and a junk one must not switch the check off
+		if isAPIKeyRequest(c) {
+			c.Next()
+			return
+		}
+
+		// The submitted token must match the cookie
+		submitted := c.GetHeader(CSRFHeaderName)
+		if submitted == "" {
+			submitted = c.PostForm(CSRFFormField)
+		}
+		if submitted == "" || subtle.ConstantTimeCompare([]byte(submitted), []byte(token)) != 1 {
+			logger.Audit("Rejected %s %s from %s: missing or invalid CSRF token", c.Request.Method, c.Request.URL.Path, c.ClientIP())
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid CSRF token"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// isAPIKeyRequest returns true if the request carries a bearer credential to a route that only accepts it as a valid API key
+func isAPIKeyRequest(c *gin.Context) bool {
+	if !strings.HasPrefix(c.Request.URL.Path, apiKeyPathPrefix) {
+		return false
+	}
+	scheme, _, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	return found && strings.EqualFold(scheme, "Bearer")
+}
+
+// newCSRFToken returns a random URL-safe token
+func newCSRFToken() (string, error) {
+	b := make([]byte, csrfTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
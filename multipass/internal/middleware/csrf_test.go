@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"multipass/internal/config"
+	"multipass/internal/services"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newCSRFTestRouter returns a router with CSRF protection and a GET and POST route
+func newCSRFTestRouter(enabled bool) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{CSRFEnabled: enabled, Environment: "development"}
+
+	r := gin.New()
+	r.Use(CSRFMiddleware(cfg, services.NewLogger(cfg)))
+	r.GET("/form", func(c *gin.Context) { c.String(http.StatusOK, c.GetString("csrf_token")) })
+	r.POST("/submit", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	r.POST("/api/v1/submit", func(c *gin.Context) { c.Status(http.StatusNoContent) })
+	return r
+}
+
+func TestCSRFMiddleware(t *testing.T) {
+	r := newCSRFTestRouter(true)
+
+	// A GET issues the token cookie and exposes the same token to templates
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/form", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != csrfCookieName {
+		t.Fatalf("Expected CSRF cookie, got %v", cookies)
+	}
+	token := cookies[0].Value
+	if w.Body.String() != token {
+		t.Fatalf("Expected template token %q, got %q", token, w.Body.String())
+	}
+
+	// Test cases
+	testCases := []struct {
+		name     string
+		path     string
+		header   string
+		form     string
+		bearer   bool
+		cookie   bool
+		expected int
+	}{
+		{
+			name:     "Header matches cookie",
+			header:   token,
+			cookie:   true,
+			expected: http.StatusNoContent,
+		},
+		{
+			name:     "Form field matches cookie",
+			form:     token,
+			cookie:   true,
+			expected: http.StatusNoContent,
+		},
+		{
+			name:     "Missing token",
+			cookie:   true,
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "Wrong token",
+			header:   "forged",
+			cookie:   true,
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "Token without cookie",
+			header:   token,
+			cookie:   false,
+			expected: http.StatusForbidden,
+		},
+		{
+			name:     "Bearer key is exempt on the API",
+			path:     "/api/v1/submit",
+			bearer:   true,
+			expected: http.StatusNoContent,
+		},
+		{
+			name:     "Bearer header does not exempt browser routes",
+			bearer:   true,
+			cookie:   true,
+			expected: http.StatusForbidden,
+		},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			body := url.Values{}
+			if tc.form != "" {
+				body.Set(CSRFFormField, tc.form)
+			}
+
+			path := tc.path
+			if path == "" {
+				path = "/submit"
+			}
+
+			req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tc.header != "" {
+				req.Header.Set(CSRFHeaderName, tc.header)
+			}
+			if tc.cookie {
+				req.AddCookie(&http.Cookie{Name: csrfCookieName, Value: token})
+			}
+			if tc.bearer {
+				req.Header.Set("Authorization", "Bearer mpk_abc_secret")
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != tc.expected {
+				t.Errorf("Expected status %d, got %d", tc.expected, w.Code)
+			}
+		})
+	}
+}
+
+func TestCSRFMiddleware_Disabled(t *testing.T) {
+	r := newCSRFTestRouter(false)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/submit", nil))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("Expected POST to pass with CSRF disabled, got %d", w.Code)
+	}
+}
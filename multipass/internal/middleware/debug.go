@@ -2,15 +2,15 @@ package middleware
 
 import (
 	"multipass/internal/config"
+	"multipass/internal/models"
 	"multipass/internal/services"
 	"multipass/internal/utils"
 	"os"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
-// DebugAuthMiddleware adds mock Authentik headers for local development and testing
+// DebugAuthMiddleware adds mock identity headers for local development and testing
 // and collects debug information to display in templates
 func DebugAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -22,31 +22,35 @@ func DebugAuthMiddleware() gin.HandlerFunc {
 		// Create logger instance
 		logger := services.NewLogger(cfg)
 
-		if cfg.IsDevelopment() && c.GetHeader("X-Authentik-Email") == "" {
-			// Add mock Authentik headers for testing
+		// Header names of the configured forward-auth profile
+		profile := cfg.HeaderProfile
+
+		if cfg.IsDevelopment() && c.GetHeader(profile.EmailHeader) == "" {
+			// Add mock identity headers for testing
 			testEmail := "test.user@example.com"
-			c.Request.Header.Set("X-Authentik-Email", testEmail)
-			c.Request.Header.Set("X-Authentik-Name", "Test User")
+			c.Request.Header.Set(profile.EmailHeader, testEmail)
+			if profile.NameHeader != "" {
+				c.Request.Header.Set(profile.NameHeader, "Test User")
+			}
 
 			// Use the Members group from group_mapping.yaml
-			c.Request.Header.Set("X-Authentik-Groups", "Members")
+			c.Request.Header.Set(profile.GroupsHeader, "Members")
 
 			// Generate Gravatar URL for test user
 			gravatarURL := utils.GenerateGravatarURL(testEmail, 256, "identicon")
 			c.Set("debug_avatar", gravatarURL)
 
-			logger.Debug("Added mock Authentik headers for path: %s", c.Request.URL.Path)
-		} else if c.GetHeader("X-Authentik-Email") != "" {
+			logger.Debug("Added mock %s headers for path: %s", profile.Name, c.Request.URL.Path)
+		} else if c.GetHeader(profile.EmailHeader) != "" {
 			logger.Debug("Headers already present, skipping debug middleware")
 		}
 
 		// Always collect debug information if debug mode is enabled
 		if debugMode || cfg.IsDevelopment() {
-			// Collect all headers for debugging
-			debugInfo := map[string]string{
-				"X-Authentik-Email":  c.GetHeader("X-Authentik-Email"),
-				"X-Authentik-Name":   c.GetHeader("X-Authentik-Name"),
-				"X-Authentik-Groups": c.GetHeader("X-Authentik-Groups"),
+			// Collect all profile headers for debugging
+			debugInfo := map[string]string{}
+			for _, header := range profile.Headers() {
+				debugInfo[header] = c.GetHeader(header)
 			}
 
 			// Add debug info to context for templates
@@ -54,29 +58,18 @@ func DebugAuthMiddleware() gin.HandlerFunc {
 			c.Set("debug_headers", debugInfo)
 
 			// Log debug information
-			logger.Debug("Headers: Email=%s, Name=%s, Groups=%s",
-				debugInfo["X-Authentik-Email"],
-				debugInfo["X-Authentik-Name"],
-				debugInfo["X-Authentik-Groups"])
+			logger.Debug("Headers (%s): Email=%s, Name=%s, Groups=%s", profile.Name,
+				debugInfo[profile.EmailHeader],
+				debugInfo[profile.NameHeader],
+				debugInfo[profile.GroupsHeader])
 
 			// Parse groups for debugging
-			groups := []string{}
-			if groupsHeader := debugInfo["X-Authentik-Groups"]; groupsHeader != "" {
-				// First try splitting by pipe, which is what we see in the actual headers
-				if strings.Contains(groupsHeader, "|") {
-					groups = strings.Split(groupsHeader, "|")
-				} else {
-					// Fall back to comma if no pipes found
-					groups = strings.Split(groupsHeader, ",")
-				}
-
-				// Trim spaces from each group
-				for i, group := range groups {
-					groups[i] = strings.TrimSpace(group)
-				}
-
+			groups := models.ParseGroups(debugInfo[profile.GroupsHeader], profile.GroupsDelimiter)
+			if len(groups) > 0 {
 				// Log the parsed groups
 				logger.Debug("Parsed groups: %v", groups)
+			} else {
+				groups = []string{}
 			}
 			c.Set("debug_groups", groups)
 		}
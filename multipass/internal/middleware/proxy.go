@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"multipass/internal/services"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrustedProxyMiddleware removes identity headers from requests that did not arrive through
+// a trusted proxy, so they can never be used to claim an identity
+func TrustedProxyMiddleware(trust *services.ProxyTrust, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if trust.IsTrusted(c.Request) {
+			c.Set("trusted_proxy", true)
+			c.Next()
+			return
+		}
+
+		// Strip any identity headers the untrusted peer tried to send
+		stripped := trust.StripIdentityHeaders(c.Request.Header)
+		if len(stripped) > 0 {
+			logger.Audit("Rejected identity headers %v from untrusted peer %s on %s %s",
+				stripped, c.Request.RemoteAddr, c.Request.Method, c.Request.URL.Path)
+
+			// Flag the request so AuthMiddleware can refuse it explicitly
+			c.Set("spoofed_identity", true)
+		}
+
+		c.Set("trusted_proxy", false)
+		c.Next()
+	}
+}
+
+// RequestBaseURL returns the scheme and host the request was made to, for absolute links and QR codes
+// Behind the TLS-terminating proxy the scheme comes from X-Forwarded-Proto, which is only believed from a trusted proxy
+func RequestBaseURL(c *gin.Context) string {
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	} else if proto := strings.ToLower(c.GetHeader("X-Forwarded-Proto")); (proto == "http" || proto == "https") && c.GetBool("trusted_proxy") {
+		scheme = proto
+	}
+	return scheme + "://" + c.Request.Host
+}
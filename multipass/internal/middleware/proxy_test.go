@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestBaseURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Test cases
+	testCases := []struct {
+		name         string
+		tls          bool
+		forwarded    string
+		trustedProxy bool
+		expected     string
+	}{
+		{
+			name:     "Plain HTTP",
+			expected: "http://id.example.com",
+		},
+		{
+			name:     "Direct TLS",
+			tls:      true,
+			expected: "https://id.example.com",
+		},
+		{
+			name:         "TLS terminated at a trusted proxy",
+			forwarded:    "https",
+			trustedProxy: true,
+			expected:     "https://id.example.com",
+		},
+		{
+			name:      "Forwarded scheme from an untrusted peer is ignored",
+			forwarded: "https",
+			expected:  "http://id.example.com",
+		},
+		{
+			name:         "Unknown forwarded scheme is ignored",
+			forwarded:    "javascript",
+			trustedProxy: true,
+			expected:     "http://id.example.com",
+		},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "http://id.example.com/card", nil)
+			if tc.tls {
+				c.Request.TLS = &tls.ConnectionState{}
+			}
+			if tc.forwarded != "" {
+				c.Request.Header.Set("X-Forwarded-Proto", tc.forwarded)
+			}
+			c.Set("trusted_proxy", tc.trustedProxy)
+
+			if got := RequestBaseURL(c); got != tc.expected {
+				t.Errorf("Expected %q, got %q", tc.expected, got)
+			}
+		})
+	}
+}
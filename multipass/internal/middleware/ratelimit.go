@@ -0,0 +1,74 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"math"
+	"multipass/internal/models"
+	"multipass/internal/services"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RateLimitKeyFunc returns the key a request is limited by, or "" to skip limiting
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// RateLimitMiddleware rejects requests with 429 once the key's budget in the group is spent
+// The group and dimension only label the throttling counters; a nil limiter disables limiting
+func RateLimitMiddleware(limiter *services.RateLimiter, stats *services.RateLimitStats, group, dimension string, key RateLimitKeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limiter == nil {
+			c.Next()
+			return
+		}
+
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+
+		// Buckets of different dimensions share the limiter, so keep their keys apart
+		allowed, retryAfter := limiter.Allow(dimension + ":" + k)
+		if !allowed {
+			stats.Throttled(group, dimension)
+
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// ClientIPKey limits by client IP, resolved from X-Forwarded-For only when set by a trusted proxy
+func ClientIPKey(c *gin.Context) string {
+	return c.ClientIP()
+}
+
+// UserKey limits by the authenticated user or API key; it must run after authentication
+func UserKey(c *gin.Context) string {
+	if key, exists := c.Get("api_key"); exists {
+		return "key:" + key.(*models.APIKey).ID
+	}
+	if user, exists := c.Get("user"); exists {
+		return "user:" + user.(*models.UserProfile).Email
+	}
+	return ""
+}
+
+// TokenKey limits by the card token in the path or query string, so a single token cannot be hammered
+func TokenKey(c *gin.Context) string {
+	token := RequestToken(c)
+	if token == "" {
+		return ""
+	}
+
+	// Hash the token so live tokens are not kept in memory
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:16])
+}
@@ -1,22 +1,24 @@
 package middleware
 
 import (
+	"errors"
 	"multipass/internal/config"
 	"multipass/internal/models"
 	"multipass/internal/services"
 	"multipass/internal/utils"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 )
 
 // TokenAuthMiddleware validates a token in the URL and sets the user profile in the context
 // This middleware is used for public routes that need user information without authentication
-func TokenAuthMiddleware() gin.HandlerFunc {
+// The token is taken from the :token path parameter (/c/<token>) or the token query parameter
+// Share tokens are counted against the view limit recorded in shareLinks
+func TokenAuthMiddleware(tokens *services.TokenService, shareLinks *services.ShareLinkStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Get token from query parameter
-		token := c.Query("token")
+		// Get token from the path or query parameter
+		token := RequestToken(c)
 		if token == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token required"})
 			c.Abort()
@@ -28,16 +30,14 @@ func TokenAuthMiddleware() gin.HandlerFunc {
 		// Create logger
 		logger := services.NewLogger(cfg)
 
-		// Check if token secret is configured
-		if cfg.TokenSecret == "" {
-			logger.Error("Warning: TOKEN_SECRET is not configured")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Token verification not configured"})
+		// Verify token; card, share and live card tokens open the public card
+		tokenData, err := tokens.Verify(token, utils.PurposeCard, utils.PurposeShare, utils.PurposeLive)
+		if errors.Is(err, services.ErrTokenRevoked) {
+			logger.Info("Revoked token %s presented for %s", tokenData.ID, tokenData.Email)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
 			c.Abort()
 			return
 		}
-
-		// Verify token
-		tokenData, err := utils.VerifyToken(token, cfg.TokenSecret)
 		if err != nil {
 			logger.Error("Token verification failed: %v", err)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
@@ -45,98 +45,100 @@ func TokenAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		// Create Authentik client
-		authentikClient := services.NewAuthentikClient(cfg)
-
-		// Try to get user by ID first if it looks like a numeric ID
-		var userProfile *models.UserProfile
-		if tokenData.UserID != "" {
-			// Only try GetUserByID if the ID looks numeric to avoid unnecessary 404s
-			_, err := strconv.Atoi(tokenData.UserID)
-			if err == nil {
-				// ID is numeric, try to get user by ID
-				userProfile, err = authentikClient.GetUserByID(tokenData.UserID)
-				if err != nil {
-					logger.Debug("Failed to get user by ID: %v", err)
-					// Fall back to email lookup
-				}
-			} else {
-				// ID is not numeric, skip the ID lookup to avoid 404
-				logger.Debug("UserID %s is not numeric, skipping ID lookup", tokenData.UserID)
-			}
+		// The card is only shown while its member exists and has not invalidated the token
+		userProfile, err := tokens.Owner(cfg, tokenData)
+		if errors.Is(err, services.ErrTokenRevoked) {
+			logger.Info("Token %s issued before its owner's token epoch", tokenData.ID)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			logger.Error("Failed to get user for token: %v", err)
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+			c.Abort()
+			return
 		}
 
-		// If user not found by ID, try by email
-		if userProfile == nil && tokenData.Email != "" {
-			userProfile, err = authentikClient.GetUserByEmail(tokenData.Email)
-			if err != nil {
-				logger.Error("Failed to get user by email: %v", err)
-				c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		// Count the view of a share link and enforce its limit, once the link is known to still be good
+		var shareLink *models.ShareLink
+		if tokenData.Purpose == utils.PurposeShare {
+			shareLink, err = shareLinks.RecordView(tokenData.ID, tokenData.Timestamp)
+			if errors.Is(err, services.ErrShareLinkExhausted) {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "This link has reached its view limit"})
 				c.Abort()
 				return
 			}
-		}
-
-		// If still no user found, return error
-		if userProfile == nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
-			c.Abort()
-			return
+			if errors.Is(err, services.ErrShareLinkNotFound) {
+				logger.Info("Share token %s for %s has no recorded link", tokenData.ID, tokenData.Email)
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+				c.Abort()
+				return
+			}
+			if err != nil {
+				logger.Error("Failed to record share link view: %v", err)
+			}
 		}
 
 		// Set user profile in context
 		c.Set("user", userProfile)
 		c.Set("token_auth", true) // Flag to indicate token-based authentication
+		c.Set("token_data", tokenData)
+		c.Set("token", token)
+		if shareLink != nil {
+			c.Set("share_link", shareLink)
+		}
 
 		c.Next()
 	}
 }
 
-// GenerateTokenHandler creates a secure token for the authenticated user
-func GenerateTokenHandler(c *gin.Context) {
-	// Get user profile from context
-	userProfile, exists := c.Get("user")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
-		return
+// RequestToken returns the token from the :token path parameter, falling back to the token query parameter
+func RequestToken(c *gin.Context) string {
+	if token := c.Param("token"); token != "" {
+		return token
 	}
+	return c.Query("token")
+}
 
-	// Cast to UserProfile
-	user, ok := userProfile.(*models.UserProfile)
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
-		return
-	}
+// GenerateTokenHandler creates a secure token for the authenticated user
+// The token is recorded as a full-card share link without a view limit
+// With Shlink configured the response also carries a short URL; short_url falls back to the long URL
+func GenerateTokenHandler(tokens *services.TokenService, shareLinks *services.ShareLinkStore, shlink *services.ShlinkClient, logger *services.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Get user profile from context
+		userProfile, exists := c.Get("user")
+		if !exists {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authentication required"})
+			return
+		}
 
-	// Get config
-	cfg := config.Load()
+		// Cast to UserProfile
+		user, ok := userProfile.(*models.UserProfile)
+		if !ok {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Invalid user data"})
+			return
+		}
 
-	// Check if token secret is configured
-	if cfg.TokenSecret == "" {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Token generation not configured"})
-		return
-	}
+		// Generate token; members with a numeric member ID get a compact token
+		token, link, err := shareLinks.Issue(tokens, user, models.ShareScopeFull, utils.TokenValidityDuration, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+			return
+		}
 
-	// Get user ID from Authentik - use the numeric PK for consistent identification
-	userID := user.MemberID // Use MemberID which is now set to the numeric PK
-	// Fall back to AuthentikID or email if MemberID is not available
-	if userID == "" || userID == "TBD" {
-		userID = user.AuthentikID
-		if userID == "" {
-			userID = user.Email
+		// Shlink needs an absolute URL to redirect to, and clients get the same one
+		cardURL := RequestBaseURL(c) + "/c/" + token
+		shortURL, err := shareLinks.Shorten(shlink, link.ID, cardURL)
+		if err != nil {
+			logger.Error("Failed to shorten share link %s, using the long URL: %v", link.ID, err)
 		}
-	}
 
-	// Generate token
-	token, err := utils.GenerateToken(userID, user.Email, cfg.TokenSecret)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
-		return
+		// Return token
+		c.JSON(http.StatusOK, gin.H{
+			"token":     token,
+			"url":       cardURL,
+			"short_url": shortURL,
+		})
 	}
-
-	// Return token
-	c.JSON(http.StatusOK, gin.H{
-		"token": token,
-		"url":   c.Request.Host + "/public/card?token=" + token,
-	})
 }
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Credential types a door controller may present
+const (
+	CredentialQR   = "qr"   // A scanned card or live code, or its URL
+	CredentialRFID = "rfid" // The UID of a 13.56 MHz card or fob
+)
+
+// Reasons for an access decision, besides the token failure reasons of the verification API
+const (
+	AccessReasonAllowed           = "allowed"
+	AccessReasonUnknownDoor       = "unknown_door"          // The door is not in ACCESS_DOORS
+	AccessReasonUnknownCredential = "unknown_credential"    // A card UID not bound to any member
+	AccessReasonInactive          = "membership_inactive"   // Suspended, expired or inactive membership
+	AccessReasonLevel             = "insufficient_level"    // Below the door's minimum level
+	AccessReasonUnavailable       = "directory_unavailable" // Authentik was too slow and nothing recent was cached
+)
+
+// AccessDecision is one answer given to a door controller, as kept in the audit log
+type AccessDecision struct {
+	ID             string    `json:"id"`
+	Door           string    `json:"door"`
+	CredentialType string    `json:"credential_type"`
+	CredentialID   string    `json:"credential_id,omitempty"` // Token ID or card UID; bare tokens are never logged
+	Email          string    `json:"email,omitempty"`
+	MemberName     string    `json:"member_name,omitempty"`
+	Allowed        bool      `json:"allowed"`
+	Reason         string    `json:"reason"`
+	UnlockSeconds  int       `json:"unlock_seconds,omitempty"`
+	CacheAge       int       `json:"cache_age,omitempty"` // Seconds since the member was looked up in Authentik
+	DecidedFor     string    `json:"decided_for"`         // API key or staff member that asked
+	At             time.Time `json:"at"`
+}
+
+// DecideAccess decides whether a member whose credential checked out may open a door that requires minLevel
+// Nobody without workspace access is let in, even through a door that requires no level
+func DecideAccess(user *UserProfile, membership *MembershipInfo, minLevel UserLevel, now time.Time) (bool, string) {
+	// The same effective status the front desk scanner shows
+	if _, status, _ := EvaluateMembership(user, membership, now); status != StatusActive {
+		return false, AccessReasonInactive
+	}
+	if user.AccessLevel == NoAccess || user.AccessLevel < minLevel {
+		return false, AccessReasonLevel
+	}
+	return true, AccessReasonAllowed
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDecideAccess(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	yesterday := now.Add(-24 * time.Hour)
+
+	// Test cases
+	tests := []struct {
+		name            string
+		level           UserLevel
+		membership      MembershipInfo
+		minLevel        UserLevel
+		expectedAllowed bool
+		expectedReason  string
+	}{
+		{"Member at a member door", FullMember, MembershipInfo{Status: StatusActive}, FullMember, true, AccessReasonAllowed},
+		{"Staff at a member door", Staff, MembershipInfo{Status: StatusActive}, FullMember, true, AccessReasonAllowed},
+		{"Volunteer at a member door", LimitedVolunteer, MembershipInfo{Status: StatusActive}, FullMember, false, AccessReasonLevel},
+		{"Volunteer at the front door", LimitedVolunteer, MembershipInfo{Status: StatusActive}, LimitedVolunteer, true, AccessReasonAllowed},
+		{"No access at an open door", NoAccess, MembershipInfo{Status: StatusActive}, NoAccess, false, AccessReasonLevel},
+		{"Suspended member", Admin, MembershipInfo{Status: StatusSuspended}, FullMember, false, AccessReasonInactive},
+		{"Past expiry date", FullMember, MembershipInfo{Status: StatusActive, ExpiryDate: &yesterday}, FullMember, false, AccessReasonInactive},
+	}
+
+	// Run tests
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			user := &UserProfile{Email: "member@example.com", AccessLevel: tc.level}
+			allowed, reason := DecideAccess(user, &tc.membership, tc.minLevel, now)
+			if allowed != tc.expectedAllowed || reason != tc.expectedReason {
+				t.Errorf("Expected %v (%s), got %v (%s)", tc.expectedAllowed, tc.expectedReason, allowed, reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// API key scopes granted to machine clients
+const (
+	ScopeVerifyRead   = "verify:read"   // Verify member tokens
+	ScopeCheckinWrite = "checkin:write" // Record check-ins
+	ScopeMembersRead  = "members:read"  // Look up member information
+	ScopeAccessDecide = "access:decide" // Ask whether a door may be unlocked
+)
+
+// ValidScopes lists every scope an API key may be granted
+var ValidScopes = []string{
+	ScopeVerifyRead,
+	ScopeCheckinWrite,
+	ScopeMembersRead,
+	ScopeAccessDecide,
+}
+
+// IsValidScope returns true if the scope is known
+func IsValidScope(scope string) bool {
+	for _, valid := range ValidScopes {
+		if scope == valid {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKey is a hashed, revocable credential for machine clients such as kiosks and door readers
+type APIKey struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Hash       string     `json:"hash"` // SHA-256 of the full key; the key itself is never stored
+	Scopes     []string   `json:"scopes"`
+	CreatedBy  string     `json:"created_by"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy  string     `json:"revoked_by,omitempty"`
+}
+
+// HasScope returns true if the key was granted the scope
+func (k *APIKey) HasScope(scope string) bool {
+	for _, granted := range k.Scopes {
+		if granted == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsActive returns true if the key is neither revoked nor expired
+func (k *APIKey) IsActive(now time.Time) bool {
+	if k.RevokedAt != nil {
+		return false
+	}
+	if k.ExpiresAt != nil && now.After(*k.ExpiresAt) {
+		return false
+	}
+	return true
+}
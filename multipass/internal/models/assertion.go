@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// MembershipAssertion is the membership state multipass signs so door controllers and partner spaces can check it offline
+// The JSON tags are the private claims of the signed JWT; the registered claims fill the json:"-" fields
+type MembershipAssertion struct {
+	MemberID          string    `json:"-"`                            // "sub" claim: Authentik member ID
+	Status            string    `json:"status"`                       // Lower-case membership status, e.g. "active"
+	Level             UserLevel `json:"level"`                        // Numeric access level
+	LevelName         string    `json:"level_name"`                   // Access level name, e.g. "Full Member"
+	MembershipExpires string    `json:"membership_expires,omitempty"` // Membership expiry date (YYYY-MM-DD), when known
+	ID                string    `json:"-"`                            // "jti" claim
+	IssuedAt          time.Time `json:"-"`                            // "iat" claim
+	ExpiresAt         time.Time `json:"-"`                            // "exp" claim; how long the assertion may be trusted
+}
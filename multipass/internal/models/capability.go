@@ -0,0 +1,128 @@
+package models
+
+import (
+	"multipass/internal/config"
+	"sort"
+)
+
+// Capabilities are named permissions checked by routes instead of comparing access levels
+const (
+	CapCardView      = "card.view"      // View one's own digital ID card
+	CapCardShare     = "card.share"     // Create shareable links to one's card
+	CapMembersSearch = "members.search" // Look up other members
+	CapTokensRevoke  = "tokens.revoke"  // Revoke card tokens and share links
+	CapAPIKeysManage = "apikeys.manage" // Mint and revoke API keys
+	CapAdminConfig   = "admin.config"   // Change application configuration
+	CapGuestsIssue   = "guests.issue"   // Issue guest passes within the level's monthly quota
+	CapRFIDManage    = "rfid.manage"    // Bind RFID cards and fobs to members
+	CapDevicesReset  = "devices.reset"  // List and reset members' device-bound browsers
+)
+
+// AllCapabilities lists every capability known to multipass
+var AllCapabilities = []string{
+	CapCardView,
+	CapCardShare,
+	CapMembersSearch,
+	CapTokensRevoke,
+	CapAPIKeysManage,
+	CapAdminConfig,
+	CapGuestsIssue,
+	CapRFIDManage,
+	CapDevicesReset,
+}
+
+// DefaultLevelCapabilities is used for any level without an entry under capabilities.levels
+var DefaultLevelCapabilities = map[UserLevel][]string{
+	NoAccess:         {},
+	LimitedVolunteer: {CapCardView},
+	FullMember:       {CapCardView, CapCardShare, CapGuestsIssue},
+	Staff:            {CapCardView, CapCardShare, CapGuestsIssue, CapMembersSearch, CapAPIKeysManage, CapRFIDManage, CapDevicesReset},
+	Admin:            {CapCardView, CapCardShare, CapGuestsIssue, CapMembersSearch, CapAPIKeysManage, CapRFIDManage, CapDevicesReset, CapTokensRevoke, CapAdminConfig},
+}
+
+// IsValidCapability returns true if the capability is known
+func IsValidCapability(capability string) bool {
+	for _, known := range AllCapabilities {
+		if capability == known {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveCapabilities returns the sorted capabilities granted by the user's level and groups
+func ResolveCapabilities(level UserLevel, groups []string, mapping *config.GroupMappingConfig) []string {
+	granted := make(map[string]bool)
+
+	// Start with the capabilities of the access level
+	levelCaps, configured := levelCapabilities(level, mapping)
+	if !configured {
+		levelCaps = DefaultLevelCapabilities[level]
+	}
+	for _, capability := range levelCaps {
+		granted[capability] = true
+	}
+
+	// Add capabilities granted directly to the user's groups
+	if mapping != nil {
+		for _, group := range groups {
+			for _, capability := range mapping.Capabilities.Groups[group] {
+				granted[capability] = true
+			}
+		}
+	}
+
+	capabilities := make([]string, 0, len(granted))
+	for capability := range granted {
+		capabilities = append(capabilities, capability)
+	}
+	sort.Strings(capabilities)
+	return capabilities
+}
+
+// UnknownCapabilities returns configured capability names that multipass does not know, to catch typos
+func UnknownCapabilities(mapping *config.GroupMappingConfig) []string {
+	if mapping == nil {
+		return nil
+	}
+
+	var unknown []string
+	check := func(capabilities []string) {
+		for _, capability := range capabilities {
+			if !IsValidCapability(capability) {
+				unknown = append(unknown, capability)
+			}
+		}
+	}
+	for _, capabilities := range mapping.Capabilities.Levels {
+		check(capabilities)
+	}
+	for _, capabilities := range mapping.Capabilities.Groups {
+		check(capabilities)
+	}
+	return unknown
+}
+
+// levelCapabilities looks up the configured capabilities for a level
+func levelCapabilities(level UserLevel, mapping *config.GroupMappingConfig) ([]string, bool) {
+	if mapping == nil {
+		return nil, false
+	}
+
+	for name, capabilities := range mapping.Capabilities.Levels {
+		if parsed, ok := ParseUserLevel(name); ok && parsed == level {
+			return capabilities, true
+		}
+	}
+	return nil, false
+}
+
+// Can returns true if the user holds the capability
+func (u *UserProfile) Can(capability string) bool {
+	for _, granted := range u.Capabilities {
+		if granted == capability {
+			return true
+		}
+	}
+	return false
+}
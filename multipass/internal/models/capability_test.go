@@ -0,0 +1,105 @@
+package models
+
+import (
+	"multipass/internal/config"
+	"reflect"
+	"testing"
+)
+
+func TestResolveCapabilities(t *testing.T) {
+	mapping := &config.GroupMappingConfig{
+		Capabilities: config.CapabilityConfig{
+			Levels: map[string][]string{
+				"Staff": {CapCardView, CapMembersSearch},
+			},
+			Groups: map[string][]string{
+				"door-admins": {CapTokensRevoke},
+			},
+		},
+	}
+
+	// Test cases
+	testCases := []struct {
+		name     string
+		level    UserLevel
+		groups   []string
+		mapping  *config.GroupMappingConfig
+		expected []string
+	}{
+		{
+			name:     "Configured level",
+			level:    Staff,
+			groups:   []string{"staff"},
+			mapping:  mapping,
+			expected: []string{CapCardView, CapMembersSearch},
+		},
+		{
+			name:     "Group grant adds to level",
+			level:    Staff,
+			groups:   []string{"staff", "door-admins"},
+			mapping:  mapping,
+			expected: []string{CapCardView, CapMembersSearch, CapTokensRevoke},
+		},
+		{
+			name:     "Unconfigured level uses defaults",
+			level:    FullMember,
+			groups:   []string{"Members"},
+			mapping:  mapping,
+			expected: []string{CapCardShare, CapCardView, CapGuestsIssue},
+		},
+		{
+			name:     "Unconfigured staff can reset devices but not revoke tokens",
+			level:    Staff,
+			mapping:  nil,
+			expected: []string{CapAPIKeysManage, CapCardShare, CapCardView, CapDevicesReset, CapGuestsIssue, CapMembersSearch, CapRFIDManage},
+		},
+		{
+			name:     "No access",
+			level:    NoAccess,
+			mapping:  nil,
+			expected: []string{},
+		},
+		{
+			name:     "Group grant without level access",
+			level:    NoAccess,
+			groups:   []string{"door-admins"},
+			mapping:  mapping,
+			expected: []string{CapTokensRevoke},
+		},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := ResolveCapabilities(tc.level, tc.groups, tc.mapping)
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestUnknownCapabilities(t *testing.T) {
+	mapping := &config.GroupMappingConfig{
+		Capabilities: config.CapabilityConfig{
+			Levels: map[string][]string{"Admin": {CapAdminConfig, "admin.everything"}},
+			Groups: map[string][]string{"front-desk": {"member.search"}},
+		},
+	}
+
+	unknown := UnknownCapabilities(mapping)
+	if len(unknown) != 2 {
+		t.Errorf("Expected 2 unknown capabilities, got %v", unknown)
+	}
+}
+
+func TestUserProfile_Can(t *testing.T) {
+	user := &UserProfile{Capabilities: []string{CapCardView}}
+
+	if !user.Can(CapCardView) {
+		t.Error("Expected user to hold card.view")
+	}
+	if user.Can(CapAdminConfig) {
+		t.Error("Expected user not to hold admin.config")
+	}
+}
@@ -0,0 +1,141 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// CertificationGroupPrefix marks Authentik groups whose members are certified on a tool or area, e.g. "cert-laser"
+const CertificationGroupPrefix = "cert-"
+
+// Certification sources
+const (
+	CertificationFromAttribute = "attribute" // The user's certifications attribute, with certifier and dates
+	CertificationFromGroup     = "group"     // Membership of a cert-<tool> group
+)
+
+// Reasons for a certification check, besides AccessReasonInactive and the token failure reasons
+const (
+	CertReasonCertified = "certified"
+	CertReasonMissing   = "not_certified"
+	CertReasonExpired   = "certification_expired"
+)
+
+// Certification says a member may use a tool or work in an area
+type Certification struct {
+	Tool        string     `json:"tool"`                   // Lower-case slug such as "laser" or "wood-shop"
+	CertifiedBy string     `json:"certified_by,omitempty"` // Staff member who certified them
+	GrantedAt   *time.Time `json:"granted_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"` // Re-certification is due on this date
+	Source      string     `json:"source"`
+}
+
+// IsExpired returns true once re-certification is due
+func (c *Certification) IsExpired(now time.Time) bool {
+	return c.ExpiresAt != nil && !now.Before(*c.ExpiresAt)
+}
+
+// ParseCertifications builds a member's certifications from their groups and certifications attribute
+// The attribute is a list of {"tool", "certified_by", "granted", "expires"} objects with YYYY-MM-DD dates.
+// Its entries win over cert- groups for the same tool; invalid entries are skipped and reported in the error.
+func ParseCertifications(groups []string, attribute interface{}) ([]Certification, error) {
+	byTool := make(map[string]Certification)
+	for _, group := range groups {
+		if tool, ok := strings.CutPrefix(strings.ToLower(group), CertificationGroupPrefix); ok && tool != "" {
+			byTool[tool] = Certification{Tool: tool, Source: CertificationFromGroup}
+		}
+	}
+
+	var errs []error
+	if attribute != nil {
+		entries, ok := attribute.([]interface{})
+		if !ok {
+			errs = append(errs, errors.New("certifications must be a list"))
+		}
+		for i, entry := range entries {
+			cert, err := parseCertificationEntry(entry)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("certification %d: %w", i, err))
+				continue
+			}
+			byTool[cert.Tool] = cert
+		}
+	}
+
+	certifications := make([]Certification, 0, len(byTool))
+	for _, cert := range byTool {
+		certifications = append(certifications, cert)
+	}
+	sort.Slice(certifications, func(i, j int) bool {
+		return certifications[i].Tool < certifications[j].Tool
+	})
+	return certifications, errors.Join(errs...)
+}
+
+// parseCertificationEntry reads one entry of the certifications attribute
+func parseCertificationEntry(entry interface{}) (Certification, error) {
+	fields, ok := entry.(map[string]interface{})
+	if !ok {
+		return Certification{}, errors.New("must be an object")
+	}
+
+	tool, _ := fields["tool"].(string)
+	tool = strings.ToLower(strings.TrimSpace(tool))
+	if tool == "" {
+		return Certification{}, errors.New("tool is required")
+	}
+
+	cert := Certification{Tool: tool, Source: CertificationFromAttribute}
+	cert.CertifiedBy, _ = fields["certified_by"].(string)
+
+	for key, target := range map[string]**time.Time{"granted": &cert.GrantedAt, "expires": &cert.ExpiresAt} {
+		value, _ := fields[key].(string)
+		if value == "" {
+			continue
+		}
+		date, err := time.Parse(GuestPassDateLayout, value)
+		if err != nil {
+			return Certification{}, fmt.Errorf("%s must be a YYYY-MM-DD date", key)
+		}
+		*target = &date
+	}
+	return cert, nil
+}
+
+// CurrentCertifications returns the user's certifications that are not due for re-certification
+func (u *UserProfile) CurrentCertifications(now time.Time) []Certification {
+	current := make([]Certification, 0, len(u.Certifications))
+	for _, cert := range u.Certifications {
+		if !cert.IsExpired(now) {
+			current = append(current, cert)
+		}
+	}
+	return current
+}
+
+// CheckCertification decides whether the member may use a tool: they need an active membership and a current certification
+// The certification is returned whenever the member holds one, expired or not
+func CheckCertification(user *UserProfile, membership *MembershipInfo, tool string, now time.Time) (bool, string, *Certification) {
+	var found *Certification
+	for i := range user.Certifications {
+		if strings.EqualFold(user.Certifications[i].Tool, tool) {
+			found = &user.Certifications[i]
+			break
+		}
+	}
+
+	if _, status, _ := EvaluateMembership(user, membership, now); status != StatusActive {
+		return false, AccessReasonInactive, found
+	}
+	switch {
+	case found == nil:
+		return false, CertReasonMissing, nil
+	case found.IsExpired(now):
+		return false, CertReasonExpired, found
+	default:
+		return true, CertReasonCertified, found
+	}
+}
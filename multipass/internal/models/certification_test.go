@@ -0,0 +1,99 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCertifications(t *testing.T) {
+	attribute := []interface{}{
+		map[string]interface{}{"tool": "Laser", "certified_by": "staff@example.com", "granted": "2025-01-10", "expires": "2026-01-10"},
+		map[string]interface{}{"tool": "cnc"},
+		map[string]interface{}{"tool": "lathe", "expires": "next year"},
+		"welding",
+	}
+
+	certifications, err := ParseCertifications([]string{"Members", "cert-laser", "cert-wood-shop", "cert-"}, attribute)
+	if err == nil {
+		t.Error("Expected an error for the invalid entries")
+	}
+
+	// Test cases
+	tests := []struct {
+		tool              string
+		expectedSource    string
+		expectedCertifier string
+		expectedExpiry    string
+	}{
+		{"cnc", CertificationFromAttribute, "", ""},
+		{"laser", CertificationFromAttribute, "staff@example.com", "2026-01-10"},
+		{"wood-shop", CertificationFromGroup, "", ""},
+	}
+
+	// Run tests
+	if len(certifications) != len(tests) {
+		t.Fatalf("Expected %d certifications, got %+v", len(tests), certifications)
+	}
+	for i, tc := range tests {
+		t.Run(tc.tool, func(t *testing.T) {
+			cert := certifications[i]
+			expiry := ""
+			if cert.ExpiresAt != nil {
+				expiry = cert.ExpiresAt.Format(GuestPassDateLayout)
+			}
+			if cert.Tool != tc.tool || cert.Source != tc.expectedSource || cert.CertifiedBy != tc.expectedCertifier || expiry != tc.expectedExpiry {
+				t.Errorf("Unexpected certification %+v", cert)
+			}
+		})
+	}
+
+	// Without the attribute only groups count
+	if certifications, err := ParseCertifications([]string{"cert-laser"}, nil); err != nil || len(certifications) != 1 {
+		t.Errorf("Expected one certification from groups, got %+v, %v", certifications, err)
+	}
+}
+
+func TestCheckCertification(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	lastWeek := now.AddDate(0, 0, -7)
+	nextYear := now.AddDate(1, 0, 0)
+	user := &UserProfile{
+		Email:       "member@example.com",
+		AccessLevel: FullMember,
+		Certifications: []Certification{
+			{Tool: "laser", ExpiresAt: &nextYear, Source: CertificationFromAttribute},
+			{Tool: "lathe", ExpiresAt: &lastWeek, Source: CertificationFromAttribute},
+			{Tool: "wood-shop", Source: CertificationFromGroup},
+		},
+	}
+	active := &MembershipInfo{Status: StatusActive}
+
+	// Test cases
+	tests := []struct {
+		name              string
+		tool              string
+		membership        *MembershipInfo
+		expectedCertified bool
+		expectedReason    string
+	}{
+		{"Certified", "Laser", active, true, CertReasonCertified},
+		{"Certified through a group", "wood-shop", active, true, CertReasonCertified},
+		{"Re-certification due", "lathe", active, false, CertReasonExpired},
+		{"Never certified", "cnc", active, false, CertReasonMissing},
+		{"Suspended member", "laser", &MembershipInfo{Status: StatusSuspended}, false, AccessReasonInactive},
+	}
+
+	// Run tests
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			certified, reason, _ := CheckCertification(user, tc.membership, tc.tool, now)
+			if certified != tc.expectedCertified || reason != tc.expectedReason {
+				t.Errorf("Expected %v (%s), got %v (%s)", tc.expectedCertified, tc.expectedReason, certified, reason)
+			}
+		})
+	}
+
+	if current := user.CurrentCertifications(now); len(current) != 2 {
+		t.Errorf("Expected 2 current certifications, got %+v", current)
+	}
+}
@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// Check-in directions
+const (
+	CheckinIn  = "in"
+	CheckinOut = "out"
+)
+
+// CheckinEvent records a member entering or leaving the space
+type CheckinEvent struct {
+	ID         string    `json:"id"`
+	Email      string    `json:"email"`
+	MemberName string    `json:"member_name"`
+	Direction  string    `json:"direction"` // CheckinIn or CheckinOut
+	Location   string    `json:"location"`  // One of CHECKIN_LOCATIONS
+	Device     string    `json:"device"`    // Scanner that recorded the event, e.g. "API key door-1" or "front desk iPad"
+	RecordedBy string    `json:"recorded_by,omitempty"`
+	At         time.Time `json:"at"`
+	Auto       bool      `json:"auto,omitempty"` // Closed at closing time rather than scanned
+}
+
+// Presence is a member who is currently checked in
+type Presence struct {
+	Email      string    `json:"email"`
+	MemberName string    `json:"member_name"`
+	Location   string    `json:"location"`
+	Since      time.Time `json:"since"`
+}
+
+// DailyCount summarizes the check-ins of one day
+type DailyCount struct {
+	Date     string `json:"date"`     // YYYY-MM-DD in local time
+	Members  int    `json:"members"`  // Distinct members who checked in
+	Checkins int    `json:"checkins"` // Check-in events, counting repeat visits
+}
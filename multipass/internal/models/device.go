@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Device is a browser the member registered to show their live card
+// The private key never leaves the browser; only the public key is stored
+type Device struct {
+	ID         string          `json:"id"`
+	Email      string          `json:"email"` // Member who registered the device
+	Name       string          `json:"name"`  // Label shown in the device list, e.g. the browser and platform
+	PublicKey  DevicePublicKey `json:"public_key"`
+	CreatedAt  time.Time       `json:"created_at"`
+	LastUsedAt *time.Time      `json:"last_used_at,omitempty"`
+}
+
+// DevicePublicKey is an ECDSA P-256 public key in the JWK form WebCrypto exports
+type DevicePublicKey struct {
+	Kty string `json:"kty"` // Always "EC"
+	Crv string `json:"crv"` // Always "P-256"
+	X   string `json:"x"`   // base64url X coordinate
+	Y   string `json:"y"`   // base64url Y coordinate
+}
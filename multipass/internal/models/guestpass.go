@@ -0,0 +1,46 @@
+package models
+
+import (
+	"multipass/internal/config"
+	"time"
+)
+
+// GuestPassDateLayout is the layout of a guest pass's visit date
+const GuestPassDateLayout = "2006-01-02"
+
+// GuestPass records a pass a member issued for a guest; the token itself is never stored
+type GuestPass struct {
+	ID           string     `json:"id"` // Token ID of the guest token
+	GuestName    string     `json:"guest_name"`
+	SponsorEmail string     `json:"sponsor_email"`
+	SponsorName  string     `json:"sponsor_name"`
+	VisitDate    string     `json:"visit_date"` // Day the pass is valid, in the server's time zone
+	IssuedAt     time.Time  `json:"issued_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	Visits       int        `json:"visits"` // Times the pass was verified
+	VisitedAt    *time.Time `json:"visited_at,omitempty"`
+}
+
+// ValidOn returns true if now falls on the pass's visit date in the server's time zone
+func (p *GuestPass) ValidOn(now time.Time) bool {
+	return p.VisitDate == now.Local().Format(GuestPassDateLayout)
+}
+
+// DefaultGuestPassQuota is used for any level without an entry under guest_passes.monthly_quota
+var DefaultGuestPassQuota = map[UserLevel]int{
+	FullMember: 2,
+	Staff:      4,
+	Admin:      4,
+}
+
+// GuestPassQuota returns how many guest passes a member of the level may issue per calendar month
+func GuestPassQuota(level UserLevel, mapping *config.GroupMappingConfig) int {
+	if mapping != nil {
+		for name, quota := range mapping.GuestPasses.MonthlyQuota {
+			if parsed, ok := ParseUserLevel(name); ok && parsed == level {
+				return quota
+			}
+		}
+	}
+	return DefaultGuestPassQuota[level]
+}
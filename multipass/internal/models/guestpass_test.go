@@ -0,0 +1,68 @@
+package models
+
+import (
+	"multipass/internal/config"
+	"testing"
+	"time"
+)
+
+func TestGuestPassQuota(t *testing.T) {
+	mapping := &config.GroupMappingConfig{
+		GuestPasses: config.GuestPassConfig{
+			MonthlyQuota: map[string]int{
+				"FullMember":       1,
+				"LimitedVolunteer": 3,
+				"Admin":            0,
+			},
+		},
+	}
+
+	// Test cases
+	testCases := []struct {
+		name     string
+		level    UserLevel
+		mapping  *config.GroupMappingConfig
+		expected int
+	}{
+		{name: "Configured level", level: FullMember, mapping: mapping, expected: 1},
+		{name: "Configured level without a default", level: LimitedVolunteer, mapping: mapping, expected: 3},
+		{name: "Configured zero disables passes", level: Admin, mapping: mapping, expected: 0},
+		{name: "Unconfigured level uses default", level: Staff, mapping: mapping, expected: DefaultGuestPassQuota[Staff]},
+		{name: "No mapping", level: FullMember, mapping: nil, expected: DefaultGuestPassQuota[FullMember]},
+		{name: "No default for volunteers", level: LimitedVolunteer, mapping: nil, expected: 0},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if quota := GuestPassQuota(tc.level, tc.mapping); quota != tc.expected {
+				t.Errorf("Expected quota %d, got %d", tc.expected, quota)
+			}
+		})
+	}
+}
+
+func TestGuestPass_ValidOn(t *testing.T) {
+	pass := &GuestPass{VisitDate: "2025-06-14"}
+
+	// Test cases
+	testCases := []struct {
+		name     string
+		now      time.Time
+		expected bool
+	}{
+		{name: "Morning of the visit", now: time.Date(2025, 6, 14, 8, 0, 0, 0, time.Local), expected: true},
+		{name: "Late on the visit", now: time.Date(2025, 6, 14, 23, 59, 0, 0, time.Local), expected: true},
+		{name: "Day before", now: time.Date(2025, 6, 13, 23, 59, 0, 0, time.Local), expected: false},
+		{name: "Day after", now: time.Date(2025, 6, 15, 0, 0, 0, 0, time.Local), expected: false},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if valid := pass.ValidOn(tc.now); valid != tc.expected {
+				t.Errorf("Expected %v, got %v", tc.expected, valid)
+			}
+		})
+	}
+}
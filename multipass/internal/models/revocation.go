@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// RevokedToken records a single card or share token that staff have revoked
+type RevokedToken struct {
+	ID        string    `json:"id"`               // Random token ID carried in v2 tokens
+	Email     string    `json:"email,omitempty"`  // Owner of the token, when known
+	Reason    string    `json:"reason,omitempty"` // Free-text note for the audit trail
+	RevokedBy string    `json:"revoked_by"`       // Staff member who revoked it
+	RevokedAt time.Time `json:"revoked_at"`       // When it was revoked
+	ExpiresAt time.Time `json:"expires_at"`       // When the token expires anyway; the entry is dropped after this
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RFIDCard is a 13.56 MHz card or fob bound to a member
+type RFIDCard struct {
+	UID         string    `json:"uid"` // Upper-case hex as returned by NormalizeCardUID
+	Email       string    `json:"email"`
+	MemberName  string    `json:"member_name"`
+	AuthentikID string    `json:"authentik_id,omitempty"` // Looked up first when the card is presented
+	Label       string    `json:"label,omitempty"`        // e.g. "Blue fob"
+	BoundBy     string    `json:"bound_by"`
+	BoundAt     time.Time `json:"bound_at"`
+}
+
+// NormalizeCardUID turns a UID typed by a keyboard-wedge reader into upper-case hex without separators
+// Readers send 4, 7 or 10 byte UIDs as hex, with or without ":", "-" or spaces between bytes,
+// and many send 4 byte UIDs as a 10 digit decimal number instead
+func NormalizeCardUID(raw string) (string, bool) {
+	uid := strings.NewReplacer(":", "", "-", "", " ", "").Replace(strings.TrimSpace(raw))
+
+	// Decimal output of a 4 byte UID
+	if len(uid) == 10 {
+		value, err := strconv.ParseUint(uid, 10, 32)
+		if err != nil {
+			return "", false
+		}
+		return fmt.Sprintf("%08X", value), true
+	}
+
+	if len(uid) != 8 && len(uid) != 14 && len(uid) != 20 {
+		return "", false
+	}
+	if _, err := strconv.ParseUint(uid[:len(uid)/2], 16, 64); err != nil {
+		return "", false
+	}
+	if _, err := strconv.ParseUint(uid[len(uid)/2:], 16, 64); err != nil {
+		return "", false
+	}
+	return strings.ToUpper(uid), true
+}
@@ -0,0 +1,33 @@
+package models
+
+import "testing"
+
+func TestNormalizeCardUID(t *testing.T) {
+	// Test cases
+	testCases := []struct {
+		name     string
+		raw      string
+		expected string
+		ok       bool
+	}{
+		{name: "Plain hex", raw: "04a22b1a", expected: "04A22B1A", ok: true},
+		{name: "Colon separated", raw: "04:A2:2B:1A:5C:6D:80", expected: "04A22B1A5C6D80", ok: true},
+		{name: "Spaces and newline", raw: " 04 a2 2b 1a\n", expected: "04A22B1A", ok: true},
+		{name: "Ten byte UID", raw: "0102030405060708090A", expected: "0102030405060708090A", ok: true},
+		{name: "Ten digit decimal", raw: "0077736730", expected: "04A22B1A", ok: true},
+		{name: "Decimal out of range", raw: "9999999999", expected: "", ok: false},
+		{name: "Wrong length", raw: "04A22B", expected: "", ok: false},
+		{name: "Not hex", raw: "04A22B1G", expected: "", ok: false},
+		{name: "Empty", raw: "", expected: "", ok: false},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			uid, ok := NormalizeCardUID(tc.raw)
+			if uid != tc.expected || ok != tc.ok {
+				t.Errorf("Expected %q (%v), got %q (%v)", tc.expected, tc.ok, uid, ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// Share link scopes decide how much of the card a link shows
+const (
+	ShareScopeFull  = "full"  // The whole card
+	ShareScopeBasic = "basic" // Name and membership status only
+)
+
+// ShareLink records the options of a share link; the token itself is never stored
+type ShareLink struct {
+	ID           string     `json:"id"`        // Token ID of the share token
+	Email        string     `json:"email"`     // Member who created the link
+	Scope        string     `json:"scope"`     // ShareScopeFull or ShareScopeBasic
+	MaxViews     int        `json:"max_views"` // 0 allows unlimited views
+	Views        int        `json:"views"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	LastViewedAt *time.Time `json:"last_viewed_at,omitempty"`
+	RevokedAt    *time.Time `json:"revoked_at,omitempty"`
+	ShortURL     string     `json:"short_url,omitempty"`  // Shlink short URL, if one was created
+	ShortCode    string     `json:"short_code,omitempty"` // Shlink short code, used to delete the short URL
+}
+
+// IsActive returns true if the link is not revoked, expired or used up
+func (l *ShareLink) IsActive(now time.Time) bool {
+	if l.RevokedAt != nil || now.After(l.ExpiresAt) {
+		return false
+	}
+	return l.MaxViews == 0 || l.Views < l.MaxViews
+}
+
+// ViewsLeft returns how many more times the link may be opened, or -1 if unlimited
+func (l *ShareLink) ViewsLeft() int {
+	if l.MaxViews == 0 {
+		return -1
+	}
+	if l.Views >= l.MaxViews {
+		return 0
+	}
+	return l.MaxViews - l.Views
+}
+
+// IsValidShareScope returns true if the scope is known
+func IsValidShareScope(scope string) bool {
+	return scope == ShareScopeFull || scope == ShareScopeBasic
+}
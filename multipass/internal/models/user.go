@@ -2,7 +2,9 @@ package models
 
 import (
 	"multipass/internal/config"
+	"net/http"
 	"strings"
+	"time"
 )
 
 type UserLevel int
@@ -33,18 +35,21 @@ func (ul UserLevel) String() string {
 }
 
 type UserProfile struct {
-	Email            string    `json:"email"`
-	FullName         string    `json:"full_name"`
-	Groups           []string  `json:"groups"`
-	Avatar           *string   `json:"avatar,omitempty"`
-	Phone            *string   `json:"phone,omitempty"`
-	MemberID         string    `json:"member_id"`
-	AccessLevel      UserLevel `json:"access_level"`
-	AuthentikID      string    `json:"authentik_id,omitempty"`
-	MemberSince      string    `json:"member_since,omitempty"`
-	MembershipType   string    `json:"membership_type,omitempty"`
-	ExpiryDate       string    `json:"expiry_date,omitempty"`
-	MembershipStatus string    `json:"membership_status,omitempty"`
+	Email            string          `json:"email"`
+	FullName         string          `json:"full_name"`
+	Groups           []string        `json:"groups"`
+	Avatar           *string         `json:"avatar,omitempty"`
+	Phone            *string         `json:"phone,omitempty"`
+	MemberID         string          `json:"member_id"`
+	AccessLevel      UserLevel       `json:"access_level"`
+	AuthentikID      string          `json:"authentik_id,omitempty"`
+	MemberSince      string          `json:"member_since,omitempty"`
+	MembershipType   string          `json:"membership_type,omitempty"`
+	ExpiryDate       string          `json:"expiry_date,omitempty"`
+	MembershipStatus string          `json:"membership_status,omitempty"`
+	Capabilities     []string        `json:"capabilities,omitempty"`
+	TokenEpoch       time.Time       `json:"-"`                        // Card tokens issued before this are invalid; from Authentik
+	Certifications   []Certification `json:"certifications,omitempty"` // Tools and areas the member may use
 }
 
 type UserFromHeaders struct {
@@ -55,6 +60,53 @@ type UserFromHeaders struct {
 	Groups   []string `json:"groups"`
 }
 
+// ParseUserFromHeaders normalizes the identity headers of a forward-auth proxy
+// Returns nil if the profile's email header is missing
+func ParseUserFromHeaders(header http.Header, profile config.HeaderProfile) *UserFromHeaders {
+	email := strings.TrimSpace(header.Get(profile.EmailHeader))
+	if email == "" {
+		return nil
+	}
+
+	user := &UserFromHeaders{
+		Email:  email,
+		Groups: ParseGroups(header.Get(profile.GroupsHeader), profile.GroupsDelimiter),
+	}
+
+	// Optional headers are only read when the profile defines them
+	if profile.NameHeader != "" {
+		user.FullName = strings.TrimSpace(header.Get(profile.NameHeader))
+	}
+	if profile.UsernameHeader != "" {
+		user.Username = strings.TrimSpace(header.Get(profile.UsernameHeader))
+	}
+	if profile.UIDHeader != "" {
+		user.UserID = strings.TrimSpace(header.Get(profile.UIDHeader))
+	}
+
+	return user
+}
+
+// ParseGroups splits a groups header on the delimiter, falling back to comma if the delimiter is absent
+func ParseGroups(groupsHeader, delimiter string) []string {
+	if strings.TrimSpace(groupsHeader) == "" {
+		return nil
+	}
+
+	if delimiter == "" || !strings.Contains(groupsHeader, delimiter) {
+		delimiter = ","
+	}
+
+	var groups []string
+	for _, group := range strings.Split(groupsHeader, delimiter) {
+		// Trim whitespace from group names and skip empty entries
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
 // Group mapping will be loaded from config file
 var GroupMapping = map[string]UserLevel{}
 
@@ -66,38 +118,32 @@ func DetermineUserLevel(groups []string) UserLevel {
 	// Check for highest privilege level first
 	for _, group := range groups {
 		if levelStr, exists := cfg.GroupMappingConfig.Mappings[group]; exists {
-			// Convert string level to UserLevel enum
-			switch levelStr {
-			case "NoAccess":
-				return NoAccess
-			case "LimitedVolunteer":
-				return LimitedVolunteer
-			case "FullMember":
-				return FullMember
-			case "Staff":
-				return Staff
-			case "Admin":
-				return Admin
-			default:
-				return NoAccess
-			}
+			// Convert string level to UserLevel enum; unknown names grant no access
+			level, _ := ParseUserLevel(levelStr)
+			return level
 		}
 	}
 
 	// Use default level from config
-	switch cfg.GroupMappingConfig.DefaultLevel {
+	level, _ := ParseUserLevel(cfg.GroupMappingConfig.DefaultLevel)
+	return level
+}
+
+// ParseUserLevel converts a level name from group_mapping.yaml (e.g. "FullMember") to a UserLevel
+func ParseUserLevel(name string) (UserLevel, bool) {
+	switch name {
 	case "NoAccess":
-		return NoAccess
+		return NoAccess, true
 	case "LimitedVolunteer":
-		return LimitedVolunteer
+		return LimitedVolunteer, true
 	case "FullMember":
-		return FullMember
+		return FullMember, true
 	case "Staff":
-		return Staff
+		return Staff, true
 	case "Admin":
-		return Admin
+		return Admin, true
 	default:
-		return NoAccess
+		return NoAccess, false
 	}
 }
 
@@ -0,0 +1,119 @@
+package models
+
+import (
+	"multipass/internal/config"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestParseUserFromHeaders(t *testing.T) {
+	// Test cases
+	testCases := []struct {
+		name     string
+		profile  string
+		headers  map[string]string
+		expected *UserFromHeaders
+	}{
+		{
+			name:    "Authentik pipe-separated groups",
+			profile: "authentik",
+			headers: map[string]string{
+				"X-Authentik-Email":    "maker@example.com",
+				"X-Authentik-Name":     "Maker Person",
+				"X-Authentik-Username": "maker",
+				"X-Authentik-Uid":      "abc123",
+				"X-Authentik-Groups":   "Members| staff",
+			},
+			expected: &UserFromHeaders{
+				Email:    "maker@example.com",
+				FullName: "Maker Person",
+				Username: "maker",
+				UserID:   "abc123",
+				Groups:   []string{"Members", "staff"},
+			},
+		},
+		{
+			name:    "Authentik falls back to comma",
+			profile: "authentik",
+			headers: map[string]string{
+				"X-Authentik-Email":  "maker@example.com",
+				"X-Authentik-Groups": "Members,staff",
+			},
+			expected: &UserFromHeaders{
+				Email:  "maker@example.com",
+				Groups: []string{"Members", "staff"},
+			},
+		},
+		{
+			name:    "oauth2-proxy",
+			profile: "oauth2-proxy",
+			headers: map[string]string{
+				"X-Forwarded-Email":              "maker@example.com",
+				"X-Forwarded-User":               "github|42",
+				"X-Forwarded-Preferred-Username": "maker",
+				"X-Forwarded-Groups":             "Members,admin",
+			},
+			expected: &UserFromHeaders{
+				Email:    "maker@example.com",
+				Username: "maker",
+				UserID:   "github|42",
+				Groups:   []string{"Members", "admin"},
+			},
+		},
+		{
+			name:    "Authelia",
+			profile: "authelia",
+			headers: map[string]string{
+				"Remote-User":   "maker",
+				"Remote-Email":  "maker@example.com",
+				"Remote-Name":   "Maker Person",
+				"Remote-Groups": "Members",
+			},
+			expected: &UserFromHeaders{
+				Email:    "maker@example.com",
+				FullName: "Maker Person",
+				Username: "maker",
+				UserID:   "maker",
+				Groups:   []string{"Members"},
+			},
+		},
+		{
+			name:    "Pomerium",
+			profile: "pomerium",
+			headers: map[string]string{
+				"X-Pomerium-Claim-Email":  "maker@example.com",
+				"X-Pomerium-Claim-Sub":    "sub-1",
+				"X-Pomerium-Claim-Groups": "Members,staff",
+			},
+			expected: &UserFromHeaders{
+				Email:  "maker@example.com",
+				UserID: "sub-1",
+				Groups: []string{"Members", "staff"},
+			},
+		},
+		{
+			name:    "Missing email",
+			profile: "oauth2-proxy",
+			headers: map[string]string{
+				"X-Authentik-Email": "maker@example.com",
+			},
+			expected: nil,
+		},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			for name, value := range tc.headers {
+				header.Set(name, value)
+			}
+
+			user := ParseUserFromHeaders(header, config.HeaderProfiles[tc.profile])
+			if !reflect.DeepEqual(user, tc.expected) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, user)
+			}
+		})
+	}
+}
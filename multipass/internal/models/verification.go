@@ -0,0 +1,58 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// VerificationColor is the result the front desk scanner shows for a scanned card
+type VerificationColor string
+
+// Verification results, from "let them in" to "do not let them in"
+const (
+	VerificationGreen  VerificationColor = "green"  // Valid card, active membership
+	VerificationYellow VerificationColor = "yellow" // Valid card, but staff should look at the warnings
+	VerificationRed    VerificationColor = "red"    // Invalid card or no access
+)
+
+// expiryWarningPeriod is how far ahead of a membership's expiry the scanner starts warning
+const expiryWarningPeriod = 14 * 24 * time.Hour
+
+// EvaluateMembership decides the scanner result for a member whose card verified
+// It returns the effective status, which accounts for a passed expiry date, and any warnings
+func EvaluateMembership(user *UserProfile, membership *MembershipInfo, now time.Time) (VerificationColor, MembershipStatus, []string) {
+	warnings := make([]string, 0)
+
+	// An expiry date in the past wins over a stale status
+	status := membership.Status
+	if status == StatusActive && membership.ExpiryDate != nil && now.After(*membership.ExpiryDate) {
+		status = StatusExpired
+	}
+
+	switch {
+	case status != StatusActive:
+		return VerificationRed, status, append(warnings, "Membership is "+status.String())
+	case user.AccessLevel == NoAccess:
+		return VerificationRed, status, append(warnings, "No access to the workspace")
+	}
+
+	if user.AccessLevel == LimitedVolunteer {
+		warnings = append(warnings, "Supervised access only")
+	}
+	if membership.ExpiryDate != nil && membership.ExpiryDate.Sub(now) < expiryWarningPeriod {
+		days := int(membership.ExpiryDate.Sub(now).Hours() / 24)
+		switch days {
+		case 0:
+			warnings = append(warnings, "Membership expires today")
+		case 1:
+			warnings = append(warnings, "Membership expires tomorrow")
+		default:
+			warnings = append(warnings, fmt.Sprintf("Membership expires in %d days", days))
+		}
+	}
+
+	if len(warnings) > 0 {
+		return VerificationYellow, status, warnings
+	}
+	return VerificationGreen, status, warnings
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvaluateMembership(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) *time.Time {
+		t := now.Add(d)
+		return &t
+	}
+
+	// Test cases
+	tests := []struct {
+		name             string
+		level            UserLevel
+		membership       MembershipInfo
+		expectedColor    VerificationColor
+		expectedStatus   MembershipStatus
+		expectedWarnings []string
+	}{
+		{
+			name:             "Active member",
+			level:            FullMember,
+			membership:       MembershipInfo{Status: StatusActive, ExpiryDate: at(90 * 24 * time.Hour)},
+			expectedColor:    VerificationGreen,
+			expectedStatus:   StatusActive,
+			expectedWarnings: []string{},
+		},
+		{
+			name:             "No expiry date",
+			level:            Staff,
+			membership:       MembershipInfo{Status: StatusActive},
+			expectedColor:    VerificationGreen,
+			expectedStatus:   StatusActive,
+			expectedWarnings: []string{},
+		},
+		{
+			name:             "Expiring soon",
+			level:            FullMember,
+			membership:       MembershipInfo{Status: StatusActive, ExpiryDate: at(5*24*time.Hour + time.Hour)},
+			expectedColor:    VerificationYellow,
+			expectedStatus:   StatusActive,
+			expectedWarnings: []string{"Membership expires in 5 days"},
+		},
+		{
+			name:             "Volunteer expiring tomorrow",
+			level:            LimitedVolunteer,
+			membership:       MembershipInfo{Status: StatusActive, ExpiryDate: at(30 * time.Hour)},
+			expectedColor:    VerificationYellow,
+			expectedStatus:   StatusActive,
+			expectedWarnings: []string{"Supervised access only", "Membership expires tomorrow"},
+		},
+		{
+			name:             "Expiry date passed",
+			level:            FullMember,
+			membership:       MembershipInfo{Status: StatusActive, ExpiryDate: at(-time.Hour)},
+			expectedColor:    VerificationRed,
+			expectedStatus:   StatusExpired,
+			expectedWarnings: []string{"Membership is Expired"},
+		},
+		{
+			name:             "Suspended",
+			level:            FullMember,
+			membership:       MembershipInfo{Status: StatusSuspended},
+			expectedColor:    VerificationRed,
+			expectedStatus:   StatusSuspended,
+			expectedWarnings: []string{"Membership is Suspended"},
+		},
+		{
+			name:             "No access",
+			level:            NoAccess,
+			membership:       MembershipInfo{Status: StatusActive},
+			expectedColor:    VerificationRed,
+			expectedStatus:   StatusActive,
+			expectedWarnings: []string{"No access to the workspace"},
+		},
+	}
+
+	// Run tests
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			user := &UserProfile{AccessLevel: tc.level}
+			color, status, warnings := EvaluateMembership(user, &tc.membership, now)
+
+			if color != tc.expectedColor {
+				t.Errorf("Expected %s, got %s", tc.expectedColor, color)
+			}
+			if status != tc.expectedStatus {
+				t.Errorf("Expected status %s, got %s", tc.expectedStatus, status)
+			}
+			if !reflect.DeepEqual(warnings, tc.expectedWarnings) {
+				t.Errorf("Expected warnings %v, got %v", tc.expectedWarnings, warnings)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+package services
+
+import (
+	"encoding/json"
+	"multipass/internal/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+// accessLogRetention is how long access decisions are kept
+const accessLogRetention = 90 * 24 * time.Hour
+
+// accessLogCompaction is how far past the retention period the oldest decision may get before the file is rewritten
+const accessLogCompaction = 24 * time.Hour
+
+// AccessLogStore is the audit log of access decisions, kept in a JSON lines file in the data directory
+// Each decision is appended, so logging stays fast however long the log gets; old decisions are dropped about once a day
+type AccessLogStore struct {
+	mu        sync.Mutex
+	file      jsonLinesFile
+	decisions []*models.AccessDecision // Oldest first
+	now       func() time.Time
+}
+
+// AccessLogFilter selects decisions from the audit log; empty fields match everything
+type AccessLogFilter struct {
+	Door  string
+	Email string
+	Since time.Time
+	Limit int // Zero returns every match
+}
+
+// NewAccessLogStore loads the decisions stored at path
+func NewAccessLogStore(path string) (*AccessLogStore, error) {
+	store := &AccessLogStore{
+		file:      jsonLinesFile{path: path},
+		decisions: make([]*models.AccessDecision, 0),
+		now:       time.Now,
+	}
+
+	err := store.file.load(func(line []byte) error {
+		var decision models.AccessDecision
+		if err := json.Unmarshal(line, &decision); err != nil {
+			return err
+		}
+		store.decisions = append(store.decisions, &decision)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Drop what expired while the service was down
+	if len(store.decisions) > 0 && store.decisions[0].At.Before(store.now().Add(-accessLogRetention)) {
+		store.mu.Lock()
+		defer store.mu.Unlock()
+		if err := store.compactLocked(); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+// Record adds a decision to the log, giving it an ID
+func (s *AccessLogStore) Record(decision *models.AccessDecision) error {
+	id, err := randomHex(8)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	decision.ID = id
+	entry := *decision
+	s.decisions = append(s.decisions, &entry)
+
+	// Rewrite the file once the oldest decision is well past the retention period
+	if s.decisions[0].At.Before(s.now().Add(-accessLogRetention - accessLogCompaction)) {
+		return s.compactLocked()
+	}
+	return s.file.append(&entry)
+}
+
+// List returns the decisions matching filter, newest first
+func (s *AccessLogStore) List(filter AccessLogFilter) []*models.AccessDecision {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	decisions := make([]*models.AccessDecision, 0)
+	for i := len(s.decisions) - 1; i >= 0; i-- {
+		decision := s.decisions[i]
+		if decision.At.Before(filter.Since) {
+			break
+		}
+		if filter.Door != "" && decision.Door != filter.Door {
+			continue
+		}
+		if filter.Email != "" && !strings.EqualFold(decision.Email, filter.Email) {
+			continue
+		}
+
+		entry := *decision
+		decisions = append(decisions, &entry)
+		if filter.Limit > 0 && len(decisions) == filter.Limit {
+			break
+		}
+	}
+	return decisions
+}
+
+// compactLocked drops decisions older than the retention period and rewrites the file; callers hold mu
+func (s *AccessLogStore) compactLocked() error {
+	cutoff := s.now().Add(-accessLogRetention)
+	dropped := 0
+	for dropped < len(s.decisions) && s.decisions[dropped].At.Before(cutoff) {
+		dropped++
+	}
+
+	s.decisions = append([]*models.AccessDecision(nil), s.decisions[dropped:]...)
+	return s.file.save(s.values())
+}
+
+// values returns the decisions for jsonLinesFile.save
+func (s *AccessLogStore) values() []interface{} {
+	values := make([]interface{}, len(s.decisions))
+	for i, decision := range s.decisions {
+		values[i] = decision
+	}
+	return values
+}
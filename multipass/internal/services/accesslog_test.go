@@ -0,0 +1,83 @@
+package services
+
+import (
+	"multipass/internal/models"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAccessLogStore_RecordAndList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access_log.jsonl")
+	store, err := NewAccessLogStore(path)
+	if err != nil {
+		t.Fatalf("Failed to create access log: %v", err)
+	}
+	now := time.Now().UTC()
+
+	decisions := []*models.AccessDecision{
+		{Door: "front-door", Email: "member@example.com", Allowed: true, Reason: models.AccessReasonAllowed, At: now.Add(-3 * time.Hour)},
+		{Door: "wood-shop", Email: "member@example.com", Reason: models.AccessReasonLevel, At: now.Add(-2 * time.Hour)},
+		{Door: "front-door", Email: "other@example.com", Allowed: true, Reason: models.AccessReasonAllowed, At: now.Add(-time.Hour)},
+	}
+	for _, decision := range decisions {
+		if err := store.Record(decision); err != nil {
+			t.Fatalf("Failed to record decision: %v", err)
+		}
+		if decision.ID == "" {
+			t.Error("Expected the decision to get an ID")
+		}
+	}
+
+	// Decisions survive a restart
+	reloaded, err := NewAccessLogStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reload access log: %v", err)
+	}
+
+	// Test cases
+	tests := []struct {
+		name        string
+		filter      AccessLogFilter
+		expectedIDs []string
+	}{
+		{"Everything, newest first", AccessLogFilter{}, []string{decisions[2].ID, decisions[1].ID, decisions[0].ID}},
+		{"One door", AccessLogFilter{Door: "front-door"}, []string{decisions[2].ID, decisions[0].ID}},
+		{"One member", AccessLogFilter{Email: "Member@Example.com"}, []string{decisions[1].ID, decisions[0].ID}},
+		{"Since", AccessLogFilter{Since: now.Add(-90 * time.Minute)}, []string{decisions[2].ID}},
+		{"Limit", AccessLogFilter{Limit: 1}, []string{decisions[2].ID}},
+	}
+
+	// Run tests
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			listed := reloaded.List(tc.filter)
+			if len(listed) != len(tc.expectedIDs) {
+				t.Fatalf("Expected %d decisions, got %d", len(tc.expectedIDs), len(listed))
+			}
+			for i, id := range tc.expectedIDs {
+				if listed[i].ID != id {
+					t.Errorf("Expected decision %d to be %s, got %s", i, id, listed[i].ID)
+				}
+			}
+		})
+	}
+}
+
+func TestAccessLogStore_Retention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access_log.jsonl")
+	store, _ := NewAccessLogStore(path)
+	now := time.Now().UTC()
+
+	store.Record(&models.AccessDecision{Door: "front-door", At: now.Add(-accessLogRetention - 2*accessLogCompaction)})
+	store.Record(&models.AccessDecision{Door: "front-door", At: now})
+
+	// Recording past the compaction window drops the old decision from memory and disk
+	reloaded, err := NewAccessLogStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reload access log: %v", err)
+	}
+	if listed := reloaded.List(AccessLogFilter{}); len(listed) != 1 || !listed[0].At.Equal(now) {
+		t.Errorf("Expected only the recent decision, got %+v", listed)
+	}
+}
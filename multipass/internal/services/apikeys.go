@@ -0,0 +1,212 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"multipass/internal/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// apiKeyPrefix marks multipass API keys so they are easy to recognize in configs and scanners
+	apiKeyPrefix = "mpk"
+
+	// lastUsedPersistInterval limits how often last-used timestamps are written to disk
+	lastUsedPersistInterval = time.Minute
+)
+
+// API key errors
+var (
+	ErrAPIKeyInvalid  = errors.New("invalid API key")
+	ErrAPIKeyInactive = errors.New("API key revoked or expired")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// APIKeyStore keeps hashed API keys in a JSON file in the data directory
+type APIKeyStore struct {
+	mu        sync.Mutex
+	file      jsonFile
+	keys      map[string]*models.APIKey
+	persisted map[string]time.Time // Last time each key's last-used timestamp was saved
+}
+
+// NewAPIKeyStore loads the API keys stored at path
+func NewAPIKeyStore(path string) (*APIKeyStore, error) {
+	store := &APIKeyStore{
+		file:      jsonFile{path: path},
+		keys:      make(map[string]*models.APIKey),
+		persisted: make(map[string]time.Time),
+	}
+
+	var keys []*models.APIKey
+	if err := store.file.load(&keys); err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		store.keys[key.ID] = key
+	}
+
+	return store, nil
+}
+
+// Create mints a new API key and returns the plaintext key, which is only available once
+func (s *APIKeyStore) Create(name string, scopes []string, validFor time.Duration, createdBy string) (string, *models.APIKey, error) {
+	for _, scope := range scopes {
+		if !models.IsValidScope(scope) {
+			return "", nil, fmt.Errorf("unknown scope: %s", scope)
+		}
+	}
+
+	id, err := randomHex(6)
+	if err != nil {
+		return "", nil, err
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate API key: %w", err)
+	}
+
+	// The key embeds its ID so lookups never need to scan every hash
+	plaintext := fmt.Sprintf("%s_%s_%s", apiKeyPrefix, id, base64.RawURLEncoding.EncodeToString(secret))
+
+	key := &models.APIKey{
+		ID:        id,
+		Name:      name,
+		Hash:      hashAPIKey(plaintext),
+		Scopes:    scopes,
+		CreatedBy: createdBy,
+		CreatedAt: time.Now().UTC(),
+	}
+	if validFor > 0 {
+		expiresAt := key.CreatedAt.Add(validFor)
+		key.ExpiresAt = &expiresAt
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys[id] = key
+	if err := s.saveLocked(); err != nil {
+		delete(s.keys, id)
+		return "", nil, err
+	}
+
+	return plaintext, copyAPIKey(key), nil
+}
+
+// Authenticate checks a presented key and records when it was last used
+// A non-nil key means the key is valid, even if recording its use failed
+func (s *APIKeyStore) Authenticate(plaintext string) (*models.APIKey, error) {
+	parts := strings.SplitN(plaintext, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[parts[1]]
+	if !ok {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashAPIKey(plaintext)), []byte(key.Hash)) != 1 {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	now := time.Now().UTC()
+	if !key.IsActive(now) {
+		return nil, ErrAPIKeyInactive
+	}
+
+	// Record usage, persisting at most once per interval to spare the disk
+	// A failed save does not reject the key; the usage is kept in memory and the key is returned along with the error
+	key.LastUsedAt = &now
+	if now.Sub(s.persisted[key.ID]) >= lastUsedPersistInterval {
+		s.persisted[key.ID] = now
+		if err := s.saveLocked(); err != nil {
+			delete(s.persisted, key.ID) // Try again on the next use
+			return copyAPIKey(key), err
+		}
+	}
+
+	return copyAPIKey(key), nil
+}
+
+// List returns all keys, newest first
+func (s *APIKeyStore) List() []*models.APIKey {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]*models.APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, copyAPIKey(key))
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+	return keys
+}
+
+// Revoke permanently disables a key
+func (s *APIKeyStore) Revoke(id, revokedBy string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return ErrAPIKeyNotFound
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+	key.RevokedBy = revokedBy
+	return s.saveLocked()
+}
+
+// saveLocked writes all keys to disk; the caller must hold the lock
+func (s *APIKeyStore) saveLocked() error {
+	keys := make([]*models.APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return s.file.save(keys)
+}
+
+// hashAPIKey returns the hex SHA-256 of a key; keys are random enough that a slow hash adds nothing
+func hashAPIKey(plaintext string) string {
+	sum := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(sum[:])
+}
+
+// copyAPIKey returns a copy so callers cannot mutate the stored key
+func copyAPIKey(key *models.APIKey) *models.APIKey {
+	c := *key
+	c.Scopes = append([]string(nil), key.Scopes...)
+	return &c
+}
+
+// randomHex returns n random bytes encoded as hex
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate random ID: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"errors"
+	"multipass/internal/models"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyStore_CreateAndAuthenticate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api_keys.json")
+	store, err := NewAPIKeyStore(path)
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	plaintext, key, err := store.Create("Front desk tablet", []string{models.ScopeVerifyRead}, 0, "staff@example.com")
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+	if !strings.HasPrefix(plaintext, "mpk_"+key.ID+"_") {
+		t.Errorf("Unexpected key format: %s", plaintext)
+	}
+
+	// Only the hash may be written to disk
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), plaintext) {
+		t.Error("Plaintext key was persisted")
+	}
+
+	authenticated, err := store.Authenticate(plaintext)
+	if err != nil {
+		t.Fatalf("Failed to authenticate key: %v", err)
+	}
+	if !authenticated.HasScope(models.ScopeVerifyRead) || authenticated.HasScope(models.ScopeMembersRead) {
+		t.Errorf("Unexpected scopes: %v", authenticated.Scopes)
+	}
+	if authenticated.LastUsedAt == nil {
+		t.Error("Expected last-used timestamp to be recorded")
+	}
+
+	// Keys survive a restart
+	reloaded, err := NewAPIKeyStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reload store: %v", err)
+	}
+	if _, err := reloaded.Authenticate(plaintext); err != nil {
+		t.Errorf("Failed to authenticate after reload: %v", err)
+	}
+}
+
+func TestAPIKeyStore_Rejects(t *testing.T) {
+	store, _ := NewAPIKeyStore(filepath.Join(t.TempDir(), "api_keys.json"))
+
+	plaintext, key, _ := store.Create("Door reader", []string{models.ScopeCheckinWrite}, 0, "staff@example.com")
+	expired, _, _ := store.Create("Old kiosk", []string{models.ScopeVerifyRead}, time.Nanosecond, "staff@example.com")
+	time.Sleep(time.Millisecond)
+
+	// Test cases
+	testCases := []struct {
+		name     string
+		key      string
+		expected error
+	}{
+		{
+			name:     "Malformed key",
+			key:      "not-a-key",
+			expected: ErrAPIKeyInvalid,
+		},
+		{
+			name:     "Wrong secret",
+			key:      "mpk_" + key.ID + "_wrongsecret",
+			expected: ErrAPIKeyInvalid,
+		},
+		{
+			name:     "Expired key",
+			key:      expired,
+			expected: ErrAPIKeyInactive,
+		},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := store.Authenticate(tc.key); !errors.Is(err, tc.expected) {
+				t.Errorf("Expected %v, got %v", tc.expected, err)
+			}
+		})
+	}
+
+	// Revoked keys stop working immediately
+	if err := store.Revoke(key.ID, "staff@example.com"); err != nil {
+		t.Fatalf("Failed to revoke key: %v", err)
+	}
+	if _, err := store.Authenticate(plaintext); !errors.Is(err, ErrAPIKeyInactive) {
+		t.Errorf("Expected revoked key to be rejected, got %v", err)
+	}
+
+	if err := store.Revoke("missing", "staff@example.com"); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("Expected ErrAPIKeyNotFound, got %v", err)
+	}
+
+	// Unknown scopes are refused at creation
+	if _, _, err := store.Create("Bad", []string{"admin:everything"}, 0, "staff@example.com"); err == nil {
+		t.Error("Expected unknown scope to be rejected")
+	}
+}
+
+func TestAPIKeyStore_AuthenticateWhenSaveFails(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewAPIKeyStore(filepath.Join(dir, "api_keys.json"))
+	plaintext, _, err := store.Create("Door reader", []string{models.ScopeCheckinWrite}, 0, "staff@example.com")
+	if err != nil {
+		t.Fatalf("Failed to create key: %v", err)
+	}
+
+	// Point the store below a regular file so the data directory cannot be created
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, nil, 0o600); err != nil {
+		t.Fatalf("Failed to create blocking file: %v", err)
+	}
+	store.file.path = filepath.Join(blocker, "api_keys.json")
+
+	// The key is still accepted and its use is kept in memory
+	key, err := store.Authenticate(plaintext)
+	if err == nil {
+		t.Error("Expected the failed save to be reported")
+	}
+	if key == nil || key.LastUsedAt == nil {
+		t.Fatalf("Expected the key with its last-used timestamp, got %+v", key)
+	}
+	if listed := store.List(); len(listed) != 1 || listed[0].LastUsedAt == nil {
+		t.Errorf("Expected the last-used timestamp to be kept in memory, got %+v", listed)
+	}
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"multipass/internal/models"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// assertionLeeway tolerates clock drift between multipass and offline verifiers
+const assertionLeeway = time.Minute
+
+// ErrAssertionKeyUnknown is returned for assertions signed by a key that is not published
+var ErrAssertionKeyUnknown = errors.New("unknown assertion signing key")
+
+// assertionKey is an Ed25519 signing key as stored in the data directory
+type assertionKey struct {
+	KID       string    `json:"kid"`
+	Seed      []byte    `json:"seed"` // 32-byte Ed25519 seed
+	CreatedAt time.Time `json:"created_at"`
+
+	private ed25519.PrivateKey
+}
+
+// AssertionSigner issues Ed25519-signed membership assertions and publishes the public keys as a JWKS
+// The newest key signs; older keys stay published so assertions issued before a rotation keep verifying
+type AssertionSigner struct {
+	mu     sync.RWMutex
+	file   jsonFile
+	keys   []*assertionKey // Oldest first; the last key signs
+	issuer string
+	ttl    time.Duration
+}
+
+// NewAssertionSigner loads the signing keys stored at path, generating the first one if there are none
+func NewAssertionSigner(path, issuer string, ttl time.Duration) (*AssertionSigner, error) {
+	signer := &AssertionSigner{
+		file:   jsonFile{path: path},
+		issuer: issuer,
+		ttl:    ttl,
+	}
+
+	if err := signer.file.load(&signer.keys); err != nil {
+		return nil, err
+	}
+	for _, key := range signer.keys {
+		if len(key.Seed) != ed25519.SeedSize {
+			return nil, fmt.Errorf("invalid seed for assertion key %s", key.KID)
+		}
+		key.private = ed25519.NewKeyFromSeed(key.Seed)
+	}
+
+	if len(signer.keys) == 0 {
+		if _, err := signer.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return signer, nil
+}
+
+// Rotate generates a new signing key and returns its key ID
+func (s *AssertionSigner) Rotate() (string, error) {
+	seed := make([]byte, ed25519.SeedSize)
+	if _, err := rand.Read(seed); err != nil {
+		return "", fmt.Errorf("failed to generate assertion key: %w", err)
+	}
+
+	private := ed25519.NewKeyFromSeed(seed)
+	kid, err := keyID(private.Public())
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = append(s.keys, &assertionKey{
+		KID:       kid,
+		Seed:      seed,
+		CreatedAt: time.Now().UTC(),
+		private:   private,
+	})
+	if err := s.file.save(s.keys); err != nil {
+		s.keys = s.keys[:len(s.keys)-1]
+		return "", err
+	}
+
+	return kid, nil
+}
+
+// Issue signs an assertion of the member's current membership state
+func (s *AssertionSigner) Issue(user *models.UserProfile, membership *models.MembershipInfo) (string, *models.MembershipAssertion, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	assertion := &models.MembershipAssertion{
+		MemberID:  user.MemberID,
+		Status:    strings.ToLower(membership.Status.String()),
+		Level:     membership.UserLevel,
+		LevelName: membership.UserLevel.String(),
+		ID:        id,
+		IssuedAt:  now,
+		ExpiresAt: now.Add(s.ttl),
+	}
+	if membership.ExpiryDate != nil {
+		assertion.MembershipExpires = membership.ExpiryDate.Format("2006-01-02")
+	}
+
+	s.mu.RLock()
+	key := s.keys[len(s.keys)-1]
+	s.mu.RUnlock()
+
+	options := (&jose.SignerOptions{}).WithType("JWT")
+	signer, err := jose.NewSigner(jose.SigningKey{
+		Algorithm: jose.EdDSA,
+		Key:       jose.JSONWebKey{Key: key.private, KeyID: key.KID},
+	}, options)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to create assertion signer: %w", err)
+	}
+
+	registered := jwt.Claims{
+		Issuer:    s.issuer,
+		Subject:   assertion.MemberID,
+		ID:        assertion.ID,
+		IssuedAt:  jwt.NewNumericDate(assertion.IssuedAt),
+		NotBefore: jwt.NewNumericDate(assertion.IssuedAt),
+		Expiry:    jwt.NewNumericDate(assertion.ExpiresAt),
+	}
+
+	raw, err := jwt.Signed(signer).Claims(registered).Claims(assertion).Serialize()
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to sign assertion: %w", err)
+	}
+
+	return raw, assertion, nil
+}
+
+// Verify checks an assertion against the published keys, the way an offline verifier would
+func (s *AssertionSigner) Verify(raw string) (*models.MembershipAssertion, error) {
+	parsed, err := jwt.ParseSigned(raw, []jose.SignatureAlgorithm{jose.EdDSA})
+	if err != nil {
+		return nil, fmt.Errorf("invalid assertion: %w", err)
+	}
+
+	keys := s.JWKS().Key(parsed.Headers[0].KeyID)
+	if len(keys) == 0 {
+		return nil, ErrAssertionKeyUnknown
+	}
+
+	var registered jwt.Claims
+	assertion := &models.MembershipAssertion{}
+	if err := parsed.Claims(keys[0].Key, &registered, assertion); err != nil {
+		return nil, fmt.Errorf("invalid assertion signature: %w", err)
+	}
+
+	expected := jwt.Expected{Issuer: s.issuer, Time: time.Now()}
+	if err := registered.ValidateWithLeeway(expected, assertionLeeway); err != nil {
+		return nil, fmt.Errorf("invalid assertion claims: %w", err)
+	}
+
+	assertion.MemberID = registered.Subject
+	assertion.ID = registered.ID
+	assertion.IssuedAt = registered.IssuedAt.Time()
+	assertion.ExpiresAt = registered.Expiry.Time()
+	return assertion, nil
+}
+
+// JWKS returns the public keys verifiers should trust
+func (s *AssertionSigner) JWKS() *jose.JSONWebKeySet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set := &jose.JSONWebKeySet{Keys: make([]jose.JSONWebKey, 0, len(s.keys))}
+	for _, key := range s.keys {
+		set.Keys = append(set.Keys, jose.JSONWebKey{
+			Key:       key.private.Public(),
+			KeyID:     key.KID,
+			Algorithm: string(jose.EdDSA),
+			Use:       "sig",
+		})
+	}
+	return set
+}
+
+// Issuer returns the "iss" claim of issued assertions
+func (s *AssertionSigner) Issuer() string {
+	return s.issuer
+}
+
+// keyID derives a stable key ID from the RFC 7638 thumbprint of the public key
+func keyID(public crypto.PublicKey) (string, error) {
+	thumbprint, err := (&jose.JSONWebKey{Key: public}).Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", fmt.Errorf("failed to compute key ID: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(thumbprint[:12]), nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"encoding/json"
+	"multipass/internal/models"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+func TestAssertionSigner_IssueAndVerifyOffline(t *testing.T) {
+	signer, err := NewAssertionSigner(filepath.Join(t.TempDir(), "assertion_keys.json"), "https://multipass.example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	expiry := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	user := &models.UserProfile{MemberID: "42", Email: "member@example.com"}
+	membership := &models.MembershipInfo{Status: models.StatusActive, UserLevel: models.FullMember, ExpiryDate: &expiry}
+
+	raw, issued, err := signer.Issue(user, membership)
+	if err != nil {
+		t.Fatalf("Failed to issue assertion: %v", err)
+	}
+
+	// An offline verifier only has the published JWKS
+	jwksJSON, _ := json.Marshal(signer.JWKS())
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(jwksJSON, &jwks); err != nil {
+		t.Fatalf("Failed to parse JWKS: %v", err)
+	}
+
+	parsed, err := jwt.ParseSigned(raw, []jose.SignatureAlgorithm{jose.EdDSA})
+	if err != nil {
+		t.Fatalf("Failed to parse assertion: %v", err)
+	}
+	keys := jwks.Key(parsed.Headers[0].KeyID)
+	if len(keys) != 1 {
+		t.Fatalf("Expected the signing key in the JWKS, got %d keys", len(keys))
+	}
+
+	var registered jwt.Claims
+	var claims models.MembershipAssertion
+	if err := parsed.Claims(keys[0].Key, &registered, &claims); err != nil {
+		t.Fatalf("Failed to verify assertion with the JWKS: %v", err)
+	}
+
+	// Check claims
+	if registered.Subject != "42" || registered.Issuer != "https://multipass.example.com" {
+		t.Errorf("Unexpected registered claims: %+v", registered)
+	}
+	if claims.Status != "active" || claims.Level != models.FullMember || claims.MembershipExpires != "2026-03-31" {
+		t.Errorf("Unexpected membership claims: %+v", claims)
+	}
+	if strings.Contains(raw, user.Email) {
+		t.Error("Assertion should not carry the member's email")
+	}
+	if !registered.Expiry.Time().Equal(issued.ExpiresAt) {
+		t.Errorf("Expected expiry %v, got %v", issued.ExpiresAt, registered.Expiry.Time())
+	}
+}
+
+func TestAssertionSigner_Rotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "assertion_keys.json")
+	signer, _ := NewAssertionSigner(path, "multipass", time.Hour)
+
+	user := &models.UserProfile{MemberID: "42"}
+	membership := &models.MembershipInfo{Status: models.StatusActive, UserLevel: models.FullMember}
+	before, _, _ := signer.Issue(user, membership)
+
+	if _, err := signer.Rotate(); err != nil {
+		t.Fatalf("Failed to rotate key: %v", err)
+	}
+
+	// Keys survive a restart and the old key stays published
+	reloaded, err := NewAssertionSigner(path, "multipass", time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to reload signer: %v", err)
+	}
+	if len(reloaded.JWKS().Keys) != 2 {
+		t.Errorf("Expected 2 published keys, got %d", len(reloaded.JWKS().Keys))
+	}
+	if _, err := reloaded.Verify(before); err != nil {
+		t.Errorf("Expected assertion signed before the rotation to verify: %v", err)
+	}
+
+	after, _, _ := reloaded.Issue(user, membership)
+	if _, err := reloaded.Verify(after); err != nil {
+		t.Errorf("Expected assertion signed with the new key to verify: %v", err)
+	}
+
+	// Tampering with the claims breaks the signature
+	parts := strings.Split(after, ".")
+	tampered := parts[0] + "." + parts[1] + "x." + parts[2]
+	if _, err := reloaded.Verify(tampered); err == nil {
+		t.Error("Expected tampered assertion to be rejected")
+	}
+
+	// Another deployment's keys are not trusted
+	other, _ := NewAssertionSigner(filepath.Join(t.TempDir(), "assertion_keys.json"), "multipass", time.Hour)
+	if _, err := other.Verify(after); err == nil {
+		t.Error("Expected assertion from another signer to be rejected")
+	}
+}
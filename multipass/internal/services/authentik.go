@@ -6,12 +6,20 @@ import (
 	"fmt"
 	"multipass/internal/config"
 	"multipass/internal/models"
+	"multipass/internal/utils"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/go-resty/resty/v2"
 )
 
+// TokenEpochAttribute is the Authentik user attribute holding the time before which the user's card tokens are invalid
+const TokenEpochAttribute = "multipass_token_epoch"
+
+// CertificationsAttribute is the Authentik user attribute listing the member's certifications with their details
+const CertificationsAttribute = "certifications"
+
 // AuthentikClient provides methods to interact with the Authentik API
 type AuthentikClient struct {
 	client    *resty.Client
@@ -167,6 +175,28 @@ func (ac *AuthentikClient) GetUserByID(userID string) (*models.UserProfile, erro
 	return userProfile, nil
 }
 
+// GetUserForToken finds the member a token was issued to
+// Numeric user IDs are looked up by ID first; anything else, or a failed ID lookup, falls back to the email
+func (ac *AuthentikClient) GetUserForToken(data *utils.TokenData) (*models.UserProfile, error) {
+	if data.UserID != "" {
+		// Only try GetUserByID if the ID looks numeric to avoid unnecessary 404s
+		if _, err := strconv.Atoi(data.UserID); err == nil {
+			userProfile, err := ac.GetUserByID(data.UserID)
+			if err == nil && userProfile != nil {
+				return userProfile, nil
+			}
+			ac.logger.Debug("Failed to get user by ID: %v", err)
+		} else {
+			ac.logger.Debug("UserID %s is not numeric, skipping ID lookup", data.UserID)
+		}
+	}
+
+	if data.Email == "" {
+		return nil, errors.New("token has no email to look up")
+	}
+	return ac.GetUserByEmail(data.Email)
+}
+
 // GetUserByEmail retrieves user information from Authentik by email
 func (ac *AuthentikClient) GetUserByEmail(email string) (*models.UserProfile, error) {
 	ac.logger.Debug("GetUserByEmail called with email: %s", email)
@@ -303,7 +333,23 @@ func createUserProfileFromAuthentikUser(ac *AuthentikClient, authUser AuthentikU
 			ac.logger.Debug("Found membership_status attribute: %s", status)
 			userProfile.MembershipStatus = status
 		}
+
+		// Extract the token epoch set by "invalidate all my links"
+		if epoch, ok := authUser.Attributes[TokenEpochAttribute].(string); ok {
+			if parsed, err := time.Parse(time.RFC3339, epoch); err == nil {
+				userProfile.TokenEpoch = parsed
+			} else {
+				ac.logger.Error("Invalid %s attribute for %s: %v", TokenEpochAttribute, authUser.Email, err)
+			}
+		}
+	}
+
+	// Certifications come from the attribute and from cert- groups, so members without attributes still get theirs
+	certifications, err := models.ParseCertifications(groups, authUser.Attributes[CertificationsAttribute])
+	if err != nil {
+		ac.logger.Error("Invalid %s attribute for %s: %v", CertificationsAttribute, authUser.Email, err)
 	}
+	userProfile.Certifications = certifications
 
 	return userProfile, nil
 }
@@ -377,3 +423,45 @@ func (ac *AuthentikClient) GetUserGroups(userID int) ([]string, error) {
 
 	return groups, nil
 }
+
+// SetUserAttribute sets a single attribute on an Authentik user, keeping the others
+// Authentik replaces the whole attributes object on update, so the current attributes are read first
+func (ac *AuthentikClient) SetUserAttribute(userID, key string, value interface{}) error {
+	url := fmt.Sprintf("%s/api/v3/core/users/%s/", ac.baseURL, userID)
+	ac.logger.Debug("Setting attribute %s on Authentik user %s", key, userID)
+
+	resp, err := ac.client.R().Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to request user data: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("failed to get user data, status: %d", resp.StatusCode())
+	}
+
+	var authUser AuthentikUserResponse
+	if err := json.Unmarshal(resp.Body(), &authUser); err != nil {
+		return fmt.Errorf("failed to parse user data: %w", err)
+	}
+
+	attributes := authUser.Attributes
+	if attributes == nil {
+		attributes = make(map[string]interface{})
+	}
+	attributes[key] = value
+
+	resp, err = ac.client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(map[string]interface{}{"attributes": attributes}).
+		Patch(url)
+	if err != nil {
+		return fmt.Errorf("failed to update user: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return fmt.Errorf("failed to update user, status: %d", resp.StatusCode())
+	}
+
+	// Drop the cached profile so the new attribute is seen
+	delete(ac.userCache, userID)
+
+	return nil
+}
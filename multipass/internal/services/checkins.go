@@ -0,0 +1,327 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"multipass/internal/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Check-in errors
+var (
+	ErrCheckinLocation  = errors.New("unknown check-in location")
+	ErrCheckinDirection = errors.New("direction must be \"in\" or \"out\"")
+)
+
+// checkinRetention is how long check-in events are kept, a little over a year for yearly reports
+const checkinRetention = 400 * 24 * time.Hour
+
+// checkinCompaction is how far past the retention period the oldest event may get before the file is rewritten
+const checkinCompaction = 24 * time.Hour
+
+// autoCheckoutDevice names the "device" that records check-outs at closing time
+const autoCheckoutDevice = "closing time"
+
+// CheckinStore keeps the check-in log in a JSON lines file in the data directory
+// Each event is appended, like the access log; old events are dropped about once a day
+// Members still checked in at closing time are checked out lazily, the next time the store is used
+type CheckinStore struct {
+	mu        sync.Mutex
+	file      jsonLinesFile
+	events    []*models.CheckinEvent          // Oldest first
+	unsaved   bool                            // An append failed, so the file must be rewritten
+	present   map[string]*models.CheckinEvent // Latest check-in of each member in the space, by lowercased email
+	locations []string
+	closing   time.Duration // Time after midnight
+	now       func() time.Time
+}
+
+// NewCheckinStore loads the check-ins stored at path
+// locations are the names scans may be recorded at; the first is used when none is given
+func NewCheckinStore(path string, locations []string, closing time.Duration) (*CheckinStore, error) {
+	if len(locations) == 0 {
+		return nil, errors.New("at least one check-in location is required")
+	}
+
+	store := &CheckinStore{
+		file:      jsonLinesFile{path: path},
+		events:    make([]*models.CheckinEvent, 0),
+		present:   make(map[string]*models.CheckinEvent),
+		locations: locations,
+		closing:   closing,
+		now:       time.Now,
+	}
+
+	err := store.file.load(func(line []byte) error {
+		var event models.CheckinEvent
+		if err := json.Unmarshal(line, &event); err != nil {
+			return err
+		}
+		store.events = append(store.events, &event)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Replay the log to find who is still in; automatic check-outs are appended after later events
+	sortCheckins(store.events)
+	for _, event := range store.events {
+		store.applyLocked(event)
+	}
+
+	// Events that expired while the service was down are dropped with the next write
+	return store, nil
+}
+
+// Locations returns the names scans may be recorded at
+func (s *CheckinStore) Locations() []string {
+	return append([]string(nil), s.locations...)
+}
+
+// Record logs a scan of the member at location
+// An empty direction toggles: members who are in are checked out, everyone else is checked in
+func (s *CheckinStore) Record(user *models.UserProfile, location, direction, device, recordedBy string) (*models.CheckinEvent, error) {
+	if location == "" {
+		location = s.locations[0]
+	}
+	if !s.isLocation(location) {
+		return nil, ErrCheckinLocation
+	}
+	if direction != "" && direction != models.CheckinIn && direction != models.CheckinOut {
+		return nil, ErrCheckinDirection
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	closed := s.closeStaleLocked()
+
+	if direction == "" {
+		direction = models.CheckinIn
+		if _, in := s.present[strings.ToLower(user.Email)]; in {
+			direction = models.CheckinOut
+		}
+	}
+
+	event := &models.CheckinEvent{
+		ID:         id,
+		Email:      user.Email,
+		MemberName: user.GetFullName(),
+		Direction:  direction,
+		Location:   location,
+		Device:     device,
+		RecordedBy: recordedBy,
+		At:         s.now().UTC(),
+	}
+	s.events = append(s.events, event)
+	s.applyLocked(event)
+
+	if err := s.saveLocked(append(closed, event)...); err != nil {
+		return nil, err
+	}
+
+	entry := *event
+	return &entry, nil
+}
+
+// Present returns the members in the space right now, longest-staying first
+func (s *CheckinStore) Present() []*models.Presence {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closeStaleAndSaveLocked()
+
+	present := make([]*models.Presence, 0, len(s.present))
+	for _, event := range s.present {
+		present = append(present, &models.Presence{
+			Email:      event.Email,
+			MemberName: event.MemberName,
+			Location:   event.Location,
+			Since:      event.At,
+		})
+	}
+
+	sort.Slice(present, func(i, j int) bool {
+		return present[i].Since.Before(present[j].Since)
+	})
+	return present
+}
+
+// History returns the member's check-in events since the given time, newest first
+func (s *CheckinStore) History(email string, since time.Time) []*models.CheckinEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.closeStaleAndSaveLocked()
+
+	events := make([]*models.CheckinEvent, 0)
+	for i := len(s.events) - 1; i >= 0; i-- {
+		event := s.events[i]
+		if strings.EqualFold(event.Email, email) && !event.At.Before(since) {
+			entry := *event
+			events = append(events, &entry)
+		}
+	}
+	return events
+}
+
+// DailyCounts returns the check-ins of each day from the day of from to the day of to, in local time
+func (s *CheckinStore) DailyCounts(from, to time.Time) []models.DailyCount {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	first := startOfDay(from)
+	last := startOfDay(to)
+
+	// Count check-ins and distinct members per day
+	checkins := make(map[string]int)
+	members := make(map[string]map[string]bool)
+	for _, event := range s.events {
+		if event.Direction != models.CheckinIn {
+			continue
+		}
+		day := event.At.Local().Format(models.GuestPassDateLayout)
+		checkins[day]++
+		if members[day] == nil {
+			members[day] = make(map[string]bool)
+		}
+		members[day][strings.ToLower(event.Email)] = true
+	}
+
+	counts := make([]models.DailyCount, 0)
+	for day := first; !day.After(last); day = day.AddDate(0, 0, 1) {
+		date := day.Format(models.GuestPassDateLayout)
+		counts = append(counts, models.DailyCount{
+			Date:     date,
+			Members:  len(members[date]),
+			Checkins: checkins[date],
+		})
+	}
+	return counts
+}
+
+// isLocation returns true if scans may be recorded at location
+func (s *CheckinStore) isLocation(location string) bool {
+	for _, known := range s.locations {
+		if location == known {
+			return true
+		}
+	}
+	return false
+}
+
+// applyLocked updates who is present after an event; callers hold mu
+func (s *CheckinStore) applyLocked(event *models.CheckinEvent) {
+	key := strings.ToLower(event.Email)
+	if event.Direction == models.CheckinIn {
+		s.present[key] = event
+	} else {
+		delete(s.present, key)
+	}
+}
+
+// closeStaleLocked checks out everyone who checked in before the last closing time and returns the check-outs; callers hold mu
+// The check-out is dated at the closing time that followed the check-in, not at the time it was noticed
+func (s *CheckinStore) closeStaleLocked() []*models.CheckinEvent {
+	now := s.now()
+	lastClosing := startOfDay(now).Add(s.closing)
+	if lastClosing.After(now) {
+		lastClosing = startOfDay(now).AddDate(0, 0, -1).Add(s.closing)
+	}
+
+	closed := make([]*models.CheckinEvent, 0)
+	for key, checkin := range s.present {
+		if !checkin.At.Before(lastClosing) {
+			continue
+		}
+
+		closedAt := startOfDay(checkin.At).Add(s.closing)
+		if !closedAt.After(checkin.At) {
+			closedAt = startOfDay(checkin.At).AddDate(0, 0, 1).Add(s.closing)
+		}
+
+		id, err := randomHex(8)
+		if err != nil {
+			continue // Try again on the next call
+		}
+		checkout := &models.CheckinEvent{
+			ID:         id,
+			Email:      checkin.Email,
+			MemberName: checkin.MemberName,
+			Direction:  models.CheckinOut,
+			Location:   checkin.Location,
+			Device:     autoCheckoutDevice,
+			At:         closedAt.UTC(),
+			Auto:       true,
+		}
+		s.events = append(s.events, checkout)
+		delete(s.present, key)
+		closed = append(closed, checkout)
+	}
+
+	if len(closed) > 0 {
+		sortCheckins(s.events)
+	}
+	return closed
+}
+
+// closeStaleAndSaveLocked closes stale check-ins and saves them if there were any; callers hold mu
+// A failed save is not reported: the check-outs stay in memory and the file is rewritten with the next event
+func (s *CheckinStore) closeStaleAndSaveLocked() {
+	if closed := s.closeStaleLocked(); len(closed) > 0 {
+		_ = s.saveLocked(closed...)
+	}
+}
+
+// saveLocked appends new events to the file; callers hold mu
+// The file is rewritten instead after a failed append, or once the oldest event is well past the retention period
+func (s *CheckinStore) saveLocked(events ...*models.CheckinEvent) error {
+	if s.unsaved || s.events[0].At.Before(s.now().Add(-checkinRetention-checkinCompaction)) {
+		return s.compactLocked()
+	}
+
+	for _, event := range events {
+		if err := s.file.append(event); err != nil {
+			s.unsaved = true
+			return err
+		}
+	}
+	return nil
+}
+
+// compactLocked drops events older than the retention period and rewrites the file; callers hold mu
+func (s *CheckinStore) compactLocked() error {
+	cutoff := s.now().Add(-checkinRetention)
+	dropped := 0
+	for dropped < len(s.events) && s.events[dropped].At.Before(cutoff) {
+		dropped++
+	}
+	s.events = append([]*models.CheckinEvent(nil), s.events[dropped:]...)
+
+	values := make([]interface{}, len(s.events))
+	for i, event := range s.events {
+		values[i] = event
+	}
+	if err := s.file.save(values); err != nil {
+		s.unsaved = true
+		return err
+	}
+	s.unsaved = false
+	return nil
+}
+
+// sortCheckins orders events oldest first
+func sortCheckins(events []*models.CheckinEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		return events[i].At.Before(events[j].At)
+	})
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"errors"
+	"multipass/internal/models"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCheckinStore_Record(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkins.jsonl")
+	store, err := NewCheckinStore(path, []string{"front-desk", "wood-shop"}, 23*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create check-in store: %v", err)
+	}
+	now := time.Date(2025, 6, 2, 10, 0, 0, 0, time.Local)
+	store.now = func() time.Time { return now }
+
+	// Test cases
+	tests := []struct {
+		name              string
+		user              *models.UserProfile
+		location          string
+		direction         string
+		expectedDirection string
+		expectedLocation  string
+		expectedPresent   int
+		expectedErr       error
+	}{
+		{"First scan checks in", testMember, "", "", models.CheckinIn, "front-desk", 1, nil},
+		{"Another member", otherMember, "wood-shop", "", models.CheckinIn, "wood-shop", 2, nil},
+		{"Explicit check-in moves the member", testMember, "wood-shop", models.CheckinIn, models.CheckinIn, "wood-shop", 2, nil},
+		{"Second scan checks out", testMember, "front-desk", "", models.CheckinOut, "front-desk", 1, nil},
+		{"Unknown location", testMember, "roof", "", "", "", 1, ErrCheckinLocation},
+		{"Unknown direction", testMember, "", "sideways", "", "", 1, ErrCheckinDirection},
+	}
+
+	// Run tests
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			now = now.Add(time.Minute)
+			event, err := store.Record(tc.user, tc.location, tc.direction, "front desk iPad", "staff@example.com")
+			if !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("Expected error %v, got %v", tc.expectedErr, err)
+			}
+			if err == nil && (event.Direction != tc.expectedDirection || event.Location != tc.expectedLocation) {
+				t.Errorf("Expected %s at %s, got %+v", tc.expectedDirection, tc.expectedLocation, event)
+			}
+			if present := store.Present(); len(present) != tc.expectedPresent {
+				t.Errorf("Expected %d present, got %d", tc.expectedPresent, len(present))
+			}
+		})
+	}
+
+	// History is newest first and survives a restart
+	reloaded, err := NewCheckinStore(path, []string{"front-desk", "wood-shop"}, 23*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to reload check-ins: %v", err)
+	}
+	reloaded.now = store.now
+	history := reloaded.History("Member@Example.com", now.AddDate(0, 0, -1))
+	if len(history) != 3 || history[0].Direction != models.CheckinOut || history[0].Device != "front desk iPad" {
+		t.Errorf("Unexpected history: %+v", history)
+	}
+	if present := reloaded.Present(); len(present) != 1 || present[0].Email != otherMember.Email {
+		t.Errorf("Expected only the other member to be present after reload, got %+v", present)
+	}
+}
+
+func TestCheckinStore_AutoCheckout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkins.jsonl")
+	store, _ := NewCheckinStore(path, []string{"front-desk"}, 22*time.Hour)
+	now := time.Date(2025, 6, 2, 18, 0, 0, 0, time.Local)
+	store.now = func() time.Time { return now }
+
+	store.Record(testMember, "", "", "scanner", "")
+	now = now.Add(time.Hour)
+	store.Record(otherMember, "", "", "scanner", "")
+
+	// Before closing both are still in
+	now = time.Date(2025, 6, 2, 21, 59, 0, 0, time.Local)
+	if present := store.Present(); len(present) != 2 {
+		t.Fatalf("Expected 2 present before closing, got %d", len(present))
+	}
+
+	// After closing both are checked out, dated at closing time
+	now = time.Date(2025, 6, 3, 9, 0, 0, 0, time.Local)
+	if present := store.Present(); len(present) != 0 {
+		t.Fatalf("Expected nobody present after closing, got %d", len(present))
+	}
+	history := store.History(testMember.Email, time.Time{})
+	closing := time.Date(2025, 6, 2, 22, 0, 0, 0, time.Local)
+	if len(history) != 2 || !history[0].Auto || !history[0].At.Equal(closing) {
+		t.Errorf("Expected an automatic check-out at closing time, got %+v", history)
+	}
+
+	// The check-outs are written to the file, after the events they follow
+	reloaded, err := NewCheckinStore(path, []string{"front-desk"}, 22*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to reload check-ins: %v", err)
+	}
+	reloaded.now = store.now
+	if history := reloaded.History(otherMember.Email, time.Time{}); len(history) != 2 || !history[0].Auto {
+		t.Errorf("Expected the automatic check-out to survive a restart, got %+v", history)
+	}
+
+	// The next scan is a fresh check-in
+	event, _ := store.Record(testMember, "", "", "scanner", "")
+	if event.Direction != models.CheckinIn {
+		t.Errorf("Expected check-in on the next day, got %s", event.Direction)
+	}
+
+	// Daily counts cover every day in the range
+	counts := store.DailyCounts(time.Date(2025, 6, 1, 0, 0, 0, 0, time.Local), now)
+	expected := []models.DailyCount{
+		{Date: "2025-06-01", Members: 0, Checkins: 0},
+		{Date: "2025-06-02", Members: 2, Checkins: 2},
+		{Date: "2025-06-03", Members: 1, Checkins: 1},
+	}
+	if len(counts) != len(expected) {
+		t.Fatalf("Expected %d days, got %+v", len(expected), counts)
+	}
+	for i := range expected {
+		if counts[i] != expected[i] {
+			t.Errorf("Expected %+v, got %+v", expected[i], counts[i])
+		}
+	}
+}
+
+func TestCheckinStore_Retention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkins.jsonl")
+	store, _ := NewCheckinStore(path, []string{"front-desk"}, 23*time.Hour)
+	now := time.Date(2025, 6, 2, 10, 0, 0, 0, time.Local)
+	store.now = func() time.Time { return now }
+
+	store.Record(testMember, "", models.CheckinIn, "scanner", "")
+	store.Record(testMember, "", models.CheckinOut, "scanner", "")
+
+	// Recording past the compaction window drops the old events from memory and disk
+	now = now.Add(checkinRetention + 2*checkinCompaction)
+	store.Record(otherMember, "", models.CheckinIn, "scanner", "")
+
+	reloaded, err := NewCheckinStore(path, []string{"front-desk"}, 23*time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to reload check-ins: %v", err)
+	}
+	reloaded.now = store.now
+	if history := reloaded.History(testMember.Email, time.Time{}); len(history) != 0 {
+		t.Errorf("Expected old events to be dropped, got %+v", history)
+	}
+	if history := reloaded.History(otherMember.Email, time.Time{}); len(history) != 1 {
+		t.Errorf("Expected the recent event to be kept, got %+v", history)
+	}
+}
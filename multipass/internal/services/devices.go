@@ -0,0 +1,281 @@
+package services
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"multipass/internal/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Device binding errors
+var (
+	ErrDeviceLimit      = errors.New("too many registered devices; remove one first")
+	ErrDeviceNotFound   = errors.New("device not found")
+	ErrDeviceKey        = errors.New("device key must be an ECDSA P-256 public key")
+	ErrDeviceChallenge  = errors.New("device challenge is unknown or expired")
+	ErrDeviceSignature  = errors.New("device signature is invalid")
+	ErrDeviceNameLength = errors.New("device name is too long")
+)
+
+// Device binding limits
+const (
+	deviceLimit        = 5                // Devices per member
+	deviceNameMax      = 100              // Characters in a device label
+	deviceChallengeTTL = 2 * time.Minute  // How long a challenge may be signed and returned
+	deviceChallengeMax = 10000            // Outstanding challenges kept in memory
+	deviceUsedInterval = 10 * time.Minute // Limits how often last-used timestamps are written to disk
+)
+
+// deviceChallenge is a one-time challenge issued for a member's card
+type deviceChallenge struct {
+	email     string
+	expiresAt time.Time
+}
+
+// DeviceStore keeps the public keys of members' registered browsers in a JSON file in the data directory
+// Challenges are kept in memory only; a restart just makes open cards ask for a new one
+type DeviceStore struct {
+	mu         sync.Mutex
+	file       jsonFile
+	devices    map[string]*models.Device
+	challenges map[string]deviceChallenge
+	now        func() time.Time
+}
+
+// NewDeviceStore loads the devices stored at path
+func NewDeviceStore(path string) (*DeviceStore, error) {
+	store := &DeviceStore{
+		file:       jsonFile{path: path},
+		devices:    make(map[string]*models.Device),
+		challenges: make(map[string]deviceChallenge),
+		now:        time.Now,
+	}
+
+	var devices []*models.Device
+	if err := store.file.load(&devices); err != nil {
+		return nil, err
+	}
+	for _, device := range devices {
+		store.devices[device.ID] = device
+	}
+
+	return store, nil
+}
+
+// Register records a device's public key for the member
+func (s *DeviceStore) Register(email, name string, key models.DevicePublicKey) (*models.Device, error) {
+	if _, err := parseDeviceKey(key); err != nil {
+		return nil, err
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = "Unnamed device"
+	}
+	if len(name) > deviceNameMax {
+		return nil, ErrDeviceNameLength
+	}
+
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.countLocked(email) >= deviceLimit {
+		return nil, ErrDeviceLimit
+	}
+
+	device := &models.Device{
+		ID:        id,
+		Email:     email,
+		Name:      name,
+		PublicKey: key,
+		CreatedAt: s.now().UTC(),
+	}
+	s.devices[id] = device
+	if err := s.file.save(s.listLocked("")); err != nil {
+		delete(s.devices, id)
+		return nil, err
+	}
+
+	entry := *device
+	return &entry, nil
+}
+
+// List returns the member's devices, most recently registered first
+func (s *DeviceStore) List(email string) []*models.Device {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listLocked(email)
+}
+
+// Remove deletes one of the member's devices
+func (s *DeviceStore) Remove(id, email string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	device, ok := s.devices[id]
+	if !ok || !strings.EqualFold(device.Email, email) {
+		return ErrDeviceNotFound
+	}
+
+	delete(s.devices, id)
+	return s.file.save(s.listLocked(""))
+}
+
+// Reset deletes every device of the member and returns how many were removed
+func (s *DeviceStore) Reset(email string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	removed := 0
+	for id, device := range s.devices {
+		if strings.EqualFold(device.Email, email) {
+			delete(s.devices, id)
+			removed++
+		}
+	}
+	if removed == 0 {
+		return 0, nil
+	}
+
+	return removed, s.file.save(s.listLocked(""))
+}
+
+// Challenge issues a one-time challenge that one of the member's devices must sign
+func (s *DeviceStore) Challenge(email string) (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate device challenge: %w", err)
+	}
+	challenge := base64.RawURLEncoding.EncodeToString(nonce)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop expired challenges, and refuse to grow without bound if cards are polled in a loop
+	now := s.now()
+	for key, entry := range s.challenges {
+		if now.After(entry.expiresAt) {
+			delete(s.challenges, key)
+		}
+	}
+	if len(s.challenges) >= deviceChallengeMax {
+		return "", errors.New("too many outstanding device challenges")
+	}
+
+	s.challenges[challenge] = deviceChallenge{email: email, expiresAt: now.Add(deviceChallengeTTL)}
+	return challenge, nil
+}
+
+// VerifyChallenge checks that the device belongs to the member and signed the challenge
+// The challenge is used up whether or not the signature checks out
+// signature is the base64url IEEE P1363 (r || s) signature WebCrypto produces over the challenge string
+func (s *DeviceStore) VerifyChallenge(email, deviceID, challenge, signature string) (*models.Device, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.challenges[challenge]
+	delete(s.challenges, challenge)
+	if !ok || s.now().After(entry.expiresAt) || !strings.EqualFold(entry.email, email) {
+		return nil, ErrDeviceChallenge
+	}
+
+	device, ok := s.devices[deviceID]
+	if !ok || !strings.EqualFold(device.Email, email) {
+		return nil, ErrDeviceNotFound
+	}
+
+	key, err := parseDeviceKey(device.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || len(sig) != 64 {
+		return nil, ErrDeviceSignature
+	}
+	digest := sha256.Sum256([]byte(challenge))
+	r := new(big.Int).SetBytes(sig[:32])
+	sv := new(big.Int).SetBytes(sig[32:])
+	if !ecdsa.Verify(key, digest[:], r, sv) {
+		return nil, ErrDeviceSignature
+	}
+
+	// Cards poll every few seconds, so the last-used time is only saved now and then
+	now := s.now().UTC()
+	persist := device.LastUsedAt == nil || now.Sub(*device.LastUsedAt) >= deviceUsedInterval
+	if persist {
+		device.LastUsedAt = &now
+	}
+
+	result := *device
+	if persist {
+		return &result, s.file.save(s.listLocked(""))
+	}
+	return &result, nil
+}
+
+// countLocked counts the member's devices; callers hold mu
+func (s *DeviceStore) countLocked(email string) int {
+	count := 0
+	for _, device := range s.devices {
+		if strings.EqualFold(device.Email, email) {
+			count++
+		}
+	}
+	return count
+}
+
+// listLocked returns copies of the member's devices, or of all devices if email is empty; callers hold mu
+func (s *DeviceStore) listLocked(email string) []*models.Device {
+	devices := make([]*models.Device, 0)
+	for _, device := range s.devices {
+		if email == "" || strings.EqualFold(device.Email, email) {
+			entry := *device
+			devices = append(devices, &entry)
+		}
+	}
+
+	sort.Slice(devices, func(i, j int) bool {
+		return devices[i].CreatedAt.After(devices[j].CreatedAt)
+	})
+	return devices
+}
+
+// parseDeviceKey converts a JWK public key into an ECDSA key, rejecting points that are not on P-256
+func parseDeviceKey(key models.DevicePublicKey) (*ecdsa.PublicKey, error) {
+	if key.Kty != "EC" || key.Crv != "P-256" {
+		return nil, ErrDeviceKey
+	}
+
+	x, errX := base64.RawURLEncoding.DecodeString(key.X)
+	y, errY := base64.RawURLEncoding.DecodeString(key.Y)
+	if errX != nil || errY != nil || len(x) != 32 || len(y) != 32 {
+		return nil, ErrDeviceKey
+	}
+
+	// crypto/ecdh validates that the point is on the curve
+	point := append(append([]byte{4}, x...), y...)
+	if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+		return nil, ErrDeviceKey
+	}
+
+	return &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}, nil
+}
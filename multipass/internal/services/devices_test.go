@@ -0,0 +1,183 @@
+package services
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"multipass/internal/models"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestDeviceKey creates a P-256 key and its public half in the JWK form browsers export
+func newTestDeviceKey(t *testing.T) (*ecdsa.PrivateKey, models.DevicePublicKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate device key: %v", err)
+	}
+
+	return key, models.DevicePublicKey{
+		Kty: "EC",
+		Crv: "P-256",
+		X:   base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		Y:   base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+// signTestChallenge signs a challenge the way WebCrypto does, as raw r || s
+func signTestChallenge(t *testing.T, key *ecdsa.PrivateKey, challenge string) string {
+	digest := sha256.Sum256([]byte(challenge))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatalf("Failed to sign challenge: %v", err)
+	}
+
+	sig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	return base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestDeviceStore_Challenge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devices.json")
+	store, _ := NewDeviceStore(path)
+	key, publicKey := newTestDeviceKey(t)
+	otherKey, _ := newTestDeviceKey(t)
+
+	device, err := store.Register("member@example.com", "Phone", publicKey)
+	if err != nil {
+		t.Fatalf("Failed to register device: %v", err)
+	}
+
+	// Test cases
+	tests := []struct {
+		name        string
+		email       string
+		deviceID    string
+		sign        func(challenge string) string
+		expectedErr error
+	}{
+		{
+			name:     "Registered device",
+			email:    "member@example.com",
+			deviceID: device.ID,
+			sign:     func(challenge string) string { return signTestChallenge(t, key, challenge) },
+		},
+		{
+			name:        "Different key",
+			email:       "member@example.com",
+			deviceID:    device.ID,
+			sign:        func(challenge string) string { return signTestChallenge(t, otherKey, challenge) },
+			expectedErr: ErrDeviceSignature,
+		},
+		{
+			name:        "Unknown device",
+			email:       "member@example.com",
+			deviceID:    "unknown",
+			sign:        func(challenge string) string { return signTestChallenge(t, key, challenge) },
+			expectedErr: ErrDeviceNotFound,
+		},
+		{
+			name:        "Malformed signature",
+			email:       "member@example.com",
+			deviceID:    device.ID,
+			sign:        func(challenge string) string { return "not-a-signature" },
+			expectedErr: ErrDeviceSignature,
+		},
+	}
+
+	// Run tests
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			challenge, err := store.Challenge(tc.email)
+			if err != nil {
+				t.Fatalf("Failed to issue challenge: %v", err)
+			}
+
+			_, err = store.VerifyChallenge(tc.email, tc.deviceID, challenge, tc.sign(challenge))
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Expected %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+
+	// A challenge can only be used once, and only for the member it was issued for
+	challenge, _ := store.Challenge("member@example.com")
+	signature := signTestChallenge(t, key, challenge)
+	if _, err := store.VerifyChallenge("member@example.com", device.ID, challenge, signature); err != nil {
+		t.Fatalf("Expected first use to succeed: %v", err)
+	}
+	if _, err := store.VerifyChallenge("member@example.com", device.ID, challenge, signature); !errors.Is(err, ErrDeviceChallenge) {
+		t.Errorf("Expected replayed challenge to be rejected, got %v", err)
+	}
+	challenge, _ = store.Challenge("other@example.com")
+	if _, err := store.VerifyChallenge("member@example.com", device.ID, challenge, signTestChallenge(t, key, challenge)); !errors.Is(err, ErrDeviceChallenge) {
+		t.Errorf("Expected another member's challenge to be rejected, got %v", err)
+	}
+
+	// Challenges expire
+	challenge, _ = store.Challenge("member@example.com")
+	store.now = func() time.Time { return time.Now().Add(deviceChallengeTTL + time.Second) }
+	if _, err := store.VerifyChallenge("member@example.com", device.ID, challenge, signTestChallenge(t, key, challenge)); !errors.Is(err, ErrDeviceChallenge) {
+		t.Errorf("Expected expired challenge to be rejected, got %v", err)
+	}
+
+	// Devices survive a restart
+	reloaded, err := NewDeviceStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reload devices: %v", err)
+	}
+	if devices := reloaded.List("Member@Example.com"); len(devices) != 1 || devices[0].LastUsedAt == nil {
+		t.Errorf("Expected the used device after reload, got %+v", devices)
+	}
+}
+
+func TestDeviceStore_RegisterRemoveReset(t *testing.T) {
+	store, _ := NewDeviceStore(filepath.Join(t.TempDir(), "devices.json"))
+	_, publicKey := newTestDeviceKey(t)
+
+	// Keys that are not P-256 points are refused
+	badKey := publicKey
+	badKey.Y = badKey.X
+	if _, err := store.Register("member@example.com", "Phone", badKey); !errors.Is(err, ErrDeviceKey) {
+		t.Errorf("Expected invalid key to be rejected, got %v", err)
+	}
+
+	// Members have a limited number of devices
+	var first *models.Device
+	for i := 0; i < deviceLimit; i++ {
+		device, err := store.Register("member@example.com", "", publicKey)
+		if err != nil {
+			t.Fatalf("Failed to register device %d: %v", i, err)
+		}
+		if first == nil {
+			first = device
+		}
+	}
+	if _, err := store.Register("member@example.com", "Laptop", publicKey); !errors.Is(err, ErrDeviceLimit) {
+		t.Errorf("Expected device limit, got %v", err)
+	}
+	if first.Name != "Unnamed device" {
+		t.Errorf("Expected default device name, got %q", first.Name)
+	}
+
+	// Members can only remove their own devices
+	if err := store.Remove(first.ID, "other@example.com"); !errors.Is(err, ErrDeviceNotFound) {
+		t.Errorf("Expected another member's remove to fail, got %v", err)
+	}
+	if err := store.Remove(first.ID, "member@example.com"); err != nil {
+		t.Fatalf("Failed to remove device: %v", err)
+	}
+
+	// Staff reset removes the rest
+	store.Register("other@example.com", "Phone", publicKey)
+	removed, err := store.Reset("member@example.com")
+	if err != nil || removed != deviceLimit-1 {
+		t.Errorf("Expected %d devices reset, got %d (%v)", deviceLimit-1, removed, err)
+	}
+	if devices := store.List("other@example.com"); len(devices) != 1 {
+		t.Errorf("Expected other member's device to remain, got %d", len(devices))
+	}
+}
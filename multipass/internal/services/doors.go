@@ -0,0 +1,55 @@
+package services
+
+import (
+	"fmt"
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"sort"
+	"time"
+)
+
+// door is a door access decisions can be asked for
+type door struct {
+	minLevel models.UserLevel
+	unlock   time.Duration
+}
+
+// DoorPolicy holds the minimum user level and unlock time of each configured door
+type DoorPolicy struct {
+	doors map[string]door
+}
+
+// NewDoorPolicy builds the policy from ACCESS_DOORS
+// Unknown level names are an error rather than NoAccess, which would leave the door open to every active member
+func NewDoorPolicy(cfg *config.Config) (*DoorPolicy, error) {
+	policy := &DoorPolicy{doors: make(map[string]door)}
+	for id, doorCfg := range cfg.AccessDoors {
+		level, ok := models.ParseUserLevel(doorCfg.MinLevel)
+		if !ok {
+			return nil, fmt.Errorf("door %s requires unknown level %q", id, doorCfg.MinLevel)
+		}
+
+		unlock := doorCfg.Unlock
+		if unlock == 0 {
+			unlock = cfg.AccessUnlockDuration
+		}
+		policy.doors[id] = door{minLevel: level, unlock: unlock}
+	}
+	return policy, nil
+}
+
+// Door returns the minimum level and unlock time of a door; ok is false for unknown doors
+func (p *DoorPolicy) Door(id string) (minLevel models.UserLevel, unlock time.Duration, ok bool) {
+	d, ok := p.doors[id]
+	return d.minLevel, d.unlock, ok
+}
+
+// Doors returns the IDs of the configured doors in alphabetical order
+func (p *DoorPolicy) Doors() []string {
+	ids := make([]string, 0, len(p.doors))
+	for id := range p.doors {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"testing"
+	"time"
+)
+
+func TestNewDoorPolicy(t *testing.T) {
+	cfg := &config.Config{
+		AccessUnlockDuration: 5 * time.Second,
+		AccessDoors: map[string]config.DoorConfig{
+			"front-door": {MinLevel: "LimitedVolunteer"},
+			"wood-shop":  {MinLevel: "FullMember", Unlock: 10 * time.Second},
+		},
+	}
+	policy, err := NewDoorPolicy(cfg)
+	if err != nil {
+		t.Fatalf("Failed to build door policy: %v", err)
+	}
+
+	// Test cases
+	tests := []struct {
+		door           string
+		expectedLevel  models.UserLevel
+		expectedUnlock time.Duration
+		expectedOK     bool
+	}{
+		{"front-door", models.LimitedVolunteer, 5 * time.Second, true},
+		{"wood-shop", models.FullMember, 10 * time.Second, true},
+		{"roof", models.NoAccess, 0, false},
+	}
+
+	// Run tests
+	for _, tc := range tests {
+		t.Run(tc.door, func(t *testing.T) {
+			level, unlock, ok := policy.Door(tc.door)
+			if level != tc.expectedLevel || unlock != tc.expectedUnlock || ok != tc.expectedOK {
+				t.Errorf("Expected %v %v %v, got %v %v %v", tc.expectedLevel, tc.expectedUnlock, tc.expectedOK, level, unlock, ok)
+			}
+		})
+	}
+
+	// A typo in a level name must not leave the door open
+	cfg.AccessDoors["roof"] = config.DoorConfig{MinLevel: "Members"}
+	if _, err := NewDoorPolicy(cfg); err == nil {
+		t.Error("Expected an error for an unknown level name")
+	}
+}
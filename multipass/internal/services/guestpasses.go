@@ -0,0 +1,240 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"multipass/internal/models"
+	"multipass/internal/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Guest pass errors
+var (
+	ErrGuestPassQuota    = errors.New("monthly guest pass quota reached")
+	ErrGuestPassDate     = errors.New("guest passes can only be issued for today or the next 30 days")
+	ErrGuestPassNotFound = errors.New("guest pass not found")
+	ErrGuestPassNotToday = errors.New("guest pass is not valid today")
+)
+
+// Reasons a scanned guest pass is refused, besides the token failure reasons
+const (
+	GuestReasonNotFound = "guest_pass_unknown" // A valid guest token whose pass was not recorded here
+	GuestReasonNotToday = "not_today"          // The pass is for another day
+)
+
+// Guest pass limits
+const (
+	guestPassMaxAdvance = 30                   // Days ahead a pass may be issued for
+	guestPassRetention  = 400 * 24 * time.Hour // Visit records are kept for a little over a year
+)
+
+// GuestPassStore keeps issued guest passes and their visits in a JSON file in the data directory
+type GuestPassStore struct {
+	mu     sync.Mutex
+	file   jsonFile
+	passes map[string]*models.GuestPass
+	now    func() time.Time
+}
+
+// NewGuestPassStore loads the guest passes stored at path
+func NewGuestPassStore(path string) (*GuestPassStore, error) {
+	store := &GuestPassStore{
+		file:   jsonFile{path: path},
+		passes: make(map[string]*models.GuestPass),
+		now:    time.Now,
+	}
+
+	var passes []*models.GuestPass
+	if err := store.file.load(&passes); err != nil {
+		return nil, err
+	}
+	for _, pass := range passes {
+		store.passes[pass.ID] = pass
+	}
+
+	return store, nil
+}
+
+// Issue signs a guest token valid until the end of the visit date and records the pass
+// quota is the sponsor's monthly allowance, counted against passes for the same month as the visit
+func (s *GuestPassStore) Issue(tokens *TokenService, sponsor *models.UserProfile, guestName string, visitDate time.Time, quota int) (string, *models.GuestPass, error) {
+	guestName = strings.TrimSpace(guestName)
+	if guestName == "" {
+		return "", nil, errors.New("guest name is required")
+	}
+
+	// The pass is valid from the start of the visit date until midnight
+	today := startOfDay(s.now())
+	day := startOfDay(visitDate)
+	if day.Before(today) || day.After(today.AddDate(0, 0, guestPassMaxAdvance)) {
+		return "", nil, ErrGuestPassDate
+	}
+	expiresAt := day.AddDate(0, 0, 1)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.countLocked(sponsor.Email, day) >= quota {
+		return "", nil, ErrGuestPassQuota
+	}
+
+	token, data, err := tokens.IssueFor(sponsor, utils.PurposeGuest, expiresAt.Sub(s.now()))
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to sign guest pass: %w", err)
+	}
+
+	pass := &models.GuestPass{
+		ID:           data.ID,
+		GuestName:    guestName,
+		SponsorEmail: sponsor.Email,
+		SponsorName:  sponsor.FullName,
+		VisitDate:    day.Format(models.GuestPassDateLayout),
+		IssuedAt:     data.Timestamp,
+		ExpiresAt:    data.ExpiresAt,
+	}
+	s.passes[pass.ID] = pass
+	if err := s.saveLocked(); err != nil {
+		delete(s.passes, pass.ID)
+		return "", nil, err
+	}
+
+	entry := *pass
+	return token, &entry, nil
+}
+
+// Used returns how many passes the sponsor has issued for visits in the month of date
+func (s *GuestPassStore) Used(email string, date time.Time) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.countLocked(email, date)
+}
+
+// Get returns the pass with the token ID
+func (s *GuestPassStore) Get(id string) (*models.GuestPass, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pass, ok := s.passes[id]
+	if !ok {
+		return nil, ErrGuestPassNotFound
+	}
+	entry := *pass
+	return &entry, nil
+}
+
+// RecordVisit counts a verification of the pass with the token ID and returns it
+// The pass is only accepted on its visit date
+func (s *GuestPassStore) RecordVisit(id string) (*models.GuestPass, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pass, ok := s.passes[id]
+	if !ok {
+		return nil, ErrGuestPassNotFound
+	}
+
+	now := s.now()
+	if !pass.ValidOn(now) {
+		entry := *pass
+		return &entry, ErrGuestPassNotToday
+	}
+
+	pass.Visits++
+	if pass.VisitedAt == nil {
+		visitedAt := now.UTC()
+		pass.VisitedAt = &visitedAt
+	}
+
+	// The visit is kept in memory even if it cannot be saved
+	entry := *pass
+	return &entry, s.saveLocked()
+}
+
+// ListBySponsor returns the sponsor's passes for visits from the start of the current month, newest visit first
+func (s *GuestPassStore) ListBySponsor(email string) []*models.GuestPass {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	since := startOfMonth(s.now()).Format(models.GuestPassDateLayout)
+	passes := make([]*models.GuestPass, 0)
+	for _, pass := range s.passes {
+		if strings.EqualFold(pass.SponsorEmail, email) && pass.VisitDate >= since {
+			entry := *pass
+			passes = append(passes, &entry)
+		}
+	}
+
+	sortGuestPasses(passes)
+	return passes
+}
+
+// ListMonth returns every pass for visits in the month of date, newest visit first
+func (s *GuestPassStore) ListMonth(date time.Time) []*models.GuestPass {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	month := date.Local().Format("2006-01")
+	passes := make([]*models.GuestPass, 0)
+	for _, pass := range s.passes {
+		if strings.HasPrefix(pass.VisitDate, month) {
+			entry := *pass
+			passes = append(passes, &entry)
+		}
+	}
+
+	sortGuestPasses(passes)
+	return passes
+}
+
+// countLocked counts the sponsor's passes for visits in the month of date; callers hold mu
+func (s *GuestPassStore) countLocked(email string, date time.Time) int {
+	month := date.Local().Format("2006-01")
+	count := 0
+	for _, pass := range s.passes {
+		if strings.EqualFold(pass.SponsorEmail, email) && strings.HasPrefix(pass.VisitDate, month) {
+			count++
+		}
+	}
+	return count
+}
+
+// saveLocked drops passes older than the retention period and writes the store; callers hold mu
+func (s *GuestPassStore) saveLocked() error {
+	cutoff := s.now().Add(-guestPassRetention)
+	passes := make([]*models.GuestPass, 0, len(s.passes))
+	for id, pass := range s.passes {
+		if pass.ExpiresAt.Before(cutoff) {
+			delete(s.passes, id)
+			continue
+		}
+		passes = append(passes, pass)
+	}
+
+	return s.file.save(passes)
+}
+
+// sortGuestPasses orders passes by visit date, newest first, then by issue time
+func sortGuestPasses(passes []*models.GuestPass) {
+	sort.Slice(passes, func(i, j int) bool {
+		if passes[i].VisitDate != passes[j].VisitDate {
+			return passes[i].VisitDate > passes[j].VisitDate
+		}
+		return passes[i].IssuedAt.After(passes[j].IssuedAt)
+	})
+}
+
+// startOfDay returns midnight at the start of t's day in the local time zone
+func startOfDay(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.Local)
+}
+
+// startOfMonth returns midnight on the first day of t's month in the local time zone
+func startOfMonth(t time.Time) time.Time {
+	t = t.Local()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.Local)
+}
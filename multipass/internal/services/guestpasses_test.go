@@ -0,0 +1,123 @@
+package services
+
+import (
+	"errors"
+	"multipass/internal/models"
+	"multipass/internal/utils"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestGuestPassStore_IssueAndVisit(t *testing.T) {
+	tokens := newTestTokenService(t)
+	path := filepath.Join(t.TempDir(), "guest_passes.json")
+	store, _ := NewGuestPassStore(path)
+	sponsor := &models.UserProfile{MemberID: "42", Email: "member@example.com", FullName: "Ada Member"}
+
+	token, pass, err := store.Issue(tokens, sponsor, "  Grace Guest ", time.Now(), 2)
+	if err != nil {
+		t.Fatalf("Failed to issue guest pass: %v", err)
+	}
+	if pass.GuestName != "Grace Guest" || pass.SponsorName != "Ada Member" {
+		t.Errorf("Unexpected guest pass: %+v", pass)
+	}
+
+	// The token is a guest token that expires at the end of the visit date
+	data, err := tokens.Verify(token, utils.PurposeGuest)
+	if err != nil {
+		t.Fatalf("Failed to verify guest token: %v", err)
+	}
+	if data.ID != pass.ID || data.UserID != sponsor.MemberID {
+		t.Errorf("Unexpected guest token data: %+v", data)
+	}
+	if validFor := data.ExpiresAt.Sub(data.Timestamp); validFor > 24*time.Hour {
+		t.Errorf("Expected guest token to expire within a day, got %v", validFor)
+	}
+	if _, err := tokens.Verify(token, utils.PurposeCard); !errors.Is(err, ErrTokenPurpose) {
+		t.Errorf("Expected guest token to be refused for the card, got %v", err)
+	}
+
+	// The first verification records the visit
+	visited, err := store.RecordVisit(pass.ID)
+	if err != nil {
+		t.Fatalf("Failed to record visit: %v", err)
+	}
+	if visited.Visits != 1 || visited.VisitedAt == nil {
+		t.Errorf("Expected visit to be recorded, got %+v", visited)
+	}
+
+	// Visits survive a restart
+	reloaded, err := NewGuestPassStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reload store: %v", err)
+	}
+	passes := reloaded.ListBySponsor("Member@Example.com")
+	if len(passes) != 1 || passes[0].VisitedAt == nil {
+		t.Errorf("Expected the visited pass after reload, got %+v", passes)
+	}
+
+	if _, err := reloaded.RecordVisit("0000000000000000"); !errors.Is(err, ErrGuestPassNotFound) {
+		t.Errorf("Expected not found for an unknown pass, got %v", err)
+	}
+}
+
+func TestGuestPassStore_Quota(t *testing.T) {
+	tokens := newTestTokenService(t)
+	store, _ := NewGuestPassStore(filepath.Join(t.TempDir(), "guest_passes.json"))
+	sponsor := &models.UserProfile{MemberID: "42", Email: "member@example.com", FullName: "Ada Member"}
+	other := &models.UserProfile{MemberID: "43", Email: "other@example.com", FullName: "Other Member"}
+
+	for i := 0; i < 2; i++ {
+		if _, _, err := store.Issue(tokens, sponsor, "Guest", time.Now(), 2); err != nil {
+			t.Fatalf("Pass %d should have been allowed: %v", i+1, err)
+		}
+	}
+	if _, _, err := store.Issue(tokens, sponsor, "Guest", time.Now(), 2); !errors.Is(err, ErrGuestPassQuota) {
+		t.Errorf("Expected quota error, got %v", err)
+	}
+	if used := store.Used("member@example.com", time.Now()); used != 2 {
+		t.Errorf("Expected 2 passes used, got %d", used)
+	}
+
+	// Quotas are per sponsor; a zero quota disables passes
+	if _, _, err := store.Issue(tokens, other, "Guest", time.Now(), 2); err != nil {
+		t.Errorf("Expected another member's pass to be allowed: %v", err)
+	}
+	if _, _, err := store.Issue(tokens, other, "Guest", time.Now(), 0); !errors.Is(err, ErrGuestPassQuota) {
+		t.Errorf("Expected zero quota to refuse passes, got %v", err)
+	}
+}
+
+func TestGuestPassStore_Dates(t *testing.T) {
+	tokens := newTestTokenService(t)
+	store, _ := NewGuestPassStore(filepath.Join(t.TempDir(), "guest_passes.json"))
+	sponsor := &models.UserProfile{MemberID: "42", Email: "member@example.com", FullName: "Ada Member"}
+
+	// Test cases
+	testCases := []struct {
+		name      string
+		visitDate time.Time
+		err       error
+	}{
+		{name: "Yesterday", visitDate: time.Now().AddDate(0, 0, -1), err: ErrGuestPassDate},
+		{name: "Too far ahead", visitDate: time.Now().AddDate(0, 0, guestPassMaxAdvance+1), err: ErrGuestPassDate},
+		{name: "Next week", visitDate: time.Now().AddDate(0, 0, 7), err: nil},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, err := store.Issue(tokens, sponsor, "Guest", tc.visitDate, 10)
+			if !errors.Is(err, tc.err) {
+				t.Errorf("Expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+
+	// A pass for another day is not accepted today
+	_, pass, _ := store.Issue(tokens, sponsor, "Guest", time.Now().AddDate(0, 0, 1), 10)
+	if _, err := store.RecordVisit(pass.ID); !errors.Is(err, ErrGuestPassNotToday) {
+		t.Errorf("Expected pass for tomorrow to be refused today, got %v", err)
+	}
+}
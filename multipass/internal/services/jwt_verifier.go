@@ -0,0 +1,70 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+)
+
+// JWTVerifier verifies identity JWTs against a remote JWKS
+// Keys are cached and only refetched when a token uses an unknown key ID
+type JWTVerifier struct {
+	verifier *oidc.IDTokenVerifier
+}
+
+// NewJWTVerifier creates a verifier checking signature, issuer, audience and expiry
+func NewJWTVerifier(issuer, jwksURL, audience string) *JWTVerifier {
+	keySet := oidc.NewRemoteKeySet(context.Background(), jwksURL)
+
+	return &JWTVerifier{
+		verifier: oidc.NewVerifier(issuer, keySet, &oidc.Config{
+			ClientID: audience,
+			SupportedSigningAlgs: []string{
+				oidc.RS256, oidc.RS384, oidc.RS512,
+				oidc.ES256, oidc.ES384, oidc.ES512,
+				oidc.EdDSA,
+			},
+		}),
+	}
+}
+
+// NewCloudflareAccessVerifier creates a verifier for Cf-Access-Jwt-Assertion headers
+// The team domain may be given with or without a scheme; https is assumed when missing
+func NewCloudflareAccessVerifier(teamDomain, audience string) *JWTVerifier {
+	issuer := strings.TrimSuffix(teamDomain, "/")
+	if !strings.HasPrefix(issuer, "http://") && !strings.HasPrefix(issuer, "https://") {
+		issuer = "https://" + issuer
+	}
+
+	return NewJWTVerifier(issuer, issuer+"/cdn-cgi/access/certs", audience)
+}
+
+// Verify validates the raw JWT and returns the identity claims it carries
+func (jv *JWTVerifier) Verify(ctx context.Context, rawToken string) (*OIDCIdentity, error) {
+	if rawToken == "" {
+		return nil, errors.New("token is empty")
+	}
+
+	token, err := jv.verifier.Verify(ctx, rawToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify token: %w", err)
+	}
+
+	var claims map[string]interface{}
+	if err := token.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse token claims: %w", err)
+	}
+
+	identity := &OIDCIdentity{
+		Subject:  token.Subject,
+		Email:    stringClaim(claims, "email"),
+		Name:     stringClaim(claims, "name"),
+		Username: stringClaim(claims, "preferred_username"),
+		Groups:   stringListClaim(claims, "groups"),
+	}
+
+	return identity, nil
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// newTestJWKS serves the public half of a freshly generated ES256 key and counts fetches
+func newTestJWKS(t *testing.T, kid string) (*httptest.Server, *ecdsa.PrivateKey, *int32) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate signing key: %v", err)
+	}
+
+	var fetches int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: kid, Algorithm: "ES256", Use: "sig"},
+		}})
+	}))
+	t.Cleanup(server.Close)
+
+	return server, key, &fetches
+}
+
+// signTestJWT signs the claims with the given ES256 key
+func signTestJWT(t *testing.T, key *ecdsa.PrivateKey, kid string, claims jwt.Claims, extra map[string]interface{}) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key},
+		(&jose.SignerOptions{}).WithHeader("kid", kid))
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	token, err := jwt.Signed(signer).Claims(claims).Claims(extra).Serialize()
+	if err != nil {
+		t.Fatalf("Failed to sign token: %v", err)
+	}
+	return token
+}
+
+func TestJWTVerifier_Verify(t *testing.T) {
+	server, key, fetches := newTestJWKS(t, "outpost-key")
+	issuer := "https://login.example.com/application/o/multipass/"
+	verifier := NewJWTVerifier(issuer, server.URL, "multipass-client")
+
+	now := time.Now()
+	valid := jwt.Claims{
+		Issuer:   issuer,
+		Subject:  "abc123",
+		Audience: jwt.Audience{"multipass-client"},
+		Expiry:   jwt.NewNumericDate(now.Add(5 * time.Minute)),
+	}
+	extra := map[string]interface{}{
+		"email":  "maker@example.com",
+		"name":   "Maker Person",
+		"groups": []string{"Members"},
+	}
+
+	// Test cases
+	testCases := []struct {
+		name      string
+		mutate    func(c *jwt.Claims)
+		expectErr bool
+	}{
+		{
+			name:      "Valid token",
+			mutate:    func(c *jwt.Claims) {},
+			expectErr: false,
+		},
+		{
+			name:      "Wrong issuer",
+			mutate:    func(c *jwt.Claims) { c.Issuer = "https://evil.example.com/" },
+			expectErr: true,
+		},
+		{
+			name:      "Wrong audience",
+			mutate:    func(c *jwt.Claims) { c.Audience = jwt.Audience{"another-app"} },
+			expectErr: true,
+		},
+		{
+			name:      "Expired token",
+			mutate:    func(c *jwt.Claims) { c.Expiry = jwt.NewNumericDate(now.Add(-time.Minute)) },
+			expectErr: true,
+		},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			claims := valid
+			tc.mutate(&claims)
+
+			identity, err := verifier.Verify(context.Background(), signTestJWT(t, key, "outpost-key", claims, extra))
+			if tc.expectErr {
+				if err == nil {
+					t.Error("Expected verification to fail, but it succeeded")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Failed to verify token: %v", err)
+			}
+			if identity.Email != "maker@example.com" || identity.Subject != "abc123" {
+				t.Errorf("Unexpected identity: %+v", identity)
+			}
+			if len(identity.Groups) != 1 || identity.Groups[0] != "Members" {
+				t.Errorf("Unexpected groups: %v", identity.Groups)
+			}
+		})
+	}
+
+	// Keys are fetched once and then served from the cache
+	if n := atomic.LoadInt32(fetches); n != 1 {
+		t.Errorf("Expected JWKS to be fetched once, got %d fetches", n)
+	}
+}
+
+func TestJWTVerifier_RejectsUnknownKey(t *testing.T) {
+	server, _, _ := newTestJWKS(t, "outpost-key")
+	issuer := "https://login.example.com/application/o/multipass/"
+	verifier := NewJWTVerifier(issuer, server.URL, "multipass-client")
+
+	// Sign with a key that is not published in the JWKS
+	otherKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	token := signTestJWT(t, otherKey, "outpost-key", jwt.Claims{
+		Issuer:   issuer,
+		Subject:  "abc123",
+		Audience: jwt.Audience{"multipass-client"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}, map[string]interface{}{"email": "mallory@example.com"})
+
+	if _, err := verifier.Verify(context.Background(), token); err == nil {
+		t.Error("Expected token signed with an unknown key to be rejected")
+	}
+
+	if _, err := verifier.Verify(context.Background(), ""); err == nil {
+		t.Error("Expected empty token to be rejected")
+	}
+}
+
+func TestCloudflareAccessVerifier_Verify(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate signing key: %v", err)
+	}
+
+	// Local stand-in for the team's Access certs endpoint
+	mux := http.NewServeMux()
+	mux.HandleFunc("/cdn-cgi/access/certs", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &key.PublicKey, KeyID: "cf-key", Algorithm: "ES256", Use: "sig"},
+		}})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	audienceTag := "4714c1358e65fe4b408ad6d432a5f878f08194bdb4752441fd56faefa9b2b6f2"
+	verifier := NewCloudflareAccessVerifier(server.URL+"/", audienceTag)
+
+	claims := jwt.Claims{
+		Issuer:   server.URL,
+		Subject:  "cf-user-id",
+		Audience: jwt.Audience{audienceTag},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Minute)),
+	}
+
+	identity, err := verifier.Verify(context.Background(),
+		signTestJWT(t, key, "cf-key", claims, map[string]interface{}{"email": "maker@example.com"}))
+	if err != nil {
+		t.Fatalf("Failed to verify Access token: %v", err)
+	}
+	if identity.Email != "maker@example.com" {
+		t.Errorf("Expected email maker@example.com, got %s", identity.Email)
+	}
+
+	// A token minted for another Access application must be rejected
+	claims.Audience = jwt.Audience{"another-application"}
+	_, err = verifier.Verify(context.Background(),
+		signTestJWT(t, key, "cf-key", claims, map[string]interface{}{"email": "maker@example.com"}))
+	if err == nil {
+		t.Error("Expected token for another audience to be rejected")
+	}
+}
@@ -19,6 +19,11 @@ func NewLogger(cfg *config.Config) *Logger {
 
 // Debug logs a debug message only when in development environment
 func (l *Logger) Debug(format string, v ...interface{}) {
+	// A zero-value service (as used in tests) has no logger configured
+	if l == nil || l.cfg == nil {
+		return
+	}
+
 	if l.cfg.IsDevelopment() {
 		log.Printf("[DEBUG] "+format, v...)
 	}
@@ -34,6 +39,11 @@ func (l *Logger) Info(format string, v ...interface{}) {
 	log.Printf("[INFO] "+format, v...)
 }
 
+// Audit logs a security-relevant event regardless of environment
+func (l *Logger) Audit(format string, v ...interface{}) {
+	log.Printf("[AUDIT] "+format, v...)
+}
+
 // Fatal logs a fatal error message and exits regardless of environment
 func (l *Logger) Fatal(format string, v ...interface{}) {
 	log.Fatalf("[FATAL] "+format, v...)
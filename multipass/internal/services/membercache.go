@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"multipass/internal/models"
+	"sync"
+	"time"
+)
+
+// ErrLookupTimeout is returned when Authentik did not answer in time and nothing recent enough was cached
+var ErrLookupTimeout = errors.New("member lookup timed out")
+
+// MemberFetcher looks a member up in Authentik
+type MemberFetcher func() (*models.UserProfile, *models.MembershipInfo, error)
+
+// MemberLookup is a member as Authentik last described them
+// The profile and membership are shared between callers and must not be modified
+type MemberLookup struct {
+	User       *models.UserProfile
+	Membership *models.MembershipInfo
+	FetchedAt  time.Time
+}
+
+// memberEntry is a cached lookup and the fetch in progress for it, if any
+type memberEntry struct {
+	lookup *MemberLookup
+	err    error         // Error of the last fetch
+	done   chan struct{} // Closed when the running fetch finishes; nil when none is running
+}
+
+// MemberCache keeps member lookups so access decisions stay fast when Authentik is slow
+// Lookups younger than fresh are used as they are. Lookups up to maxStale old are used too,
+// while a fetch in the background refreshes them. Anything older waits up to timeout for Authentik.
+type MemberCache struct {
+	mu       sync.Mutex
+	entries  map[string]*memberEntry
+	fresh    time.Duration
+	maxStale time.Duration
+	timeout  time.Duration
+	now      func() time.Time
+}
+
+// NewMemberCache creates an empty cache with the given staleness bounds
+func NewMemberCache(fresh, maxStale, timeout time.Duration) *MemberCache {
+	return &MemberCache{
+		entries:  make(map[string]*memberEntry),
+		fresh:    fresh,
+		maxStale: maxStale,
+		timeout:  timeout,
+		now:      time.Now,
+	}
+}
+
+// Get returns the member cached under key, calling fetch when the cached lookup is missing or too old
+// Concurrent callers for the same key share one fetch. A fetch that times out keeps running and fills the cache for the next call.
+func (mc *MemberCache) Get(key string, fetch MemberFetcher) (*MemberLookup, error) {
+	mc.mu.Lock()
+	entry, ok := mc.entries[key]
+	if !ok {
+		entry = &memberEntry{}
+		mc.entries[key] = entry
+	}
+
+	// Serve recent enough lookups right away
+	if entry.lookup != nil {
+		age := mc.now().Sub(entry.lookup.FetchedAt)
+		if age <= mc.maxStale {
+			if age > mc.fresh && entry.done == nil {
+				mc.startFetchLocked(entry, fetch)
+			}
+			lookup := entry.lookup
+			mc.mu.Unlock()
+			return lookup, nil
+		}
+	}
+
+	// Otherwise wait for Authentik, but not for long
+	if entry.done == nil {
+		mc.startFetchLocked(entry, fetch)
+	}
+	done := entry.done
+	mc.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-time.After(mc.timeout):
+		return nil, ErrLookupTimeout
+	}
+
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if entry.err != nil {
+		return nil, entry.err
+	}
+	return entry.lookup, nil
+}
+
+// startFetchLocked runs fetch in the background and stores its result in entry; callers hold mu
+// A failed fetch keeps the previous lookup, which stops being served once it is older than maxStale
+func (mc *MemberCache) startFetchLocked(entry *memberEntry, fetch MemberFetcher) {
+	done := make(chan struct{})
+	entry.done = done
+	mc.pruneLocked()
+
+	go func() {
+		user, membership, err := fetch()
+
+		mc.mu.Lock()
+		defer mc.mu.Unlock()
+		entry.err = err
+		if err == nil {
+			entry.lookup = &MemberLookup{User: user, Membership: membership, FetchedAt: mc.now()}
+		}
+		entry.done = nil
+		close(done)
+	}()
+}
+
+// pruneLocked drops entries that can no longer be served and have no fetch running; callers hold mu
+func (mc *MemberCache) pruneLocked() {
+	now := mc.now()
+	for key, entry := range mc.entries {
+		if entry.done == nil && (entry.lookup == nil || now.Sub(entry.lookup.FetchedAt) > mc.maxStale) {
+			delete(mc.entries, key)
+		}
+	}
+}
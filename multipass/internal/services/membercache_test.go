@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"multipass/internal/models"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMemberCache_Staleness(t *testing.T) {
+	cache := NewMemberCache(time.Minute, 15*time.Minute, 50*time.Millisecond)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	var calls atomic.Int32
+	fetched := make(chan struct{}, 10)
+	fetch := func() (*models.UserProfile, *models.MembershipInfo, error) {
+		calls.Add(1)
+		defer func() { fetched <- struct{}{} }()
+		return testMember, &models.MembershipInfo{Status: models.StatusActive}, nil
+	}
+
+	// The first lookup waits for Authentik
+	lookup, err := cache.Get("member", fetch)
+	<-fetched
+	if err != nil || lookup.User != testMember || !lookup.FetchedAt.Equal(now) {
+		t.Fatalf("Expected a fetched lookup, got %+v, %v", lookup, err)
+	}
+
+	// Test cases
+	tests := []struct {
+		name          string
+		age           time.Duration
+		expectedCalls int32
+		expectedErr   error
+	}{
+		{"Fresh lookup is used as is", 30 * time.Second, 1, nil},
+		{"Stale lookup is used and refreshed", 10 * time.Minute, 2, nil},
+	}
+
+	// Run tests
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			now = lookup.FetchedAt.Add(tc.age)
+			if _, err := cache.Get("member", fetch); !errors.Is(err, tc.expectedErr) {
+				t.Fatalf("Expected error %v, got %v", tc.expectedErr, err)
+			}
+			if tc.expectedCalls > calls.Load() {
+				<-fetched
+			}
+			if calls.Load() != tc.expectedCalls {
+				t.Errorf("Expected %d fetches, got %d", tc.expectedCalls, calls.Load())
+			}
+		})
+	}
+}
+
+func TestMemberCache_SlowAuthentik(t *testing.T) {
+	cache := NewMemberCache(time.Minute, 15*time.Minute, 20*time.Millisecond)
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	cache.now = func() time.Time { return now }
+
+	release := make(chan struct{})
+	fetched := make(chan struct{})
+	slow := func() (*models.UserProfile, *models.MembershipInfo, error) {
+		<-release
+		defer close(fetched)
+		return testMember, &models.MembershipInfo{Status: models.StatusActive}, nil
+	}
+
+	// Nothing cached and Authentik hangs: the caller gives up
+	if _, err := cache.Get("member", slow); !errors.Is(err, ErrLookupTimeout) {
+		t.Fatalf("Expected ErrLookupTimeout, got %v", err)
+	}
+
+	// The fetch still fills the cache once Authentik answers
+	close(release)
+	<-fetched
+	failing := func() (*models.UserProfile, *models.MembershipInfo, error) {
+		return nil, nil, errors.New("authentik is down")
+	}
+	if lookup, err := cache.Get("member", failing); err != nil || lookup.User != testMember {
+		t.Fatalf("Expected the late lookup to be cached, got %+v, %v", lookup, err)
+	}
+
+	// Past the staleness bound the cached lookup is no longer trusted
+	now = now.Add(16 * time.Minute)
+	if _, err := cache.Get("member", failing); err == nil {
+		t.Error("Expected an error for a lookup older than the staleness bound")
+	}
+}
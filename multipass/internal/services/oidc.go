@@ -0,0 +1,148 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"multipass/internal/config"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDCClient implements the authorization-code + PKCE flow against the OIDC provider
+type OIDCClient struct {
+	provider    *oidc.Provider
+	verifier    *oidc.IDTokenVerifier
+	oauth2      oauth2.Config
+	groupsClaim string
+	endSession  string
+	logger      *Logger
+}
+
+// OIDCIdentity holds the identity claims taken from a verified ID token
+type OIDCIdentity struct {
+	Subject  string
+	Email    string
+	Name     string
+	Username string
+	Groups   []string
+}
+
+// NewOIDCClient discovers the provider configuration and creates an OIDC client
+func NewOIDCClient(ctx context.Context, cfg *config.Config) (*OIDCClient, error) {
+	logger := NewLogger(cfg)
+
+	// Discover endpoints and signing keys from the issuer
+	provider, err := oidc.NewProvider(ctx, cfg.OIDCIssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover OIDC provider: %w", err)
+	}
+
+	// Read the optional end_session_endpoint for logout
+	var extra struct {
+		EndSessionEndpoint string `json:"end_session_endpoint"`
+	}
+	if err := provider.Claims(&extra); err != nil {
+		logger.Debug("Failed to read provider metadata: %v", err)
+	}
+
+	logger.Debug("OIDC provider discovered: %s", cfg.OIDCIssuerURL)
+
+	return &OIDCClient{
+		provider: provider,
+		verifier: provider.Verifier(&oidc.Config{ClientID: cfg.OIDCClientID}),
+		oauth2: oauth2.Config{
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Endpoint:     provider.Endpoint(),
+			Scopes:       cfg.OIDCScopes,
+		},
+		groupsClaim: cfg.OIDCGroupsClaim,
+		endSession:  extra.EndSessionEndpoint,
+		logger:      logger,
+	}, nil
+}
+
+// AuthCodeURL returns the provider URL that starts the login, bound to the state, nonce and PKCE verifier
+func (oc *OIDCClient) AuthCodeURL(state, nonce, verifier string) string {
+	return oc.oauth2.AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+}
+
+// EndSessionURL returns the provider logout URL, or an empty string if it has none
+func (oc *OIDCClient) EndSessionURL() string {
+	return oc.endSession
+}
+
+// Exchange trades the authorization code for tokens and returns the verified identity
+func (oc *OIDCClient) Exchange(ctx context.Context, code, verifier, nonce string) (*OIDCIdentity, error) {
+	// Redeem the code together with the PKCE verifier
+	token, err := oc.oauth2.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token response did not include an id_token")
+	}
+
+	// Verify signature, issuer, audience and expiry
+	idToken, err := oc.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify id_token: %w", err)
+	}
+
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	// Decode the standard claims plus the configured groups claim
+	var claims map[string]interface{}
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to parse id_token claims: %w", err)
+	}
+
+	identity := &OIDCIdentity{
+		Subject:  idToken.Subject,
+		Email:    stringClaim(claims, "email"),
+		Name:     stringClaim(claims, "name"),
+		Username: stringClaim(claims, "preferred_username"),
+		Groups:   stringListClaim(claims, oc.groupsClaim),
+	}
+
+	if identity.Email == "" {
+		return nil, errors.New("id_token did not include an email claim")
+	}
+
+	oc.logger.Debug("OIDC login for %s with groups %v", identity.Email, identity.Groups)
+
+	return identity, nil
+}
+
+// stringClaim returns a string claim or an empty string
+func stringClaim(claims map[string]interface{}, name string) string {
+	if value, ok := claims[name].(string); ok {
+		return value
+	}
+	return ""
+}
+
+// stringListClaim returns a claim that may be a list of strings or a single string
+func stringListClaim(claims map[string]interface{}, name string) []string {
+	switch value := claims[name].(type) {
+	case []interface{}:
+		list := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	case string:
+		return []string{value}
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,221 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"multipass/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// mockOIDCProvider is a minimal local OIDC provider for exercising the login flow
+type mockOIDCProvider struct {
+	server    *httptest.Server
+	key       *rsa.PrivateKey
+	clientID  string
+	challenge string
+	nonce     string
+	claims    map[string]interface{}
+}
+
+// newMockOIDCProvider starts a provider serving discovery, JWKS and token endpoints
+func newMockOIDCProvider(t *testing.T) *mockOIDCProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Failed to generate signing key: %v", err)
+	}
+
+	mp := &mockOIDCProvider{key: key, clientID: "multipass"}
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                mp.server.URL,
+			"authorization_endpoint":                mp.server.URL + "/authorize",
+			"token_endpoint":                        mp.server.URL + "/token",
+			"jwks_uri":                              mp.server.URL + "/jwks",
+			"end_session_endpoint":                  mp.server.URL + "/end-session",
+			"id_token_signing_alg_values_supported": []string{"RS256"},
+		})
+	})
+
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{
+			{Key: &mp.key.PublicKey, KeyID: "test-key", Algorithm: "RS256", Use: "sig"},
+		}})
+	})
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		// Enforce PKCE the way a real provider would
+		sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+		if base64.RawURLEncoding.EncodeToString(sum[:]) != mp.challenge {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access-token",
+			"token_type":   "Bearer",
+			"expires_in":   300,
+			"id_token":     mp.signIDToken(t),
+		})
+	})
+
+	mp.server = httptest.NewServer(mux)
+	t.Cleanup(mp.server.Close)
+	return mp
+}
+
+// signIDToken signs an ID token carrying the provider's configured claims
+func (mp *mockOIDCProvider) signIDToken(t *testing.T) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: mp.key},
+		(&jose.SignerOptions{}).WithHeader("kid", "test-key"))
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	now := time.Now()
+	registered := jwt.Claims{
+		Issuer:   mp.server.URL,
+		Subject:  "42",
+		Audience: jwt.Audience{mp.clientID},
+		IssuedAt: jwt.NewNumericDate(now),
+		Expiry:   jwt.NewNumericDate(now.Add(5 * time.Minute)),
+	}
+
+	token, err := jwt.Signed(signer).Claims(registered).Claims(map[string]interface{}{"nonce": mp.nonce}).Claims(mp.claims).Serialize()
+	if err != nil {
+		t.Fatalf("Failed to sign id_token: %v", err)
+	}
+	return token
+}
+
+// startLogin creates a client against the mock provider and records the PKCE challenge and nonce
+func startLogin(t *testing.T, mp *mockOIDCProvider, verifier, nonce string) *OIDCClient {
+	t.Helper()
+
+	client, err := NewOIDCClient(context.Background(), &config.Config{
+		OIDCIssuerURL:   mp.server.URL,
+		OIDCClientID:    mp.clientID,
+		OIDCRedirectURL: "http://localhost:3000/callback",
+		OIDCScopes:      []string{"openid", "profile", "email"},
+		OIDCGroupsClaim: "groups",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create OIDC client: %v", err)
+	}
+
+	authURL, err := url.Parse(client.AuthCodeURL("state", nonce, verifier))
+	if err != nil {
+		t.Fatalf("Failed to parse auth URL: %v", err)
+	}
+	if authURL.Query().Get("code_challenge_method") != "S256" {
+		t.Fatalf("Expected S256 PKCE challenge, got %q", authURL.Query().Get("code_challenge_method"))
+	}
+
+	mp.challenge = authURL.Query().Get("code_challenge")
+	mp.nonce = authURL.Query().Get("nonce")
+	return client
+}
+
+func TestOIDCClient_Exchange(t *testing.T) {
+	mp := newMockOIDCProvider(t)
+	mp.claims = map[string]interface{}{
+		"email":              "maker@example.com",
+		"name":               "Maker Person",
+		"preferred_username": "maker",
+		"groups":             []string{"Members", "staff"},
+	}
+
+	client := startLogin(t, mp, "verifier-0123456789-0123456789-0123456789", "nonce-1")
+
+	identity, err := client.Exchange(context.Background(), "code", "verifier-0123456789-0123456789-0123456789", "nonce-1")
+	if err != nil {
+		t.Fatalf("Failed to exchange code: %v", err)
+	}
+
+	if identity.Email != "maker@example.com" || identity.Name != "Maker Person" || identity.Username != "maker" {
+		t.Errorf("Unexpected identity: %+v", identity)
+	}
+	if identity.Subject != "42" {
+		t.Errorf("Expected subject 42, got %s", identity.Subject)
+	}
+	if len(identity.Groups) != 2 || identity.Groups[0] != "Members" || identity.Groups[1] != "staff" {
+		t.Errorf("Unexpected groups: %v", identity.Groups)
+	}
+	if client.EndSessionURL() != mp.server.URL+"/end-session" {
+		t.Errorf("Unexpected end session URL: %s", client.EndSessionURL())
+	}
+}
+
+func TestOIDCClient_ExchangeWithWrongVerifier(t *testing.T) {
+	mp := newMockOIDCProvider(t)
+	mp.claims = map[string]interface{}{"email": "maker@example.com"}
+
+	client := startLogin(t, mp, "verifier-0123456789-0123456789-0123456789", "nonce-1")
+
+	_, err := client.Exchange(context.Background(), "code", "some-other-verifier-0123456789-0123456789", "nonce-1")
+	if err == nil {
+		t.Error("Expected exchange to fail with a wrong PKCE verifier")
+	}
+}
+
+func TestOIDCClient_ExchangeWithWrongNonce(t *testing.T) {
+	mp := newMockOIDCProvider(t)
+	mp.claims = map[string]interface{}{"email": "maker@example.com"}
+
+	client := startLogin(t, mp, "verifier-0123456789-0123456789-0123456789", "nonce-1")
+
+	_, err := client.Exchange(context.Background(), "code", "verifier-0123456789-0123456789-0123456789", "nonce-2")
+	if err == nil {
+		t.Error("Expected exchange to fail with a mismatched nonce")
+	}
+}
+
+func TestSessionManager_RoundTrip(t *testing.T) {
+	sessions, err := NewSessionManager(&config.Config{SessionSecret: "session-secret", SessionMaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create session manager: %v", err)
+	}
+
+	// Save a session and replay its cookie on a new request
+	recorder := httptest.NewRecorder()
+	if err := sessions.Save(recorder, &Session{Email: "maker@example.com", Groups: []string{"Members"}}); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/card", nil)
+	for _, cookie := range recorder.Result().Cookies() {
+		req.AddCookie(cookie)
+	}
+
+	session, err := sessions.Load(req)
+	if err != nil {
+		t.Fatalf("Failed to load session: %v", err)
+	}
+	if session.Email != "maker@example.com" || len(session.Groups) != 1 {
+		t.Errorf("Unexpected session: %+v", session)
+	}
+
+	// A cookie sealed with another secret must be rejected
+	other, _ := NewSessionManager(&config.Config{SessionSecret: "other-secret", SessionMaxAge: time.Hour})
+	if _, err := other.Load(req); err == nil {
+		t.Error("Expected session sealed with another secret to be rejected")
+	}
+}
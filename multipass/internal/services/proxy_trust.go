@@ -0,0 +1,143 @@
+package services
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"multipass/internal/config"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const (
+	// identityHeaderPrefix is the prefix shared by all identity headers set by the Authentik outpost
+	identityHeaderPrefix = "X-Authentik-"
+
+	// signedIdentityHeader carries the outpost JWT, which is verified on its own
+	signedIdentityHeader = "X-Authentik-Jwt"
+)
+
+// ProxyTrust decides whether a request arrived through a trusted reverse proxy
+type ProxyTrust struct {
+	enabled      bool
+	networks     []*net.IPNet
+	secret       string
+	secretHeader string
+	keepHeaders  map[string]bool // Headers that are safe from any peer
+	identity     map[string]bool // Identity headers of the configured header profile
+}
+
+// NewProxyTrust creates a ProxyTrust from the trusted proxy settings in the config
+func NewProxyTrust(cfg *config.Config) (*ProxyTrust, error) {
+	pt := &ProxyTrust{
+		enabled:      cfg.TrustedProxyHeaders,
+		secret:       cfg.TrustedProxySecret,
+		secretHeader: http.CanonicalHeaderKey(cfg.TrustedProxySecretHeader),
+		keepHeaders:  map[string]bool{},
+		identity:     map[string]bool{},
+	}
+
+	// Strip the headers of the configured profile as well as any X-Authentik-* header
+	for _, header := range cfg.HeaderProfile.Headers() {
+		pt.identity[http.CanonicalHeaderKey(header)] = true
+	}
+
+	// A signed JWT cannot be forged, so it may pass through when it is what we verify
+	if cfg.AuthMode == config.AuthModeJWT {
+		pt.keepHeaders[signedIdentityHeader] = true
+	}
+
+	// Parse the trusted networks, accepting bare IPs as single-host networks
+	for _, cidr := range cfg.TrustedProxyCIDRs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid trusted proxy address: %s", cidr)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				bits = 8 * net.IPv4len
+			}
+			cidr = fmt.Sprintf("%s/%d", cidr, bits)
+		}
+
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy network %s: %w", cidr, err)
+		}
+		pt.networks = append(pt.networks, network)
+	}
+
+	// A secret without a header to carry it can never match
+	if pt.secret != "" && pt.secretHeader == "" {
+		return nil, fmt.Errorf("TRUSTED_PROXY_SECRET requires TRUSTED_PROXY_SECRET_HEADER")
+	}
+
+	return pt, nil
+}
+
+// IsTrusted returns true if the request came from a trusted proxy network
+// and, when a shared secret is configured, carries the matching secret header
+func (pt *ProxyTrust) IsTrusted(r *http.Request) bool {
+	// Header-based authentication can be switched off entirely
+	if !pt.enabled {
+		return false
+	}
+
+	// Check the directly connected peer, never X-Forwarded-For
+	if len(pt.networks) > 0 {
+		ip := remoteIP(r.RemoteAddr)
+		if ip == nil || !pt.containsIP(ip) {
+			return false
+		}
+	}
+
+	// Check the shared secret if one is configured
+	if pt.secret != "" {
+		provided := r.Header.Get(pt.secretHeader)
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(pt.secret)) != 1 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// StripIdentityHeaders removes all identity headers (and the proxy secret) from the header set
+// and returns the names of the identity headers that were present
+func (pt *ProxyTrust) StripIdentityHeaders(header http.Header) []string {
+	var stripped []string
+	for name := range header {
+		canonical := http.CanonicalHeaderKey(name)
+		isIdentity := pt.identity[canonical] || strings.HasPrefix(canonical, identityHeaderPrefix)
+		if isIdentity && !pt.keepHeaders[canonical] {
+			stripped = append(stripped, name)
+			header.Del(name)
+		}
+	}
+
+	if pt.secretHeader != "" {
+		header.Del(pt.secretHeader)
+	}
+
+	return stripped
+}
+
+// containsIP checks if the IP is inside any trusted network
+func (pt *ProxyTrust) containsIP(ip net.IP) bool {
+	for _, network := range pt.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP extracts the IP address from a host:port remote address
+func remoteIP(remoteAddr string) net.IP {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		host = remoteAddr
+	}
+	return net.ParseIP(host)
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"multipass/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProxyTrust_IsTrusted(t *testing.T) {
+	// Test cases
+	testCases := []struct {
+		name       string
+		enabled    bool
+		cidrs      []string
+		secret     string
+		remoteAddr string
+		sentSecret string
+		expected   bool
+	}{
+		{
+			name:       "Peer inside trusted network",
+			enabled:    true,
+			cidrs:      []string{"10.0.0.0/8"},
+			remoteAddr: "10.1.2.3:54321",
+			expected:   true,
+		},
+		{
+			name:       "Peer outside trusted network",
+			enabled:    true,
+			cidrs:      []string{"10.0.0.0/8"},
+			remoteAddr: "192.168.1.5:54321",
+			expected:   false,
+		},
+		{
+			name:       "Bare IP entry",
+			enabled:    true,
+			cidrs:      []string{"::1"},
+			remoteAddr: "[::1]:8080",
+			expected:   true,
+		},
+		{
+			name:       "Secret matches",
+			enabled:    true,
+			cidrs:      []string{"10.0.0.0/8"},
+			secret:     "s3cret",
+			remoteAddr: "10.1.2.3:54321",
+			sentSecret: "s3cret",
+			expected:   true,
+		},
+		{
+			name:       "Secret missing",
+			enabled:    true,
+			cidrs:      []string{"10.0.0.0/8"},
+			secret:     "s3cret",
+			remoteAddr: "10.1.2.3:54321",
+			expected:   false,
+		},
+		{
+			name:       "Secret only, any network",
+			enabled:    true,
+			secret:     "s3cret",
+			remoteAddr: "203.0.113.9:443",
+			sentSecret: "s3cret",
+			expected:   true,
+		},
+		{
+			name:       "Header authentication disabled",
+			enabled:    false,
+			cidrs:      []string{"10.0.0.0/8"},
+			remoteAddr: "10.1.2.3:54321",
+			expected:   false,
+		},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			trust, err := NewProxyTrust(&config.Config{
+				TrustedProxyHeaders:      tc.enabled,
+				TrustedProxyCIDRs:        tc.cidrs,
+				TrustedProxySecret:       tc.secret,
+				TrustedProxySecretHeader: "X-Multipass-Proxy-Secret",
+			})
+			if err != nil {
+				t.Fatalf("Failed to create proxy trust: %v", err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/card", nil)
+			req.RemoteAddr = tc.remoteAddr
+			if tc.sentSecret != "" {
+				req.Header.Set("X-Multipass-Proxy-Secret", tc.sentSecret)
+			}
+
+			if trusted := trust.IsTrusted(req); trusted != tc.expected {
+				t.Errorf("Expected trusted=%v, got %v", tc.expected, trusted)
+			}
+		})
+	}
+}
+
+func TestProxyTrust_InvalidNetwork(t *testing.T) {
+	_, err := NewProxyTrust(&config.Config{
+		TrustedProxyHeaders: true,
+		TrustedProxyCIDRs:   []string{"not-a-network"},
+	})
+	if err == nil {
+		t.Error("Expected an error for an invalid trusted proxy network")
+	}
+}
+
+func TestProxyTrust_StripIdentityHeaders(t *testing.T) {
+	trust, err := NewProxyTrust(&config.Config{
+		TrustedProxyHeaders:      true,
+		TrustedProxySecretHeader: "X-Multipass-Proxy-Secret",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy trust: %v", err)
+	}
+
+	header := http.Header{}
+	header.Set("X-Authentik-Email", "mallory@example.com")
+	header.Set("X-Authentik-Groups", "admin")
+	header.Set("X-Multipass-Proxy-Secret", "guess")
+	header.Set("Accept", "text/html")
+
+	stripped := trust.StripIdentityHeaders(header)
+	if len(stripped) != 2 {
+		t.Errorf("Expected 2 stripped headers, got %v", stripped)
+	}
+	if header.Get("X-Authentik-Email") != "" || header.Get("X-Authentik-Groups") != "" {
+		t.Error("Expected identity headers to be removed")
+	}
+	if header.Get("X-Multipass-Proxy-Secret") != "" {
+		t.Error("Expected proxy secret header to be removed")
+	}
+	if header.Get("Accept") != "text/html" {
+		t.Error("Expected unrelated headers to be kept")
+	}
+}
+
+func TestProxyTrust_KeepsSignedHeaderInJWTMode(t *testing.T) {
+	trust, err := NewProxyTrust(&config.Config{
+		AuthMode:            config.AuthModeJWT,
+		TrustedProxyHeaders: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy trust: %v", err)
+	}
+
+	header := http.Header{}
+	header.Set("X-Authentik-Email", "mallory@example.com")
+	header.Set("X-Authentik-Jwt", "eyJ...")
+
+	trust.StripIdentityHeaders(header)
+	if header.Get("X-Authentik-Email") != "" {
+		t.Error("Expected plain identity header to be removed")
+	}
+	if header.Get("X-Authentik-Jwt") == "" {
+		t.Error("Expected signed JWT header to be kept")
+	}
+}
+
+func TestProxyTrust_StripsProfileHeaders(t *testing.T) {
+	trust, err := NewProxyTrust(&config.Config{
+		TrustedProxyHeaders: true,
+		HeaderProfile:       config.HeaderProfiles["authelia"],
+	})
+	if err != nil {
+		t.Fatalf("Failed to create proxy trust: %v", err)
+	}
+
+	header := http.Header{}
+	header.Set("Remote-User", "mallory")
+	header.Set("Remote-Groups", "admins")
+
+	if stripped := trust.StripIdentityHeaders(header); len(stripped) != 2 {
+		t.Errorf("Expected 2 stripped headers, got %v", stripped)
+	}
+	if header.Get("Remote-User") != "" || header.Get("Remote-Groups") != "" {
+		t.Error("Expected profile identity headers to be removed")
+	}
+}
@@ -0,0 +1,144 @@
+package services
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a token-bucket limiter keyed by client IP, user or token
+// Each key may burst up to the per-minute budget and refills continuously
+type RateLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	rate      float64 // Tokens added per second
+	burst     float64 // Bucket capacity
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// tokenBucket tracks the remaining tokens of one key
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a limiter allowing perMinute requests per key
+// Returns nil, meaning no limiting, if perMinute is not positive
+func NewRateLimiter(perMinute int) *RateLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+
+	return &RateLimiter{
+		buckets: make(map[string]*tokenBucket),
+		rate:    float64(perMinute) / 60,
+		burst:   float64(perMinute),
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token for the key; when none is left it returns how long until one is
+func (rl *RateLimiter) Allow(key string) (bool, time.Duration) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := rl.now()
+	rl.sweep(now)
+
+	// New keys start with a full bucket
+	bucket, ok := rl.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: rl.burst, last: now}
+		rl.buckets[key] = bucket
+	}
+
+	// Refill for the time elapsed since the last request
+	bucket.tokens = math.Min(rl.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*rl.rate)
+	bucket.last = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+
+	wait := time.Duration((1 - bucket.tokens) / rl.rate * float64(time.Second))
+	return false, wait
+}
+
+// sweep drops buckets that have refilled completely, since they behave like new ones
+func (rl *RateLimiter) sweep(now time.Time) {
+	fillTime := time.Duration(rl.burst / rl.rate * float64(time.Second))
+	if now.Sub(rl.lastSweep) < fillTime {
+		return
+	}
+	rl.lastSweep = now
+
+	for key, bucket := range rl.buckets {
+		if now.Sub(bucket.last) >= fillTime {
+			delete(rl.buckets, key)
+		}
+	}
+}
+
+// RateLimitStats counts throttled requests for monitoring
+type RateLimitStats struct {
+	mu        sync.Mutex
+	throttled map[rateLimitSeries]uint64
+}
+
+// rateLimitSeries identifies a counter by route group and limiting dimension
+type rateLimitSeries struct {
+	group     string
+	dimension string
+}
+
+// NewRateLimitStats creates an empty set of counters
+func NewRateLimitStats() *RateLimitStats {
+	return &RateLimitStats{throttled: make(map[rateLimitSeries]uint64)}
+}
+
+// Throttled records a limited request
+func (s *RateLimitStats) Throttled(group, dimension string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.throttled[rateLimitSeries{group, dimension}]++
+}
+
+// Count returns the number of limited requests for a group and dimension
+func (s *RateLimitStats) Count(group, dimension string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.throttled[rateLimitSeries{group, dimension}]
+}
+
+// WritePrometheus writes the counters in the Prometheus text exposition format
+func (s *RateLimitStats) WritePrometheus(w io.Writer) {
+	s.mu.Lock()
+	series := make([]rateLimitSeries, 0, len(s.throttled))
+	for key := range s.throttled {
+		series = append(series, key)
+	}
+	counts := make(map[rateLimitSeries]uint64, len(s.throttled))
+	for key, count := range s.throttled {
+		counts[key] = count
+	}
+	s.mu.Unlock()
+
+	// Stable output keeps scrapes diffable
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].group != series[j].group {
+			return series[i].group < series[j].group
+		}
+		return series[i].dimension < series[j].dimension
+	})
+
+	fmt.Fprintln(w, "# HELP multipass_rate_limited_total Requests rejected by the rate limiter.")
+	fmt.Fprintln(w, "# TYPE multipass_rate_limited_total counter")
+	for _, key := range series {
+		fmt.Fprintf(w, "multipass_rate_limited_total{group=%q,dimension=%q} %d\n", key.group, key.dimension, counts[key])
+	}
+}
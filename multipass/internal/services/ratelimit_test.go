@@ -0,0 +1,82 @@
+package services
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter_Allow(t *testing.T) {
+	limiter := NewRateLimiter(60) // One token per second, bursts of 60
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+
+	// The full burst is available to a new key
+	for i := 0; i < 60; i++ {
+		if allowed, _ := limiter.Allow("ip:192.0.2.1"); !allowed {
+			t.Fatalf("Request %d should have been allowed", i+1)
+		}
+	}
+
+	// The next request must wait for a token to refill
+	allowed, retryAfter := limiter.Allow("ip:192.0.2.1")
+	if allowed {
+		t.Fatal("Expected request beyond the burst to be limited")
+	}
+	if retryAfter <= 0 || retryAfter > time.Second {
+		t.Errorf("Expected retry-after within one second, got %v", retryAfter)
+	}
+
+	// Other keys have their own budget
+	if allowed, _ := limiter.Allow("ip:192.0.2.2"); !allowed {
+		t.Error("Expected a different key to be allowed")
+	}
+
+	// Tokens refill over time
+	now = now.Add(time.Second)
+	if allowed, _ := limiter.Allow("ip:192.0.2.1"); !allowed {
+		t.Error("Expected a token to be available after one second")
+	}
+}
+
+func TestRateLimiter_SweepsIdleBuckets(t *testing.T) {
+	limiter := NewRateLimiter(60)
+	now := time.Unix(1700000000, 0)
+	limiter.now = func() time.Time { return now }
+
+	limiter.Allow("ip:192.0.2.1")
+	now = now.Add(2 * time.Minute)
+	limiter.Allow("ip:192.0.2.2")
+
+	if _, ok := limiter.buckets["ip:192.0.2.1"]; ok {
+		t.Error("Expected idle bucket to be swept")
+	}
+}
+
+func TestNewRateLimiter_Disabled(t *testing.T) {
+	if NewRateLimiter(0) != nil {
+		t.Error("Expected a zero budget to disable limiting")
+	}
+}
+
+func TestRateLimitStats_WritePrometheus(t *testing.T) {
+	stats := NewRateLimitStats()
+	stats.Throttled("public", "token")
+	stats.Throttled("public", "token")
+	stats.Throttled("api", "ip")
+
+	var buf bytes.Buffer
+	stats.WritePrometheus(&buf)
+	output := buf.String()
+
+	for _, expected := range []string{
+		"# TYPE multipass_rate_limited_total counter",
+		`multipass_rate_limited_total{group="api",dimension="ip"} 1`,
+		`multipass_rate_limited_total{group="public",dimension="token"} 2`,
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+}
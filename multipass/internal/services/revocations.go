@@ -0,0 +1,158 @@
+package services
+
+import (
+	"errors"
+	"multipass/internal/models"
+	"multipass/internal/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrTokenRevoked is returned for tokens that were revoked individually or by their owner
+var ErrTokenRevoked = errors.New("token revoked")
+
+// revocationState is the on-disk form of the revocation store
+type revocationState struct {
+	UserEpochs map[string]time.Time   `json:"user_epochs"` // Tokens issued before this time are invalid, keyed by email
+	Tokens     []*models.RevokedToken `json:"tokens"`
+}
+
+// RevocationStore keeps per-user token epochs and individually revoked token IDs
+// Lookups are served from memory, so a revocation applies to the next request
+type RevocationStore struct {
+	mu     sync.RWMutex
+	file   jsonFile
+	epochs map[string]time.Time
+	tokens map[string]*models.RevokedToken
+	now    func() time.Time
+}
+
+// NewRevocationStore loads the revocations stored at path
+func NewRevocationStore(path string) (*RevocationStore, error) {
+	store := &RevocationStore{
+		file:   jsonFile{path: path},
+		epochs: make(map[string]time.Time),
+		tokens: make(map[string]*models.RevokedToken),
+		now:    time.Now,
+	}
+
+	var state revocationState
+	if err := store.file.load(&state); err != nil {
+		return nil, err
+	}
+	for email, epoch := range state.UserEpochs {
+		store.epochs[email] = epoch
+	}
+	for _, token := range state.Tokens {
+		store.tokens[token.ID] = token
+	}
+
+	return store, nil
+}
+
+// InvalidateUser invalidates every token issued to the user up to now and returns the new epoch
+// Token timestamps have second precision, so the epoch is truncated to the second as well
+func (s *RevocationStore) InvalidateUser(email string) (time.Time, error) {
+	epoch := s.now().UTC().Truncate(time.Second)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.epochs[normalizeEmail(email)] = epoch
+	return epoch, s.saveLocked()
+}
+
+// UserEpoch returns the time before which the user's tokens are invalid, or the zero time
+func (s *RevocationStore) UserEpoch(email string) time.Time {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.epochs[normalizeEmail(email)]
+}
+
+// Revoke adds a token ID to the revocation list until the token would have expired
+func (s *RevocationStore) Revoke(id, email string, expiresAt time.Time, revokedBy, reason string) (*models.RevokedToken, error) {
+	if id == "" {
+		return nil, errors.New("token ID is required")
+	}
+
+	entry := &models.RevokedToken{
+		ID:        id,
+		Email:     email,
+		Reason:    reason,
+		RevokedBy: revokedBy,
+		RevokedAt: s.now().UTC(),
+		ExpiresAt: expiresAt,
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[id] = entry
+	return entry, s.saveLocked()
+}
+
+// IsRevoked returns true if the token ID is on the revocation list
+func (s *RevocationStore) IsRevoked(id string) bool {
+	if id == "" {
+		return false
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, revoked := s.tokens[id]
+	return revoked
+}
+
+// Check returns ErrTokenRevoked if the token was revoked by ID or issued before its owner's epoch
+func (s *RevocationStore) Check(data *utils.TokenData) error {
+	if s.IsRevoked(data.ID) {
+		return ErrTokenRevoked
+	}
+	if data.Timestamp.Before(s.UserEpoch(data.Email)) {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// List returns the revoked tokens, most recent first
+func (s *RevocationStore) List() []*models.RevokedToken {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]*models.RevokedToken, 0, len(s.tokens))
+	for _, token := range s.tokens {
+		entry := *token
+		tokens = append(tokens, &entry)
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].RevokedAt.After(tokens[j].RevokedAt)
+	})
+	return tokens
+}
+
+// saveLocked drops entries for tokens that have expired anyway and writes the store; callers hold mu
+func (s *RevocationStore) saveLocked() error {
+	now := s.now()
+	state := revocationState{
+		UserEpochs: s.epochs,
+		Tokens:     make([]*models.RevokedToken, 0, len(s.tokens)),
+	}
+
+	for id, token := range s.tokens {
+		if now.After(token.ExpiresAt) {
+			delete(s.tokens, id)
+			continue
+		}
+		state.Tokens = append(state.Tokens, token)
+	}
+
+	return s.file.save(state)
+}
+
+// normalizeEmail makes epoch lookups independent of the email's case
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"errors"
+	"multipass/internal/config"
+	"multipass/internal/utils"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRevocationStore_UserEpoch(t *testing.T) {
+	store, err := NewRevocationStore(filepath.Join(t.TempDir(), "token_revocations.json"))
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+	now := time.Date(2025, 6, 1, 12, 0, 0, 500, time.UTC)
+	store.now = func() time.Time { return now }
+
+	if _, err := store.InvalidateUser("Member@Example.com"); err != nil {
+		t.Fatalf("Failed to invalidate user: %v", err)
+	}
+
+	// Test cases
+	testCases := []struct {
+		name     string
+		issuedAt time.Time
+		email    string
+		revoked  bool
+	}{
+		{name: "Issued before the epoch", issuedAt: now.Add(-time.Hour), email: "member@example.com", revoked: true},
+		{name: "Issued in the same second", issuedAt: now.Truncate(time.Second), email: "member@example.com", revoked: false},
+		{name: "Issued after the epoch", issuedAt: now.Add(time.Minute), email: "member@example.com", revoked: false},
+		{name: "Other member", issuedAt: now.Add(-time.Hour), email: "other@example.com", revoked: false},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := store.Check(&utils.TokenData{Email: tc.email, Timestamp: tc.issuedAt})
+			if revoked := errors.Is(err, ErrTokenRevoked); revoked != tc.revoked {
+				t.Errorf("Expected revoked=%v, got error %v", tc.revoked, err)
+			}
+		})
+	}
+}
+
+func TestRevocationStore_RevokeAndReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "token_revocations.json")
+	store, _ := NewRevocationStore(path)
+
+	if _, err := store.Revoke("a1b2c3d4e5f60708", "member@example.com", time.Now().Add(time.Hour), "staff@example.com", "leaked"); err != nil {
+		t.Fatalf("Failed to revoke token: %v", err)
+	}
+	if _, err := store.Revoke("expired0expired0", "member@example.com", time.Now().Add(-time.Hour), "staff@example.com", ""); err != nil {
+		t.Fatalf("Failed to revoke token: %v", err)
+	}
+	if _, err := store.InvalidateUser("member@example.com"); err != nil {
+		t.Fatalf("Failed to invalidate user: %v", err)
+	}
+
+	// Revocations survive a restart; entries for expired tokens are dropped
+	reloaded, err := NewRevocationStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reload store: %v", err)
+	}
+	if !reloaded.IsRevoked("a1b2c3d4e5f60708") {
+		t.Error("Expected revoked token to survive a reload")
+	}
+	if reloaded.IsRevoked("expired0expired0") {
+		t.Error("Expected entry for an expired token to be pruned")
+	}
+	if reloaded.UserEpoch("member@example.com").IsZero() {
+		t.Error("Expected user epoch to survive a reload")
+	}
+	if len(reloaded.List()) != 1 {
+		t.Errorf("Expected 1 revoked token, got %d", len(reloaded.List()))
+	}
+}
+
+func TestTokenService_Revocation(t *testing.T) {
+	store, _ := NewRevocationStore(filepath.Join(t.TempDir(), "token_revocations.json"))
+	cfg := &config.Config{
+		TokenActiveKID: utils.DefaultKeyID,
+		TokenKeys:      map[string]string{utils.DefaultKeyID: "test-secret"},
+	}
+	tokens, err := NewTokenService(cfg, store)
+	if err != nil {
+		t.Fatalf("Failed to create token service: %v", err)
+	}
+
+	first, firstData, _ := tokens.Issue("42", "member@example.com", utils.PurposeShare, time.Hour)
+	second, _, _ := tokens.Issue("42", "member@example.com", utils.PurposeShare, time.Hour)
+
+	// Revoking one token leaves the others working
+	store.Revoke(firstData.ID, firstData.Email, firstData.ExpiresAt, "staff@example.com", "")
+	if _, err := tokens.Verify(first, utils.PurposeShare); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected revoked token to be rejected, got %v", err)
+	}
+	if _, err := tokens.Verify(second, utils.PurposeShare); err != nil {
+		t.Errorf("Expected other token to verify: %v", err)
+	}
+
+	// A share token is not accepted where only card tokens are
+	if _, err := tokens.Verify(second, utils.PurposeCard); !errors.Is(err, ErrTokenPurpose) {
+		t.Errorf("Expected purpose mismatch, got %v", err)
+	}
+
+	// Invalidating the member kills everything issued so far
+	store.now = func() time.Time { return time.Now().Add(time.Second) }
+	store.InvalidateUser("member@example.com")
+	if _, err := tokens.Verify(second, utils.PurposeShare); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected token issued before the epoch to be rejected, got %v", err)
+	}
+}
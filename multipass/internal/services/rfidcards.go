@@ -0,0 +1,188 @@
+package services
+
+import (
+	"errors"
+	"multipass/internal/models"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RFIDUIDsAttribute is the Authentik user attribute mirroring the UIDs bound to the member
+const RFIDUIDsAttribute = "multipass_rfid_uids"
+
+// Card binding errors
+var (
+	ErrCardUID         = errors.New("card UID must be 4, 7 or 10 bytes of hex, or a 10 digit number")
+	ErrCardTaken       = errors.New("card is already bound to another member")
+	ErrCardLimit       = errors.New("member has too many cards; unbind one first")
+	ErrCardNotFound    = errors.New("card not found")
+	ErrCardLabelLength = errors.New("card label is too long")
+)
+
+// Card binding limits
+const (
+	cardLimit    = 5   // Cards per member
+	cardLabelMax = 100 // Characters in a card label
+)
+
+// RFIDCardStore keeps the cards and fobs bound to members in a JSON file in the data directory
+// Each UID belongs to at most one member
+type RFIDCardStore struct {
+	mu    sync.Mutex
+	file  jsonFile
+	cards map[string]*models.RFIDCard // By normalized UID
+	now   func() time.Time
+}
+
+// NewRFIDCardStore loads the cards stored at path
+func NewRFIDCardStore(path string) (*RFIDCardStore, error) {
+	store := &RFIDCardStore{
+		file:  jsonFile{path: path},
+		cards: make(map[string]*models.RFIDCard),
+		now:   time.Now,
+	}
+
+	var cards []*models.RFIDCard
+	if err := store.file.load(&cards); err != nil {
+		return nil, err
+	}
+	for _, card := range cards {
+		store.cards[card.UID] = card
+	}
+
+	return store, nil
+}
+
+// Bind binds the card to the member
+// Binding a card the member already holds updates its label; a card of another member must be unbound first
+func (s *RFIDCardStore) Bind(rawUID string, user *models.UserProfile, label, boundBy string) (*models.RFIDCard, error) {
+	uid, ok := models.NormalizeCardUID(rawUID)
+	if !ok {
+		return nil, ErrCardUID
+	}
+	label = strings.TrimSpace(label)
+	if len(label) > cardLabelMax {
+		return nil, ErrCardLabelLength
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if existing, ok := s.cards[uid]; ok {
+		if !strings.EqualFold(existing.Email, user.Email) {
+			return nil, ErrCardTaken
+		}
+		previous := *existing
+		existing.Label = label
+		if err := s.saveLocked(); err != nil {
+			*existing = previous
+			return nil, err
+		}
+		entry := *existing
+		return &entry, nil
+	}
+
+	if len(s.listLocked(user.Email)) >= cardLimit {
+		return nil, ErrCardLimit
+	}
+
+	card := &models.RFIDCard{
+		UID:         uid,
+		Email:       user.Email,
+		MemberName:  user.GetFullName(),
+		AuthentikID: user.AuthentikID,
+		Label:       label,
+		BoundBy:     boundBy,
+		BoundAt:     s.now().UTC(),
+	}
+	s.cards[uid] = card
+	if err := s.saveLocked(); err != nil {
+		delete(s.cards, uid)
+		return nil, err
+	}
+
+	entry := *card
+	return &entry, nil
+}
+
+// Unbind removes a card from whoever it is bound to and returns the removed binding
+func (s *RFIDCardStore) Unbind(rawUID string) (*models.RFIDCard, error) {
+	uid, ok := models.NormalizeCardUID(rawUID)
+	if !ok {
+		return nil, ErrCardUID
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	card, ok := s.cards[uid]
+	if !ok {
+		return nil, ErrCardNotFound
+	}
+
+	delete(s.cards, uid)
+	if err := s.saveLocked(); err != nil {
+		s.cards[uid] = card
+		return nil, err
+	}
+	return card, nil
+}
+
+// Lookup returns the binding of a card, or nil if the UID is invalid or bound to nobody
+func (s *RFIDCardStore) Lookup(rawUID string) *models.RFIDCard {
+	uid, ok := models.NormalizeCardUID(rawUID)
+	if !ok {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	card, ok := s.cards[uid]
+	if !ok {
+		return nil
+	}
+	entry := *card
+	return &entry
+}
+
+// List returns the member's cards, or every card for an empty email, most recently bound first
+func (s *RFIDCardStore) List(email string) []*models.RFIDCard {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.listLocked(email)
+}
+
+// UIDs returns the UIDs bound to the member, for the Authentik attribute
+func (s *RFIDCardStore) UIDs(email string) []string {
+	uids := make([]string, 0)
+	for _, card := range s.List(email) {
+		uids = append(uids, card.UID)
+	}
+	sort.Strings(uids)
+	return uids
+}
+
+// listLocked returns copies of the member's cards, or of every card for an empty email; callers hold mu
+func (s *RFIDCardStore) listLocked(email string) []*models.RFIDCard {
+	cards := make([]*models.RFIDCard, 0)
+	for _, card := range s.cards {
+		if email == "" || strings.EqualFold(card.Email, email) {
+			entry := *card
+			cards = append(cards, &entry)
+		}
+	}
+
+	sort.Slice(cards, func(i, j int) bool {
+		return cards[i].BoundAt.After(cards[j].BoundAt)
+	})
+	return cards
+}
+
+// saveLocked writes every card to the file; callers hold mu
+func (s *RFIDCardStore) saveLocked() error {
+	return s.file.save(s.listLocked(""))
+}
@@ -0,0 +1,86 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+)
+
+func TestRFIDCardStore_Bind(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rfid_cards.json")
+	store, err := NewRFIDCardStore(path)
+	if err != nil {
+		t.Fatalf("Failed to create card store: %v", err)
+	}
+
+	if _, err := store.Bind("04:A2:2B:1A", testMember, "Blue fob", "staff@example.com"); err != nil {
+		t.Fatalf("Failed to bind card: %v", err)
+	}
+
+	// Test cases
+	tests := []struct {
+		name        string
+		uid         string
+		email       string
+		expectedErr error
+	}{
+		{"Same card in another format, same member", "04a22b1a", testMember.Email, nil},
+		{"Same card as a decimal number, other member", "0077736730", otherMember.Email, ErrCardTaken},
+		{"New card for the other member", "04B33C2B5D6E81", otherMember.Email, nil},
+		{"Invalid UID", "hello", otherMember.Email, ErrCardUID},
+	}
+
+	// Run tests
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			user := testMember
+			if tc.email == otherMember.Email {
+				user = otherMember
+			}
+			if _, err := store.Bind(tc.uid, user, "", "staff@example.com"); !errors.Is(err, tc.expectedErr) {
+				t.Errorf("Expected error %v, got %v", tc.expectedErr, err)
+			}
+		})
+	}
+
+	// Bindings survive a restart and are found in any format
+	reloaded, err := NewRFIDCardStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reload cards: %v", err)
+	}
+	card := reloaded.Lookup("04 A2 2B 1A")
+	if card == nil || card.Email != testMember.Email || card.Label != "" {
+		t.Errorf("Expected the member's card with its label cleared by the rebind, got %+v", card)
+	}
+	if uids := reloaded.UIDs(otherMember.Email); len(uids) != 1 || uids[0] != "04B33C2B5D6E81" {
+		t.Errorf("Unexpected UIDs for the other member: %v", uids)
+	}
+
+	// Unbinding frees the card for someone else
+	if _, err := reloaded.Unbind("04A22B1A"); err != nil {
+		t.Fatalf("Failed to unbind card: %v", err)
+	}
+	if _, err := reloaded.Unbind("04A22B1A"); !errors.Is(err, ErrCardNotFound) {
+		t.Errorf("Expected ErrCardNotFound, got %v", err)
+	}
+	if _, err := reloaded.Bind("04A22B1A", otherMember, "", "staff@example.com"); err != nil {
+		t.Errorf("Expected the unbound card to be free, got %v", err)
+	}
+}
+
+func TestRFIDCardStore_Limit(t *testing.T) {
+	store, _ := NewRFIDCardStore(filepath.Join(t.TempDir(), "rfid_cards.json"))
+
+	for i := 0; i < cardLimit; i++ {
+		if _, err := store.Bind(fmt.Sprintf("0000000%d", i), testMember, "", ""); err != nil {
+			t.Fatalf("Failed to bind card %d: %v", i, err)
+		}
+	}
+	if _, err := store.Bind("000000FF", testMember, "", ""); !errors.Is(err, ErrCardLimit) {
+		t.Errorf("Expected ErrCardLimit, got %v", err)
+	}
+	if cards := store.List(""); len(cards) != cardLimit {
+		t.Errorf("Expected %d cards, got %d", cardLimit, len(cards))
+	}
+}
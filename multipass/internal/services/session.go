@@ -0,0 +1,212 @@
+package services
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"multipass/internal/config"
+	"net/http"
+	"time"
+)
+
+const (
+	// SessionCookieName is the cookie holding the encrypted login session
+	SessionCookieName = "multipass_session"
+
+	// LoginStateCookieName is the cookie holding the in-flight OIDC login state
+	LoginStateCookieName = "multipass_login"
+
+	// loginStateMaxAge bounds how long a user may take on the provider's login page
+	loginStateMaxAge = 10 * time.Minute
+)
+
+// ErrNoSession is returned when the request carries no valid session
+var ErrNoSession = errors.New("no valid session")
+
+// Session is the identity stored in the encrypted session cookie
+type Session struct {
+	Email     string   `json:"email"`
+	Name      string   `json:"name"`
+	Username  string   `json:"username"`
+	UID       string   `json:"uid"`
+	Groups    []string `json:"groups"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+// LoginState is the state kept between the redirect to the provider and the callback
+type LoginState struct {
+	State     string `json:"state"`
+	Nonce     string `json:"nonce"`
+	Verifier  string `json:"verifier"`
+	ReturnTo  string `json:"return_to"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+// SessionManager encrypts and decrypts session cookies with AES-GCM
+type SessionManager struct {
+	aead   cipher.AEAD
+	maxAge time.Duration
+	secure bool
+}
+
+// NewSessionManager creates a session manager keyed from the configured session secret
+func NewSessionManager(cfg *config.Config) (*SessionManager, error) {
+	if cfg.SessionSecret == "" {
+		return nil, errors.New("session secret is required")
+	}
+
+	// Derive a dedicated AES-256 key so the raw secret is never used directly
+	key := sha256.Sum256([]byte("multipass-session:" + cfg.SessionSecret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session cipher: %w", err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session cipher: %w", err)
+	}
+
+	return &SessionManager{
+		aead:   aead,
+		maxAge: cfg.SessionMaxAge,
+		secure: cfg.IsProduction(),
+	}, nil
+}
+
+// Seal encrypts a value into a URL-safe string bound to the cookie it is stored in
+// The cookie name is authenticated as additional data, so a value sealed for one cookie cannot be replayed as another
+func (sm *SessionManager) Seal(cookieName string, v interface{}) (string, error) {
+	plaintext, err := json.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode session data: %w", err)
+	}
+
+	nonce := make([]byte, sm.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := sm.aead.Seal(nonce, nonce, plaintext, []byte(cookieName))
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open decrypts a string produced by Seal for the same cookie into v
+func (sm *SessionManager) Open(cookieName, value string, v interface{}) error {
+	sealed, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return fmt.Errorf("failed to decode session data: %w", err)
+	}
+
+	nonceSize := sm.aead.NonceSize()
+	if len(sealed) < nonceSize {
+		return errors.New("session data too short")
+	}
+
+	plaintext, err := sm.aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(cookieName))
+	if err != nil {
+		return fmt.Errorf("failed to decrypt session data: %w", err)
+	}
+
+	return json.Unmarshal(plaintext, v)
+}
+
+// Save stores the session in an encrypted cookie
+func (sm *SessionManager) Save(w http.ResponseWriter, session *Session) error {
+	session.ExpiresAt = time.Now().Add(sm.maxAge).Unix()
+
+	value, err := sm.Seal(SessionCookieName, session)
+	if err != nil {
+		return err
+	}
+
+	sm.setCookie(w, SessionCookieName, value, sm.maxAge)
+	return nil
+}
+
+// Load reads and validates the session cookie from the request
+func (sm *SessionManager) Load(r *http.Request) (*Session, error) {
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, ErrNoSession
+	}
+
+	var session Session
+	if err := sm.Open(SessionCookieName, cookie.Value, &session); err != nil {
+		return nil, ErrNoSession
+	}
+
+	if time.Now().Unix() > session.ExpiresAt {
+		return nil, ErrNoSession
+	}
+
+	// A session without an identity is never valid, whatever decrypted into it
+	if session.Email == "" {
+		return nil, ErrNoSession
+	}
+
+	return &session, nil
+}
+
+// Clear removes the session cookie
+func (sm *SessionManager) Clear(w http.ResponseWriter) {
+	sm.setCookie(w, SessionCookieName, "", -1)
+}
+
+// SaveLoginState stores the OIDC login state in a short-lived cookie
+func (sm *SessionManager) SaveLoginState(w http.ResponseWriter, state *LoginState) error {
+	state.ExpiresAt = time.Now().Add(loginStateMaxAge).Unix()
+
+	value, err := sm.Seal(LoginStateCookieName, state)
+	if err != nil {
+		return err
+	}
+
+	sm.setCookie(w, LoginStateCookieName, value, loginStateMaxAge)
+	return nil
+}
+
+// TakeLoginState reads the OIDC login state and clears its cookie so it can only be used once
+func (sm *SessionManager) TakeLoginState(w http.ResponseWriter, r *http.Request) (*LoginState, error) {
+	cookie, err := r.Cookie(LoginStateCookieName)
+	if err != nil || cookie.Value == "" {
+		return nil, errors.New("login state missing")
+	}
+	sm.setCookie(w, LoginStateCookieName, "", -1)
+
+	var state LoginState
+	if err := sm.Open(LoginStateCookieName, cookie.Value, &state); err != nil {
+		return nil, err
+	}
+
+	if time.Now().Unix() > state.ExpiresAt {
+		return nil, errors.New("login state expired")
+	}
+
+	return &state, nil
+}
+
+// setCookie writes an HTTP-only cookie; a negative maxAge deletes it
+func (sm *SessionManager) setCookie(w http.ResponseWriter, name, value string, maxAge time.Duration) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   sm.secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	} else {
+		cookie.MaxAge = int(maxAge.Seconds())
+	}
+
+	http.SetCookie(w, cookie)
+}
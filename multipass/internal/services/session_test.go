@@ -0,0 +1,100 @@
+package services
+
+import (
+	"multipass/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestSessionManager creates a session manager with a fixed secret
+func newTestSessionManager(t *testing.T) *SessionManager {
+	t.Helper()
+
+	sm, err := NewSessionManager(&config.Config{SessionSecret: "test-secret", SessionMaxAge: time.Hour})
+	if err != nil {
+		t.Fatalf("Failed to create session manager: %v", err)
+	}
+	return sm
+}
+
+// requestWithCookies builds a request carrying the cookies set on a recorder
+func requestWithCookies(recorder *httptest.ResponseRecorder, rename map[string]string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, cookie := range recorder.Result().Cookies() {
+		if name, ok := rename[cookie.Name]; ok {
+			cookie.Name = name
+		}
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+func TestSessionManagerLoad(t *testing.T) {
+	sm := newTestSessionManager(t)
+
+	// Test cases
+	tests := []struct {
+		name    string
+		setup   func(w http.ResponseWriter) error
+		rename  map[string]string
+		wantErr bool
+	}{
+		{
+			name: "Saved session loads",
+			setup: func(w http.ResponseWriter) error {
+				return sm.Save(w, &Session{Email: "member@example.com", Name: "Member"})
+			},
+			wantErr: false,
+		},
+		{
+			name: "Session without an email is rejected",
+			setup: func(w http.ResponseWriter) error {
+				return sm.Save(w, &Session{Name: "Nobody"})
+			},
+			wantErr: true,
+		},
+		{
+			name: "Login state replayed as the session cookie is rejected",
+			setup: func(w http.ResponseWriter) error {
+				return sm.SaveLoginState(w, &LoginState{State: "state", Nonce: "nonce", Verifier: "verifier", ReturnTo: "/"})
+			},
+			rename:  map[string]string{LoginStateCookieName: SessionCookieName},
+			wantErr: true,
+		},
+	}
+
+	// Run tests
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			if err := tt.setup(recorder); err != nil {
+				t.Fatalf("Failed to set up cookie: %v", err)
+			}
+
+			session, err := sm.Load(requestWithCookies(recorder, tt.rename))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && session.Email != "member@example.com" {
+				t.Errorf("Load() email = %q, want %q", session.Email, "member@example.com")
+			}
+		})
+	}
+}
+
+func TestSessionManagerTakeLoginStateRejectsSession(t *testing.T) {
+	sm := newTestSessionManager(t)
+
+	recorder := httptest.NewRecorder()
+	if err := sm.Save(recorder, &Session{Email: "member@example.com"}); err != nil {
+		t.Fatalf("Failed to save session: %v", err)
+	}
+
+	// A session cookie presented as the login state must not decrypt
+	req := requestWithCookies(recorder, map[string]string{SessionCookieName: LoginStateCookieName})
+	if _, err := sm.TakeLoginState(httptest.NewRecorder(), req); err == nil {
+		t.Error("TakeLoginState() accepted a session cookie")
+	}
+}
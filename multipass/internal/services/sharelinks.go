@@ -0,0 +1,297 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"multipass/internal/models"
+	"multipass/internal/utils"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Share link errors
+var (
+	ErrShareLinkExhausted = errors.New("share link view limit reached")
+	ErrShareLinkNotFound  = errors.New("share link not found")
+)
+
+// shareLinkState is the on-disk form of the share link store
+type shareLinkState struct {
+	Since time.Time           `json:"since"` // Share tokens issued from this time on must have a recorded link
+	Links []*models.ShareLink `json:"links"`
+}
+
+// ShareLinkStore keeps the options and view counts of share links in a JSON file in the data directory
+type ShareLinkStore struct {
+	mu    sync.Mutex
+	file  jsonFile
+	links map[string]*models.ShareLink
+	since time.Time
+	now   func() time.Time
+}
+
+// NewShareLinkStore loads the share links stored at path
+func NewShareLinkStore(path string) (*ShareLinkStore, error) {
+	store := &ShareLinkStore{
+		file:  jsonFile{path: path},
+		links: make(map[string]*models.ShareLink),
+		now:   time.Now,
+	}
+
+	var raw json.RawMessage
+	if err := store.file.load(&raw); err != nil {
+		return nil, err
+	}
+
+	var state shareLinkState
+	if len(raw) > 0 && raw[0] == '[' {
+		// Older files hold only the list of links; they were recorded since the first of them was created
+		if err := json.Unmarshal(raw, &state.Links); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+		for _, link := range state.Links {
+			if state.Since.IsZero() || link.CreatedAt.Before(state.Since) {
+				state.Since = link.CreatedAt
+			}
+		}
+	} else if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &state); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	}
+	for _, link := range state.Links {
+		store.links[link.ID] = link
+	}
+	store.since = state.Since
+
+	// Share tokens issued before the store was created have no recorded link; remember when that was
+	if store.since.IsZero() {
+		store.since = store.now().UTC().Truncate(time.Second)
+		if err := store.saveLocked(); err != nil {
+			return nil, err
+		}
+	}
+
+	return store, nil
+}
+
+// Issue signs a share token for the member and records the link's options
+func (s *ShareLinkStore) Issue(tokens *TokenService, user *models.UserProfile, scope string, validFor time.Duration, maxViews int) (string, *models.ShareLink, error) {
+	if !models.IsValidShareScope(scope) {
+		return "", nil, fmt.Errorf("unknown share scope: %s", scope)
+	}
+	if maxViews < 0 {
+		return "", nil, errors.New("view limit must not be negative")
+	}
+
+	token, data, err := tokens.IssueFor(user, utils.PurposeShare, validFor)
+	if err != nil {
+		return "", nil, err
+	}
+
+	link := &models.ShareLink{
+		ID:        data.ID,
+		Email:     user.Email,
+		Scope:     scope,
+		MaxViews:  maxViews,
+		CreatedAt: data.Timestamp,
+		ExpiresAt: data.ExpiresAt,
+	}
+	if err := s.Add(link); err != nil {
+		return "", nil, err
+	}
+
+	return token, link, nil
+}
+
+// Add records a newly issued share link
+func (s *ShareLinkStore) Add(link *models.ShareLink) error {
+	if link.ID == "" {
+		return errors.New("share link ID is required")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry := *link
+	s.links[link.ID] = &entry
+	return s.saveLocked()
+}
+
+// RecordView counts a view of the link with the token ID and returns its options
+// Tokens issued before share links were recorded have no entry; they return nil and no error
+// Any other token without a recorded link returns ErrShareLinkNotFound
+func (s *ShareLinkStore) RecordView(id string, issuedAt time.Time) (*models.ShareLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[id]
+	if !ok {
+		if issuedAt.Before(s.since) {
+			return nil, nil
+		}
+		return nil, ErrShareLinkNotFound
+	}
+
+	if link.MaxViews > 0 && link.Views >= link.MaxViews {
+		return nil, ErrShareLinkExhausted
+	}
+
+	now := s.now().UTC()
+	link.Views++
+	link.LastViewedAt = &now
+
+	// The count is kept in memory even if it cannot be saved, so the scope still applies
+	entry := *link
+	return &entry, s.saveLocked()
+}
+
+// Get returns the link with the token ID without counting a view, or nil if none was recorded
+func (s *ShareLinkStore) Get(id string) *models.ShareLink {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[id]
+	if !ok {
+		return nil
+	}
+	entry := *link
+	return &entry
+}
+
+// ListActive returns the member's links that can still be opened, newest first
+func (s *ShareLinkStore) ListActive(email string) []*models.ShareLink {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	links := make([]*models.ShareLink, 0)
+	for _, link := range s.links {
+		if strings.EqualFold(link.Email, email) && link.IsActive(now) {
+			entry := *link
+			links = append(links, &entry)
+		}
+	}
+
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].CreatedAt.After(links[j].CreatedAt)
+	})
+	return links
+}
+
+// Revoke marks the member's link as revoked and returns it
+func (s *ShareLinkStore) Revoke(id, email string) (*models.ShareLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	link, ok := s.links[id]
+	if !ok || !strings.EqualFold(link.Email, email) {
+		return nil, ErrShareLinkNotFound
+	}
+
+	now := s.now().UTC()
+	link.RevokedAt = &now
+	if err := s.saveLocked(); err != nil {
+		return nil, err
+	}
+
+	entry := *link
+	return &entry, nil
+}
+
+// RevokeAll marks every active link of the member as revoked, after their tokens were invalidated, and returns those links
+func (s *ShareLinkStore) RevokeAll(email string) ([]*models.ShareLink, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().UTC()
+	revoked := make([]*models.ShareLink, 0)
+	for _, link := range s.links {
+		if strings.EqualFold(link.Email, email) && link.IsActive(now) {
+			link.RevokedAt = &now
+			entry := *link
+			revoked = append(revoked, &entry)
+		}
+	}
+	if len(revoked) == 0 {
+		return revoked, nil
+	}
+
+	// Like view counts, the revocations are kept in memory even if they cannot be saved
+	return revoked, s.saveLocked()
+}
+
+// Shorten creates a Shlink short URL for the link that expires with its token and records it
+// The long URL is returned when Shlink is not configured, and also along with the error when Shlink fails
+func (s *ShareLinkStore) Shorten(shlink *ShlinkClient, id, longURL string) (string, error) {
+	if shlink == nil {
+		return longURL, nil
+	}
+
+	link := s.Get(id)
+	if link == nil {
+		return longURL, ErrShareLinkNotFound
+	}
+
+	// Tags let staff find multipass links in the Shlink dashboard
+	short, err := shlink.Shorten(longURL, []string{"multipass", "share", link.Scope}, link.ExpiresAt)
+	if err != nil {
+		return longURL, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.links[id]; ok {
+		entry.ShortURL = short.ShortURL
+		entry.ShortCode = short.ShortCode
+		if err := s.saveLocked(); err != nil {
+			return short.ShortURL, err
+		}
+	}
+
+	return short.ShortURL, nil
+}
+
+// DeleteShortURL deletes the link's Shlink short URL, if it has one, so a revoked link stops resolving
+func (s *ShareLinkStore) DeleteShortURL(shlink *ShlinkClient, id string) error {
+	if shlink == nil {
+		return nil
+	}
+
+	link := s.Get(id)
+	if link == nil || link.ShortCode == "" {
+		return nil
+	}
+
+	if err := shlink.Delete(link.ShortCode); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.links[id]; ok {
+		entry.ShortURL = ""
+		entry.ShortCode = ""
+	}
+	return s.saveLocked()
+}
+
+// saveLocked drops links that have expired and writes the store; callers hold mu
+func (s *ShareLinkStore) saveLocked() error {
+	now := s.now()
+	links := make([]*models.ShareLink, 0, len(s.links))
+	for id, link := range s.links {
+		if now.After(link.ExpiresAt) {
+			delete(s.links, id)
+			continue
+		}
+		links = append(links, link)
+	}
+
+	return s.file.save(shareLinkState{Since: s.since, Links: links})
+}
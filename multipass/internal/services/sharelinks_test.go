@@ -0,0 +1,205 @@
+package services
+
+import (
+	"errors"
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"multipass/internal/utils"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestTokenService creates a token service with a single test key
+func newTestTokenService(t *testing.T) *TokenService {
+	tokens, err := NewTokenService(&config.Config{
+		TokenActiveKID: utils.DefaultKeyID,
+		TokenKeys:      map[string]string{utils.DefaultKeyID: "test-secret"},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create token service: %v", err)
+	}
+	return tokens
+}
+
+// Members the store tests issue tokens for
+var (
+	testMember  = &models.UserProfile{MemberID: "42", Email: "member@example.com"}
+	otherMember = &models.UserProfile{MemberID: "43", Email: "other@example.com"}
+)
+
+func TestShareLinkStore_ViewLimit(t *testing.T) {
+	tokens := newTestTokenService(t)
+	store, _ := NewShareLinkStore(filepath.Join(t.TempDir(), "share_links.json"))
+
+	token, link, err := store.Issue(tokens, testMember, models.ShareScopeBasic, time.Hour, 2)
+	if err != nil {
+		t.Fatalf("Failed to issue share link: %v", err)
+	}
+
+	// The token carries the link's lifetime and ID
+	data, err := tokens.Verify(token, utils.PurposeShare)
+	if err != nil {
+		t.Fatalf("Failed to verify share token: %v", err)
+	}
+	if data.ID != link.ID || data.ExpiresAt.Sub(data.Timestamp) != time.Hour {
+		t.Errorf("Unexpected share token data: %+v", data)
+	}
+
+	// Two views are allowed, the third is refused
+	for i := 1; i <= 2; i++ {
+		viewed, err := store.RecordView(link.ID, link.CreatedAt)
+		if err != nil {
+			t.Fatalf("View %d should have been allowed: %v", i, err)
+		}
+		if viewed.Scope != models.ShareScopeBasic || viewed.Views != i {
+			t.Errorf("Unexpected link after view %d: %+v", i, viewed)
+		}
+	}
+	if _, err := store.RecordView(link.ID, link.CreatedAt); !errors.Is(err, ErrShareLinkExhausted) {
+		t.Errorf("Expected view limit error, got %v", err)
+	}
+	if active := store.ListActive("member@example.com"); len(active) != 0 {
+		t.Errorf("Expected used-up link to be inactive, got %d active links", len(active))
+	}
+}
+
+func TestShareLinkStore_ListAndRevoke(t *testing.T) {
+	tokens := newTestTokenService(t)
+	path := filepath.Join(t.TempDir(), "share_links.json")
+	store, _ := NewShareLinkStore(path)
+
+	_, first, _ := store.Issue(tokens, testMember, models.ShareScopeFull, time.Hour, 0)
+	store.Issue(tokens, testMember, models.ShareScopeFull, 24*time.Hour, 0)
+	store.Issue(tokens, otherMember, models.ShareScopeFull, time.Hour, 0)
+
+	if active := store.ListActive("Member@Example.com"); len(active) != 2 {
+		t.Fatalf("Expected 2 active links, got %d", len(active))
+	}
+
+	// Members can only revoke their own links
+	if _, err := store.Revoke(first.ID, "other@example.com"); !errors.Is(err, ErrShareLinkNotFound) {
+		t.Errorf("Expected not found for another member's link, got %v", err)
+	}
+	if _, err := store.Revoke(first.ID, "member@example.com"); err != nil {
+		t.Fatalf("Failed to revoke link: %v", err)
+	}
+
+	// Links survive a restart
+	reloaded, err := NewShareLinkStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reload store: %v", err)
+	}
+	if active := reloaded.ListActive("member@example.com"); len(active) != 1 {
+		t.Errorf("Expected 1 active link after revoking, got %d", len(active))
+	}
+
+}
+
+func TestShareLinkStore_UnrecordedTokens(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "share_links.json")
+	store, _ := NewShareLinkStore(path)
+	since := store.since
+
+	// Test cases
+	tests := []struct {
+		name     string
+		issuedAt time.Time
+		wantErr  error
+	}{
+		{"Token issued before links were recorded is not limited", since.Add(-time.Hour), nil},
+		{"Token issued since links were recorded is refused", since, ErrShareLinkNotFound},
+		{"Token issued later is refused", since.Add(time.Hour), ErrShareLinkNotFound},
+	}
+
+	// Run tests
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			link, err := store.RecordView("0000000000000000", tt.issuedAt)
+			if link != nil || !errors.Is(err, tt.wantErr) {
+				t.Errorf("RecordView() = %v, %v, want nil, %v", link, err, tt.wantErr)
+			}
+		})
+	}
+
+	// The time links were first recorded survives a restart
+	reloaded, err := NewShareLinkStore(path)
+	if err != nil {
+		t.Fatalf("Failed to reload store: %v", err)
+	}
+	if !reloaded.since.Equal(since) {
+		t.Errorf("Expected since %v after reload, got %v", since, reloaded.since)
+	}
+}
+
+func TestShareLinkStore_LoadsLinkList(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "share_links.json")
+	created := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+	links := []*models.ShareLink{{
+		ID:        "0123456789abcdef",
+		Email:     "member@example.com",
+		Scope:     models.ShareScopeBasic,
+		CreatedAt: created,
+		ExpiresAt: created.Add(24 * time.Hour),
+	}}
+	if err := (jsonFile{path: path}).save(links); err != nil {
+		t.Fatalf("Failed to write old store: %v", err)
+	}
+
+	// Files from before the store kept a start time count from their oldest link
+	store, err := NewShareLinkStore(path)
+	if err != nil {
+		t.Fatalf("Failed to load old store: %v", err)
+	}
+	if !store.since.Equal(created) {
+		t.Errorf("Expected since %v, got %v", created, store.since)
+	}
+	if link, err := store.RecordView("0123456789abcdef", created); err != nil || link.Scope != models.ShareScopeBasic {
+		t.Errorf("Expected the stored link to be found, got %v, %v", link, err)
+	}
+}
+
+func TestShareLinkStore_RevokeAll(t *testing.T) {
+	tokens := newTestTokenService(t)
+	store, _ := NewShareLinkStore(filepath.Join(t.TempDir(), "share_links.json"))
+
+	store.Issue(tokens, testMember, models.ShareScopeFull, time.Hour, 0)
+	store.Issue(tokens, testMember, models.ShareScopeBasic, time.Hour, 0)
+	store.Issue(tokens, otherMember, models.ShareScopeFull, time.Hour, 0)
+
+	revoked, err := store.RevokeAll("Member@Example.com")
+	if err != nil {
+		t.Fatalf("Failed to revoke links: %v", err)
+	}
+	if len(revoked) != 2 {
+		t.Errorf("Expected 2 revoked links, got %d", len(revoked))
+	}
+	for _, link := range revoked {
+		if link.RevokedAt == nil {
+			t.Errorf("Expected link %s to be marked revoked", link.ID)
+		}
+	}
+
+	// Only the member's links are revoked, and revoking again finds nothing
+	if active := store.ListActive("member@example.com"); len(active) != 0 {
+		t.Errorf("Expected no active links after revoking all, got %d", len(active))
+	}
+	if active := store.ListActive("other@example.com"); len(active) != 1 {
+		t.Errorf("Expected the other member's link to stay active, got %d", len(active))
+	}
+	if again, _ := store.RevokeAll("member@example.com"); len(again) != 0 {
+		t.Errorf("Expected nothing left to revoke, got %d", len(again))
+	}
+}
+
+func TestShareLinkStore_InvalidOptions(t *testing.T) {
+	tokens := newTestTokenService(t)
+	store, _ := NewShareLinkStore(filepath.Join(t.TempDir(), "share_links.json"))
+
+	if _, _, err := store.Issue(tokens, testMember, "everything", time.Hour, 0); err == nil {
+		t.Error("Expected unknown scope to be rejected")
+	}
+	if _, _, err := store.Issue(tokens, testMember, models.ShareScopeFull, time.Hour, -1); err == nil {
+		t.Error("Expected negative view limit to be rejected")
+	}
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"multipass/internal/config"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// ShortURL is a short URL created in Shlink
+type ShortURL struct {
+	ShortCode string `json:"shortCode"`
+	ShortURL  string `json:"shortUrl"`
+	LongURL   string `json:"longUrl"`
+}
+
+// ShlinkClient creates and deletes short URLs through the Shlink REST API
+type ShlinkClient struct {
+	client  *resty.Client
+	baseURL string
+	domain  string
+}
+
+// shlinkCreateRequest is the body of POST /rest/v3/short-urls
+type shlinkCreateRequest struct {
+	LongURL    string   `json:"longUrl"`
+	Tags       []string `json:"tags,omitempty"`
+	ValidUntil string   `json:"validUntil,omitempty"`
+	Domain     string   `json:"domain,omitempty"`
+	Crawlable  bool     `json:"crawlable"`
+}
+
+// NewShlinkClient creates a Shlink client from the SHLINK_* settings, or returns nil if Shlink is not configured
+func NewShlinkClient(cfg *config.Config) *ShlinkClient {
+	if cfg.ShlinkURL == "" || cfg.ShlinkAPIKey == "" {
+		return nil
+	}
+
+	// A slow Shlink must not hold up the share page; callers fall back to the long URL
+	client := resty.New().SetTimeout(cfg.ShlinkTimeout)
+	client.SetHeader("Accept", "application/json")
+	client.SetHeader("X-Api-Key", cfg.ShlinkAPIKey)
+
+	return &ShlinkClient{
+		client:  client,
+		baseURL: cfg.ShlinkURL,
+		domain:  cfg.ShlinkDomain,
+	}
+}
+
+// Shorten creates a short URL for longURL that stops resolving at validUntil
+func (sc *ShlinkClient) Shorten(longURL string, tags []string, validUntil time.Time) (*ShortURL, error) {
+	body := shlinkCreateRequest{
+		LongURL:   longURL,
+		Tags:      tags,
+		Domain:    sc.domain,
+		Crawlable: false,
+	}
+	if !validUntil.IsZero() {
+		body.ValidUntil = validUntil.UTC().Format(time.RFC3339)
+	}
+
+	resp, err := sc.client.R().
+		SetHeader("Content-Type", "application/json").
+		SetBody(body).
+		Post(sc.baseURL + "/rest/v3/short-urls")
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach Shlink: %w", err)
+	}
+	if resp.StatusCode() != http.StatusOK && resp.StatusCode() != http.StatusCreated {
+		return nil, fmt.Errorf("failed to create short URL, status: %d", resp.StatusCode())
+	}
+
+	var short ShortURL
+	if err := json.Unmarshal(resp.Body(), &short); err != nil {
+		return nil, fmt.Errorf("failed to parse short URL: %w", err)
+	}
+	if short.ShortCode == "" || short.ShortURL == "" {
+		return nil, fmt.Errorf("Shlink returned no short URL")
+	}
+
+	return &short, nil
+}
+
+// Delete removes a short URL; one that is already gone is not an error
+func (sc *ShlinkClient) Delete(shortCode string) error {
+	req := sc.client.R()
+	if sc.domain != "" {
+		req.SetQueryParam("domain", sc.domain)
+	}
+
+	resp, err := req.Delete(sc.baseURL + "/rest/v3/short-urls/" + url.PathEscape(shortCode))
+	if err != nil {
+		return fmt.Errorf("failed to reach Shlink: %w", err)
+	}
+	if resp.StatusCode() != http.StatusNoContent && resp.StatusCode() != http.StatusNotFound {
+		return fmt.Errorf("failed to delete short URL, status: %d", resp.StatusCode())
+	}
+
+	return nil
+}
@@ -0,0 +1,157 @@
+package services
+
+import (
+	"encoding/json"
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// shlinkStub is a minimal Shlink REST API that records the requests it receives
+type shlinkStub struct {
+	mu      sync.Mutex
+	created []shlinkCreateRequest
+	deleted []string
+}
+
+func (s *shlinkStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Header.Get("X-Api-Key") != "test-key" {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/rest/v3/short-urls":
+		var body shlinkCreateRequest
+		json.NewDecoder(r.Body).Decode(&body)
+		s.created = append(s.created, body)
+		json.NewEncoder(w).Encode(ShortURL{ShortCode: "abc12", ShortURL: "https://s.example.com/abc12", LongURL: body.LongURL})
+	case r.Method == http.MethodDelete:
+		s.deleted = append(s.deleted, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// newTestShlinkClient creates a client for the Shlink at baseURL
+func newTestShlinkClient(baseURL string) *ShlinkClient {
+	return NewShlinkClient(&config.Config{
+		ShlinkURL:     baseURL,
+		ShlinkAPIKey:  "test-key",
+		ShlinkTimeout: time.Second,
+	})
+}
+
+func TestNewShlinkClient_Disabled(t *testing.T) {
+	// Test cases
+	tests := []struct {
+		name string
+		cfg  *config.Config
+	}{
+		{"No URL", &config.Config{ShlinkAPIKey: "test-key"}},
+		{"No API key", &config.Config{ShlinkURL: "https://s.example.com"}},
+	}
+
+	// Run tests
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if client := NewShlinkClient(tc.cfg); client != nil {
+				t.Errorf("Expected Shlink to be disabled")
+			}
+		})
+	}
+}
+
+func TestShareLinkStore_ShortenAndDelete(t *testing.T) {
+	stub := &shlinkStub{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	tokens := newTestTokenService(t)
+	store, _ := NewShareLinkStore(filepath.Join(t.TempDir(), "share_links.json"))
+	shlink := newTestShlinkClient(server.URL)
+
+	_, link, err := store.Issue(tokens, testMember, models.ShareScopeBasic, time.Hour, 0)
+	if err != nil {
+		t.Fatalf("Failed to issue share link: %v", err)
+	}
+
+	// The short URL expires with the token and carries the link's scope as a tag
+	shortURL, err := store.Shorten(shlink, link.ID, "https://multipass.example.com/c/token")
+	if err != nil {
+		t.Fatalf("Failed to shorten share link: %v", err)
+	}
+	if shortURL != "https://s.example.com/abc12" {
+		t.Errorf("Expected short URL, got %s", shortURL)
+	}
+	if len(stub.created) != 1 {
+		t.Fatalf("Expected 1 short URL to be created, got %d", len(stub.created))
+	}
+	created := stub.created[0]
+	if created.LongURL != "https://multipass.example.com/c/token" || created.ValidUntil != link.ExpiresAt.UTC().Format(time.RFC3339) {
+		t.Errorf("Unexpected create request: %+v", created)
+	}
+	if len(created.Tags) != 3 || created.Tags[2] != models.ShareScopeBasic {
+		t.Errorf("Unexpected tags: %v", created.Tags)
+	}
+	if got := store.Get(link.ID); got.ShortCode != "abc12" {
+		t.Errorf("Expected short code to be recorded, got %+v", got)
+	}
+
+	// Revoking deletes the short URL once
+	if err := store.DeleteShortURL(shlink, link.ID); err != nil {
+		t.Fatalf("Failed to delete short URL: %v", err)
+	}
+	if err := store.DeleteShortURL(shlink, link.ID); err != nil {
+		t.Fatalf("Second delete should be a no-op: %v", err)
+	}
+	if len(stub.deleted) != 1 || stub.deleted[0] != "/rest/v3/short-urls/abc12" {
+		t.Errorf("Unexpected delete requests: %v", stub.deleted)
+	}
+}
+
+func TestShareLinkStore_ShortenFallback(t *testing.T) {
+	tokens := newTestTokenService(t)
+	store, _ := NewShareLinkStore(filepath.Join(t.TempDir(), "share_links.json"))
+	_, link, _ := store.Issue(tokens, testMember, models.ShareScopeFull, time.Hour, 0)
+	longURL := "https://multipass.example.com/c/token"
+
+	// A server that is already closed stands in for an unreachable Shlink
+	server := httptest.NewServer(&shlinkStub{})
+	server.Close()
+
+	// Test cases
+	tests := []struct {
+		name      string
+		shlink    *ShlinkClient
+		expectErr bool
+	}{
+		{"Shlink not configured", nil, false},
+		{"Shlink unreachable", newTestShlinkClient(server.URL), true},
+	}
+
+	// Run tests
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			shortURL, err := store.Shorten(tc.shlink, link.ID, longURL)
+			if (err != nil) != tc.expectErr {
+				t.Errorf("Expected error %v, got %v", tc.expectErr, err)
+			}
+			if shortURL != longURL {
+				t.Errorf("Expected fallback to the long URL, got %s", shortURL)
+			}
+			if got := store.Get(link.ID); got.ShortCode != "" {
+				t.Errorf("Expected no short code, got %s", got.ShortCode)
+			}
+		})
+	}
+}
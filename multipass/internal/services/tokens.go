@@ -0,0 +1,179 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"multipass/internal/utils"
+	"strconv"
+	"time"
+)
+
+// Token verification errors
+var (
+	ErrTokenPurpose  = errors.New("token not valid for this use")   // A valid token presented where its purpose is not accepted
+	ErrLiveCodeEarly = errors.New("live code issued in the future") // A live code beyond the allowed clock skew
+	ErrTokenNoOwner  = errors.New("token owner not found")          // The member the token was issued to no longer exists
+)
+
+// Reasons a token fails verification, as reported to scanners
+const (
+	TokenReasonExpired      = "expired"       // Past its expiry, or a v1 token after TOKEN_V1_UNTIL
+	TokenReasonBadSignature = "bad_signature" // Tampered, or signed with a key not in the keyring
+	TokenReasonMalformed    = "malformed"     // Not a token at all
+	TokenReasonWrongPurpose = "wrong_purpose" // A valid token of a kind not accepted here
+	TokenReasonNotYetValid  = "not_yet_valid" // A live code from beyond the allowed clock skew
+	TokenReasonRevoked      = "revoked"       // Revoked by ID or by the member's epoch
+	TokenReasonExhausted    = "exhausted"     // A share link that reached its view limit or was revoked
+	TokenReasonUserMissing  = "user_missing"  // The member no longer exists in Authentik
+)
+
+// TokenFailureReason maps an error from Verify to one of the TokenReason codes
+func TokenFailureReason(err error) string {
+	switch {
+	case errors.Is(err, utils.ErrTokenExpired):
+		return TokenReasonExpired
+	case errors.Is(err, utils.ErrTokenSignature):
+		return TokenReasonBadSignature
+	case errors.Is(err, ErrTokenPurpose):
+		return TokenReasonWrongPurpose
+	case errors.Is(err, ErrLiveCodeEarly):
+		return TokenReasonNotYetValid
+	case errors.Is(err, ErrTokenRevoked):
+		return TokenReasonRevoked
+	case errors.Is(err, ErrTokenNoOwner):
+		return TokenReasonUserMissing
+	default:
+		return TokenReasonMalformed
+	}
+}
+
+// TokenService issues and verifies member tokens with the configured keyring
+type TokenService struct {
+	keyring     *utils.Keyring
+	revocations *RevocationStore
+
+	liveInterval time.Duration // Rotation period of live card codes
+	liveSkew     time.Duration // Grace period either side of a live code's window
+}
+
+// NewTokenService creates a token service from the TOKEN_* settings
+// Tokens found in revocations are rejected; a nil store disables revocation checks
+func NewTokenService(cfg *config.Config, revocations *RevocationStore) (*TokenService, error) {
+	keyring, err := utils.NewKeyring(cfg.TokenActiveKID, cfg.TokenKeys)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token keyring: %w", err)
+	}
+	keyring.LegacyUntil = cfg.TokenV1Until
+
+	return &TokenService{
+		keyring:      keyring,
+		revocations:  revocations,
+		liveInterval: cfg.LiveCodeInterval,
+		liveSkew:     cfg.LiveCodeSkew,
+	}, nil
+}
+
+// Issue signs a new token for the user with the active key
+func (ts *TokenService) Issue(userID, email string, purpose utils.TokenPurpose, validFor time.Duration) (string, *utils.TokenData, error) {
+	return ts.keyring.Issue(userID, email, purpose, validFor)
+}
+
+// IssueFor signs a token for the member with the active key
+// Members with a numeric member ID get a compact v3 token that keeps their email out of URLs;
+// anyone else gets a v2 token naming their Authentik ID or email
+func (ts *TokenService) IssueFor(user *models.UserProfile, purpose utils.TokenPurpose, validFor time.Duration) (string, *utils.TokenData, error) {
+	if memberRef, err := strconv.ParseUint(user.MemberID, 10, 64); err == nil && memberRef > 0 {
+		return ts.keyring.IssueCompact(memberRef, purpose, validFor)
+	}
+
+	userID := user.AuthentikID
+	if userID == "" {
+		userID = user.Email
+	}
+	return ts.keyring.Issue(userID, user.Email, purpose, validFor)
+}
+
+// IssueLiveCode signs a code for the rotating QR on the member's own card
+// It is valid for one interval plus the skew, so a screenshot stops working within about a minute
+func (ts *TokenService) IssueLiveCode(user *models.UserProfile) (string, *utils.TokenData, error) {
+	return ts.IssueFor(user, utils.PurposeLive, ts.liveInterval+ts.liveSkew)
+}
+
+// LiveCodeInterval returns how often the card should fetch a new live code
+func (ts *TokenService) LiveCodeInterval() time.Duration {
+	return ts.liveInterval
+}
+
+// Verify checks the token's signature, expiry and revocation and that its purpose is one of the accepted ones
+// The token data is returned alongside ErrTokenPurpose and ErrTokenRevoked so callers can log whose token it was
+func (ts *TokenService) Verify(token string, accepted ...utils.TokenPurpose) (*utils.TokenData, error) {
+	data, err := ts.Parse(token)
+	if err != nil {
+		return nil, err
+	}
+
+	if !acceptsPurpose(data.Purpose, accepted) {
+		return data, ErrTokenPurpose
+	}
+
+	// Expiry covers the late side of a live code's window; this covers the early side
+	if data.Purpose == utils.PurposeLive && data.Timestamp.After(time.Now().Add(ts.liveSkew)) {
+		return data, ErrLiveCodeEarly
+	}
+
+	if ts.revocations != nil {
+		if err := ts.revocations.Check(data); err != nil {
+			return data, err
+		}
+	}
+
+	return data, nil
+}
+
+// CheckOwner returns ErrTokenRevoked if the token was issued before its owner invalidated their tokens
+// Compact tokens carry no email, so the owner's epoch can only be checked once they have been looked up
+func (ts *TokenService) CheckOwner(data *utils.TokenData, owner *models.UserProfile) error {
+	if data.Timestamp.Before(owner.TokenEpoch) {
+		return ErrTokenRevoked
+	}
+	if ts.revocations != nil && data.Timestamp.Before(ts.revocations.UserEpoch(owner.Email)) {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// Owner looks up the member a verified token was issued to in Authentik and checks the token is still theirs
+// Failures wrap ErrTokenNoOwner or are ErrTokenRevoked, so TokenFailureReason reports them to scanners
+func (ts *TokenService) Owner(cfg *config.Config, data *utils.TokenData) (*models.UserProfile, error) {
+	// Look up the member the token was issued to
+	user, err := NewAuthentikClient(cfg).GetUserForToken(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTokenNoOwner, err)
+	}
+	if user == nil {
+		return nil, ErrTokenNoOwner
+	}
+
+	// The member may have invalidated their links, here or from another instance
+	if err := ts.CheckOwner(data, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Parse checks the token's signature and expiry only, so staff can inspect a token before revoking it
+func (ts *TokenService) Parse(token string) (*utils.TokenData, error) {
+	return ts.keyring.Verify(token)
+}
+
+// acceptsPurpose returns true if purpose is one of accepted
+func acceptsPurpose(purpose utils.TokenPurpose, accepted []utils.TokenPurpose) bool {
+	for _, candidate := range accepted {
+		if purpose == candidate {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"multipass/internal/config"
+	"multipass/internal/models"
+	"multipass/internal/utils"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTokenService_LiveCode(t *testing.T) {
+	cfg := &config.Config{
+		TokenActiveKID:   utils.DefaultKeyID,
+		TokenKeys:        map[string]string{utils.DefaultKeyID: "test-secret"},
+		LiveCodeInterval: 30 * time.Second,
+		LiveCodeSkew:     15 * time.Second,
+	}
+	tokens, err := NewTokenService(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create token service: %v", err)
+	}
+
+	code, issued, err := tokens.IssueLiveCode(testMember)
+	if err != nil {
+		t.Fatalf("Failed to issue live code: %v", err)
+	}
+
+	// A live code lives for one interval plus the skew
+	if lifetime := issued.ExpiresAt.Sub(issued.Timestamp); lifetime != 45*time.Second {
+		t.Errorf("Expected live code to be valid for 45s, got %v", lifetime)
+	}
+
+	data, err := tokens.Verify(code, utils.PurposeCard, utils.PurposeLive)
+	if err != nil {
+		t.Fatalf("Failed to verify live code: %v", err)
+	}
+	if data.Purpose != utils.PurposeLive || data.UserID != "42" {
+		t.Errorf("Unexpected live code data: %+v", data)
+	}
+
+	// Live codes cannot stand in for card tokens, e.g. to mint more live codes
+	if _, err := tokens.Verify(code, utils.PurposeCard); !errors.Is(err, ErrTokenPurpose) {
+		t.Errorf("Expected purpose mismatch, got %v", err)
+	}
+}
+
+func TestTokenFailureReason(t *testing.T) {
+	revocations, _ := NewRevocationStore(filepath.Join(t.TempDir(), "token_revocations.json"))
+	tokens, _ := NewTokenService(&config.Config{
+		TokenActiveKID: utils.DefaultKeyID,
+		TokenKeys:      map[string]string{utils.DefaultKeyID: "test-secret"},
+	}, revocations)
+	other, _ := NewTokenService(&config.Config{
+		TokenActiveKID: "other",
+		TokenKeys:      map[string]string{"other": "other-secret"},
+	}, nil)
+
+	valid, _, _ := tokens.Issue("42", "member@example.com", utils.PurposeCard, time.Hour)
+	expired, _, _ := tokens.Issue("42", "member@example.com", utils.PurposeCard, -time.Minute)
+	guest, _, _ := tokens.Issue("42", "member@example.com", utils.PurposeGuest, time.Hour)
+	revoked, revokedData, _ := tokens.Issue("42", "member@example.com", utils.PurposeCard, time.Hour)
+	revocations.Revoke(revokedData.ID, revokedData.Email, revokedData.ExpiresAt, "staff@example.com", "test")
+	unknownKey, _, _ := other.Issue("42", "member@example.com", utils.PurposeCard, time.Hour)
+
+	// Test cases
+	testCases := []struct {
+		name     string
+		token    string
+		expected string
+	}{
+		{name: "Expired token", token: expired, expected: TokenReasonExpired},
+		{name: "Unknown key", token: unknownKey, expected: TokenReasonBadSignature},
+		{name: "Swapped signature", token: strings.Split(valid, ".")[0] + "." + strings.Split(expired, ".")[1], expected: TokenReasonBadSignature},
+		{name: "Guest pass", token: guest, expected: TokenReasonWrongPurpose},
+		{name: "Revoked token", token: revoked, expected: TokenReasonRevoked},
+		{name: "Not a token", token: "hello", expected: TokenReasonMalformed},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := tokens.Verify(tc.token, utils.PurposeCard)
+			if err == nil {
+				t.Fatal("Expected verification to fail, but it succeeded")
+			}
+			if reason := TokenFailureReason(err); reason != tc.expected {
+				t.Errorf("Expected reason %s, got %s (%v)", tc.expected, reason, err)
+			}
+		})
+	}
+
+	// A token whose member is gone is reported as such, even with the lookup error wrapped in
+	if reason := TokenFailureReason(fmt.Errorf("%w: %v", ErrTokenNoOwner, errors.New("404"))); reason != TokenReasonUserMissing {
+		t.Errorf("Expected reason %s for a missing owner, got %s", TokenReasonUserMissing, reason)
+	}
+}
+
+func TestTokenService_IssueFor(t *testing.T) {
+	revocations, _ := NewRevocationStore(filepath.Join(t.TempDir(), "token_revocations.json"))
+	tokens, _ := NewTokenService(&config.Config{
+		TokenActiveKID: utils.DefaultKeyID,
+		TokenKeys:      map[string]string{utils.DefaultKeyID: "test-secret"},
+	}, revocations)
+
+	// Members with a numeric member ID get compact tokens without their email
+	compact, data, err := tokens.IssueFor(testMember, utils.PurposeCard, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+	if data.Version != utils.TokenVersion3 || strings.Contains(compact, ".") {
+		t.Errorf("Expected a compact token, got %s", compact)
+	}
+
+	// Anyone else falls back to a v2 token naming their email
+	_, data, _ = tokens.IssueFor(&models.UserProfile{Email: "guest@example.com"}, utils.PurposeCard, time.Hour)
+	if data.Version != utils.TokenVersion2 || data.Email != "guest@example.com" {
+		t.Errorf("Expected a v2 token, got %+v", data)
+	}
+
+	// Compact tokens are revoked by the owner's epoch once the owner is known
+	data, err = tokens.Verify(compact, utils.PurposeCard)
+	if err != nil {
+		t.Fatalf("Failed to verify compact token: %v", err)
+	}
+	if err := tokens.CheckOwner(data, testMember); err != nil {
+		t.Errorf("Expected token to belong to a member in good standing: %v", err)
+	}
+	revocations.now = func() time.Time { return time.Now().Add(time.Minute) }
+	revocations.InvalidateUser(testMember.Email)
+	if err := tokens.CheckOwner(data, testMember); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Expected token issued before the epoch to be revoked, got %v", err)
+	}
+}
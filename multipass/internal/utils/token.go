@@ -2,8 +2,10 @@ package utils
 
 import (
 	"crypto/hmac"
+	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
+	"encoding/binary"
 	"encoding/hex"
 	"errors"
 	"fmt"
@@ -12,46 +14,238 @@ import (
 )
 
 const (
-	// TokenValidityDuration is the duration for which a token is valid
+	// TokenValidityDuration is the default duration for which a token is valid, and the lifetime of v1 tokens
 	TokenValidityDuration = 24 * 30 * time.Hour // 30 days
+
+	// TokenVersion2 is the version byte of binary, key-ID-carrying tokens
+	TokenVersion2 = 2
+
+	// DefaultKeyID names the key derived from TOKEN_SECRET
+	DefaultKeyID = "default"
+
+	// tokenIDLength is the number of random bytes identifying a single token
+	tokenIDLength = 8
+)
+
+// TokenPurpose restricts where a token may be used
+type TokenPurpose uint8
+
+// Token purposes
+const (
+	PurposeCard  TokenPurpose = 1 // Opens the member's own card
+	PurposeShare TokenPurpose = 2 // Share link handed to someone else
+	PurposeGuest TokenPurpose = 3 // Guest pass
+	PurposeLive  TokenPurpose = 4 // Rotating code shown on the member's own card
+)
+
+// String returns the purpose name
+func (p TokenPurpose) String() string {
+	switch p {
+	case PurposeCard:
+		return "card"
+	case PurposeShare:
+		return "share"
+	case PurposeGuest:
+		return "guest"
+	case PurposeLive:
+		return "live"
+	default:
+		return "unknown"
+	}
+}
+
+// Token verification errors callers can tell apart
+var (
+	ErrTokenExpired   = errors.New("token expired")
+	ErrTokenSignature = errors.New("invalid token signature")
 )
 
 // TokenData represents the data encoded in a token
 type TokenData struct {
-	UserID    string    // Authentik user ID
-	Email     string    // User email
-	Timestamp time.Time // Token creation time
+	UserID    string       // Authentik user ID
+	Email     string       // User email; empty for v3 tokens
+	Timestamp time.Time    // Token creation time
+	Version   int          // Token format version (1, 2 or 3)
+	KeyID     string       // Key that signed the token; empty for v1
+	Purpose   TokenPurpose // Where the token may be used; v1 tokens are treated as card tokens
+	ExpiresAt time.Time    // Explicit expiry
+	ID        string       // Random token ID (hex); empty for v1
 }
 
-// GenerateToken creates a secure token for a user that can be used in public URLs
-// The token format is: base64(userID:email:timestamp):hmac
-func GenerateToken(userID, email, secret string) (string, error) {
-	if userID == "" || email == "" || secret == "" {
-		return "", errors.New("userID, email, and secret are required")
+// Keyring holds the secrets tokens are signed and verified with
+// The active key signs new tokens; every other key only verifies, which allows rotation without downtime
+type Keyring struct {
+	activeKID string
+	keys      map[string][]byte
+
+	// Legacy v1 tokens carry no key ID; they are checked against every key until LegacyUntil
+	LegacyUntil time.Time // Zero accepts v1 tokens for as long as they are valid
+}
+
+// NewKeyring creates a keyring from key ID to secret; activeKID must be one of them
+func NewKeyring(activeKID string, secrets map[string]string) (*Keyring, error) {
+	kr := &Keyring{
+		activeKID: activeKID,
+		keys:      make(map[string][]byte, len(secrets)),
 	}
 
-	// Create timestamp
-	timestamp := time.Now().UTC().Format(time.RFC3339)
+	for kid, secret := range secrets {
+		if kid == "" || len(kid) > 255 || secret == "" {
+			return nil, fmt.Errorf("invalid token key %q", kid)
+		}
+		kr.keys[kid] = []byte(secret)
+	}
 
-	// Create payload
-	payload := fmt.Sprintf("%s:%s:%s", userID, email, timestamp)
+	if _, ok := kr.keys[activeKID]; !ok {
+		return nil, fmt.Errorf("active token key %q is not in the keyring", activeKID)
+	}
 
-	// Base64 encode the payload
-	encodedPayload := base64.URLEncoding.EncodeToString([]byte(payload))
+	return kr, nil
+}
 
-	// Generate HMAC
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write([]byte(encodedPayload))
-	signature := hex.EncodeToString(h.Sum(nil))
+// ActiveKeyID returns the ID of the key that signs new tokens
+func (kr *Keyring) ActiveKeyID() string {
+	return kr.activeKID
+}
+
+// Issue creates a v2 token for the user, valid for validFor
+// The token format is: base64url(payload).base64url(hmac), where the payload is
+// version | purpose | len(kid) kid | iat | exp | id | len(userID) userID | len(email) email
+func (kr *Keyring) Issue(userID, email string, purpose TokenPurpose, validFor time.Duration) (string, *TokenData, error) {
+	if userID == "" || email == "" {
+		return "", nil, errors.New("userID and email are required")
+	}
+	if len(userID) > 255 || len(email) > 255 {
+		return "", nil, errors.New("userID and email must be at most 255 bytes")
+	}
 
-	// Combine payload and signature
-	token := fmt.Sprintf("%s:%s", encodedPayload, signature)
+	id := make([]byte, tokenIDLength)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
+	// Second precision keeps the payload small
+	issuedAt := time.Now().UTC().Truncate(time.Second)
+	data := &TokenData{
+		UserID:    userID,
+		Email:     email,
+		Timestamp: issuedAt,
+		Version:   TokenVersion2,
+		KeyID:     kr.activeKID,
+		Purpose:   purpose,
+		ExpiresAt: issuedAt.Add(validFor),
+		ID:        hex.EncodeToString(id),
+	}
+
+	// Build the binary payload
+	payload := []byte{TokenVersion2, byte(purpose)}
+	payload = appendShortString(payload, data.KeyID)
+	payload = binary.BigEndian.AppendUint64(payload, uint64(data.Timestamp.Unix()))
+	payload = binary.BigEndian.AppendUint64(payload, uint64(data.ExpiresAt.Unix()))
+	payload = append(payload, id...)
+	payload = appendShortString(payload, userID)
+	payload = appendShortString(payload, email)
+
+	encodedPayload := base64.RawURLEncoding.EncodeToString(payload)
+	signature := signPayload(kr.keys[kr.activeKID], encodedPayload)
 
-	return token, nil
+	return encodedPayload + "." + base64.RawURLEncoding.EncodeToString(signature), data, nil
 }
 
-// VerifyToken verifies a token and returns the user data if valid
+// Verify checks a v2 or compact v3 token, or a legacy v1 token during the migration window, and returns its data
+func (kr *Keyring) Verify(token string) (*TokenData, error) {
+	// v1 tokens are "payload:hexsignature"; v2 tokens use a dot; compact v3 tokens have neither
+	if strings.Contains(token, ":") {
+		return kr.verifyV1(token)
+	}
+	if !strings.Contains(token, ".") {
+		return kr.verifyCompact(token)
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, errors.New("invalid token format")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode payload: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	data, err := parseV2Payload(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	// Verify HMAC with the key named in the token
+	key, ok := kr.keys[data.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown token key %q", ErrTokenSignature, data.KeyID)
+	}
+	if !hmac.Equal(signature, signPayload(key, parts[0])) {
+		return nil, ErrTokenSignature
+	}
+
+	// Check if token is expired
+	if time.Now().After(data.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	return data, nil
+}
+
+// verifyV1 checks a legacy token against every key in the ring
+func (kr *Keyring) verifyV1(token string) (*TokenData, error) {
+	if !kr.LegacyUntil.IsZero() && time.Now().After(kr.LegacyUntil) {
+		return nil, fmt.Errorf("%w: legacy tokens are no longer accepted", ErrTokenExpired)
+	}
+
+	var lastErr error
+	for _, key := range kr.keys {
+		data, err := verifyV1(token, string(key))
+		if err == nil {
+			return data, nil
+		}
+		// Only the signature depends on the key; anything else fails with every key
+		if !errors.Is(err, ErrTokenSignature) {
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// GenerateToken creates a secure card token for a user that can be used in public URLs
+// It signs a v2 token with a single-key keyring named DefaultKeyID
+func GenerateToken(userID, email, secret string) (string, error) {
+	if userID == "" || email == "" || secret == "" {
+		return "", errors.New("userID, email, and secret are required")
+	}
+
+	kr, err := NewKeyring(DefaultKeyID, map[string]string{DefaultKeyID: secret})
+	if err != nil {
+		return "", err
+	}
+
+	token, _, err := kr.Issue(userID, email, PurposeCard, TokenValidityDuration)
+	return token, err
+}
+
+// VerifyToken verifies a v1 or v2 token signed with the secret and returns the user data if valid
 func VerifyToken(token, secret string) (*TokenData, error) {
+	kr, err := NewKeyring(DefaultKeyID, map[string]string{DefaultKeyID: secret})
+	if err != nil {
+		return nil, err
+	}
+	return kr.Verify(token)
+}
+
+// verifyV1 verifies a legacy token of the form base64(userID:email:timestamp):hmac
+func verifyV1(token, secret string) (*TokenData, error) {
 	// Split token into payload and signature
 	parts := strings.Split(token, ":")
 	if len(parts) != 2 {
@@ -62,12 +256,9 @@ func VerifyToken(token, secret string) (*TokenData, error) {
 	providedSignature := parts[1]
 
 	// Verify HMAC
-	h := hmac.New(sha256.New, []byte(secret))
-	h.Write([]byte(encodedPayload))
-	expectedSignature := hex.EncodeToString(h.Sum(nil))
-
+	expectedSignature := hex.EncodeToString(signPayload([]byte(secret), encodedPayload))
 	if !hmac.Equal([]byte(providedSignature), []byte(expectedSignature)) {
-		return nil, errors.New("invalid token signature")
+		return nil, ErrTokenSignature
 	}
 
 	// Decode payload
@@ -95,12 +286,89 @@ func VerifyToken(token, secret string) (*TokenData, error) {
 
 	// Check if token is expired
 	if time.Since(timestamp) > TokenValidityDuration {
-		return nil, errors.New("token expired")
+		return nil, ErrTokenExpired
 	}
 
 	return &TokenData{
 		UserID:    userID,
 		Email:     email,
 		Timestamp: timestamp,
+		Version:   1,
+		Purpose:   PurposeCard,
+		ExpiresAt: timestamp.Add(TokenValidityDuration),
 	}, nil
 }
+
+// parseV2Payload decodes the binary payload of a v2 token
+func parseV2Payload(payload []byte) (*TokenData, error) {
+	r := &byteReader{buf: payload}
+
+	if version := r.byte(); version != TokenVersion2 {
+		return nil, fmt.Errorf("unsupported token version %d", version)
+	}
+
+	data := &TokenData{Version: TokenVersion2}
+	data.Purpose = TokenPurpose(r.byte())
+	data.KeyID = r.shortString()
+	data.Timestamp = time.Unix(int64(r.uint64()), 0).UTC()
+	data.ExpiresAt = time.Unix(int64(r.uint64()), 0).UTC()
+	data.ID = hex.EncodeToString(r.bytes(tokenIDLength))
+	data.UserID = r.shortString()
+	data.Email = r.shortString()
+
+	if r.err != nil || len(r.buf) != 0 {
+		return nil, errors.New("invalid token payload")
+	}
+	return data, nil
+}
+
+// signPayload returns the HMAC-SHA256 of the encoded payload
+func signPayload(key []byte, encodedPayload string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(encodedPayload))
+	return h.Sum(nil)
+}
+
+// appendShortString appends a length-prefixed string of at most 255 bytes
+func appendShortString(b []byte, s string) []byte {
+	b = append(b, byte(len(s)))
+	return append(b, s...)
+}
+
+// byteReader reads the fields of a binary token payload, remembering the first error
+type byteReader struct {
+	buf []byte
+	err error
+}
+
+// bytes reads n raw bytes
+func (r *byteReader) bytes(n int) []byte {
+	if r.err != nil || len(r.buf) < n {
+		r.err = errors.New("truncated token payload")
+		return nil
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+// byte reads a single byte
+func (r *byteReader) byte() byte {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+// uint64 reads a big-endian uint64
+func (r *byteReader) uint64() uint64 {
+	if b := r.bytes(8); b != nil {
+		return binary.BigEndian.Uint64(b)
+	}
+	return 0
+}
+
+// shortString reads a length-prefixed string
+func (r *byteReader) shortString() string {
+	return string(r.bytes(int(r.byte())))
+}
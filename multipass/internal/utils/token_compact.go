@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	// TokenVersion3 is the version byte of compact tokens that carry a member reference instead of an email
+	TokenVersion3 = 3
+
+	// compactMACLength is the number of HMAC-SHA256 bytes kept in a compact token
+	// 80 bits cannot be brute-forced online, and every guess has to go through the rate limiter
+	compactMACLength = 10
+
+	// compactHeaderLength covers version, purpose, key tag, iat, exp and the token ID
+	compactHeaderLength = 1 + 1 + 1 + 4 + 4 + tokenIDLength
+)
+
+// IssueCompact creates a v3 token for the member with the numeric reference memberRef, valid for validFor
+// The token is base64url(payload | mac), where the payload is
+// version | purpose | key tag | iat (uint32) | exp (uint32) | id | uvarint(memberRef)
+// and mac is the truncated HMAC-SHA256 of the payload. It holds no email and is about 42 characters long.
+func (kr *Keyring) IssueCompact(memberRef uint64, purpose TokenPurpose, validFor time.Duration) (string, *TokenData, error) {
+	if memberRef == 0 {
+		return "", nil, errors.New("member reference is required")
+	}
+
+	id := make([]byte, tokenIDLength)
+	if _, err := rand.Read(id); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token ID: %w", err)
+	}
+
+	issuedAt := time.Now().UTC().Truncate(time.Second)
+	data := &TokenData{
+		UserID:    strconv.FormatUint(memberRef, 10),
+		Timestamp: issuedAt,
+		Version:   TokenVersion3,
+		KeyID:     kr.activeKID,
+		Purpose:   purpose,
+		ExpiresAt: issuedAt.Add(validFor),
+		ID:        hex.EncodeToString(id),
+	}
+
+	// Build the binary payload
+	payload := []byte{TokenVersion3, byte(purpose), keyTag(kr.activeKID)}
+	payload = binary.BigEndian.AppendUint32(payload, uint32(data.Timestamp.Unix()))
+	payload = binary.BigEndian.AppendUint32(payload, uint32(data.ExpiresAt.Unix()))
+	payload = append(payload, id...)
+	payload = binary.AppendUvarint(payload, memberRef)
+
+	token := append(payload, compactMAC(kr.keys[kr.activeKID], payload)...)
+	return base64.RawURLEncoding.EncodeToString(token), data, nil
+}
+
+// verifyCompact checks a v3 token against the keys whose tag matches
+func (kr *Keyring) verifyCompact(token string) (*TokenData, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode token: %w", err)
+	}
+	if len(raw) <= compactHeaderLength+compactMACLength || raw[0] != TokenVersion3 {
+		return nil, errors.New("invalid token format")
+	}
+
+	payload, mac := raw[:len(raw)-compactMACLength], raw[len(raw)-compactMACLength:]
+
+	// The one-byte tag narrows down the key; a collision only costs an extra HMAC
+	keyID := ""
+	for kid, key := range kr.keys {
+		if keyTag(kid) == payload[2] && hmac.Equal(mac, compactMAC(key, payload)) {
+			keyID = kid
+			break
+		}
+	}
+	if keyID == "" {
+		return nil, ErrTokenSignature
+	}
+
+	memberRef, n := binary.Uvarint(payload[compactHeaderLength:])
+	if n <= 0 || compactHeaderLength+n != len(payload) || memberRef == 0 {
+		return nil, errors.New("invalid token payload")
+	}
+
+	data := &TokenData{
+		UserID:    strconv.FormatUint(memberRef, 10),
+		Timestamp: time.Unix(int64(binary.BigEndian.Uint32(payload[3:7])), 0).UTC(),
+		ExpiresAt: time.Unix(int64(binary.BigEndian.Uint32(payload[7:11])), 0).UTC(),
+		Version:   TokenVersion3,
+		KeyID:     keyID,
+		Purpose:   TokenPurpose(payload[1]),
+		ID:        hex.EncodeToString(payload[11:compactHeaderLength]),
+	}
+
+	// Check if token is expired
+	if time.Now().After(data.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+
+	return data, nil
+}
+
+// keyTag returns the one-byte hint identifying a key in compact tokens
+func keyTag(kid string) byte {
+	sum := sha256.Sum256([]byte(kid))
+	return sum[0]
+}
+
+// compactMAC returns the truncated HMAC-SHA256 of a compact token payload
+func compactMAC(key, payload []byte) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write(payload)
+	return h.Sum(nil)[:compactMACLength]
+}
@@ -1,6 +1,11 @@
 package utils
 
 import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 )
@@ -81,3 +86,209 @@ func TestTokenExpiration(t *testing.T) {
 		t.Errorf("Expected token validity duration to be 30 days, got %v", TokenValidityDuration)
 	}
 }
+
+// generateV1Token builds a legacy token the way v1 GenerateToken did
+func generateV1Token(userID, email, secret string, issuedAt time.Time) string {
+	payload := fmt.Sprintf("%s:%s:%s", userID, email, issuedAt.UTC().Format(time.RFC3339))
+	encodedPayload := base64.URLEncoding.EncodeToString([]byte(payload))
+	return encodedPayload + ":" + hex.EncodeToString(signPayload([]byte(secret), encodedPayload))
+}
+
+func TestKeyringIssueAndVerify(t *testing.T) {
+	kr, err := NewKeyring("2025a", map[string]string{"2025a": "secret-a"})
+	if err != nil {
+		t.Fatalf("Failed to create keyring: %v", err)
+	}
+
+	token, issued, err := kr.Issue("42", "test@example.com", PurposeShare, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to issue token: %v", err)
+	}
+	if strings.Contains(token, ":") {
+		t.Errorf("v2 token must not look like a v1 token: %s", token)
+	}
+
+	data, err := kr.Verify(token)
+	if err != nil {
+		t.Fatalf("Failed to verify token: %v", err)
+	}
+
+	// Every claim survives the round trip
+	if data.Version != TokenVersion2 || data.KeyID != "2025a" || data.Purpose != PurposeShare {
+		t.Errorf("Unexpected header fields: %+v", data)
+	}
+	if data.UserID != "42" || data.Email != "test@example.com" || data.ID != issued.ID {
+		t.Errorf("Unexpected user fields: %+v", data)
+	}
+	if !data.ExpiresAt.Equal(issued.ExpiresAt) || data.ExpiresAt.Sub(data.Timestamp) != time.Hour {
+		t.Errorf("Unexpected expiry: %v", data.ExpiresAt)
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldRing, _ := NewKeyring("2025a", map[string]string{"2025a": "secret-a"})
+	oldToken, _, _ := oldRing.Issue("42", "test@example.com", PurposeCard, time.Hour)
+
+	// The new key signs, the old one still verifies
+	rotated, _ := NewKeyring("2025b", map[string]string{"2025a": "secret-a", "2025b": "secret-b"})
+	if _, err := rotated.Verify(oldToken); err != nil {
+		t.Errorf("Expected token signed with the old key to verify: %v", err)
+	}
+
+	newToken, data, _ := rotated.Issue("42", "test@example.com", PurposeCard, time.Hour)
+	if data.KeyID != "2025b" {
+		t.Errorf("Expected new tokens to be signed with 2025b, got %s", data.KeyID)
+	}
+
+	// Once the old key is retired its tokens stop working
+	retired, _ := NewKeyring("2025b", map[string]string{"2025b": "secret-b"})
+	if _, err := retired.Verify(oldToken); err == nil {
+		t.Error("Expected token signed with a retired key to be rejected")
+	}
+	if _, err := retired.Verify(newToken); err != nil {
+		t.Errorf("Expected token signed with the active key to verify: %v", err)
+	}
+}
+
+func TestKeyringRejectsInvalidTokens(t *testing.T) {
+	kr, _ := NewKeyring("k1", map[string]string{"k1": "secret"})
+	token, _, _ := kr.Issue("42", "test@example.com", PurposeCard, time.Hour)
+	expired, _, _ := kr.Issue("42", "test@example.com", PurposeCard, -time.Minute)
+
+	// Flip a byte of the payload
+	parts := strings.Split(token, ".")
+	payload, _ := base64.RawURLEncoding.DecodeString(parts[0])
+	payload[len(payload)-1] ^= 0x01
+	tampered := base64.RawURLEncoding.EncodeToString(payload) + "." + parts[1]
+
+	// Test cases
+	testCases := []struct {
+		name  string
+		token string
+	}{
+		{name: "Tampered payload", token: tampered},
+		{name: "Expired token", token: expired},
+		{name: "Truncated payload", token: "AgE." + parts[1]},
+		{name: "Missing signature", token: parts[0]},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := kr.Verify(tc.token); err == nil {
+				t.Error("Expected verification to fail, but it succeeded")
+			}
+		})
+	}
+}
+
+func TestKeyringLegacyTokens(t *testing.T) {
+	legacy := generateV1Token("42", "test@example.com", "old-secret", time.Now().Add(-time.Hour))
+
+	kr, _ := NewKeyring("default", map[string]string{"default": "old-secret"})
+	data, err := kr.Verify(legacy)
+	if err != nil {
+		t.Fatalf("Expected v1 token to verify during the migration window: %v", err)
+	}
+	if data.Version != 1 || data.Purpose != PurposeCard || data.UserID != "42" {
+		t.Errorf("Unexpected v1 token data: %+v", data)
+	}
+
+	// After the window closes v1 tokens are refused
+	kr.LegacyUntil = time.Now().Add(-time.Minute)
+	if _, err := kr.Verify(legacy); err == nil {
+		t.Error("Expected v1 token to be rejected after the migration window")
+	}
+
+	// Old v1 tokens still expire after 30 days
+	stale := generateV1Token("42", "test@example.com", "old-secret", time.Now().Add(-31*24*time.Hour))
+	kr.LegacyUntil = time.Time{}
+	if _, err := kr.Verify(stale); err == nil {
+		t.Error("Expected expired v1 token to be rejected")
+	}
+}
+
+func TestNewKeyringRequiresActiveKey(t *testing.T) {
+	if _, err := NewKeyring("missing", map[string]string{"k1": "secret"}); err == nil {
+		t.Error("Expected keyring without the active key to be rejected")
+	}
+}
+
+func TestKeyringCompactTokens(t *testing.T) {
+	kr, _ := NewKeyring("2025a", map[string]string{"2025a": "secret-a"})
+
+	token, issued, err := kr.IssueCompact(1234, PurposeCard, TokenValidityDuration)
+	if err != nil {
+		t.Fatalf("Failed to issue compact token: %v", err)
+	}
+
+	// Compact tokens are short, path-safe and carry no email
+	if len(token) > 48 {
+		t.Errorf("Expected a compact token of at most 48 characters, got %d: %s", len(token), token)
+	}
+	if strings.ContainsAny(token, ".:/+=") {
+		t.Errorf("Compact token must be unpadded base64url: %s", token)
+	}
+
+	data, err := kr.Verify(token)
+	if err != nil {
+		t.Fatalf("Failed to verify compact token: %v", err)
+	}
+	if data.Version != TokenVersion3 || data.KeyID != "2025a" || data.Purpose != PurposeCard {
+		t.Errorf("Unexpected header fields: %+v", data)
+	}
+	if data.UserID != "1234" || data.Email != "" || data.ID != issued.ID {
+		t.Errorf("Unexpected member fields: %+v", data)
+	}
+	if !data.Timestamp.Equal(issued.Timestamp) || !data.ExpiresAt.Equal(issued.ExpiresAt) {
+		t.Errorf("Unexpected times: %+v", data)
+	}
+
+	// Rotated keys keep verifying compact tokens
+	rotated, _ := NewKeyring("2025b", map[string]string{"2025a": "secret-a", "2025b": "secret-b"})
+	if _, err := rotated.Verify(token); err != nil {
+		t.Errorf("Expected compact token signed with the old key to verify: %v", err)
+	}
+}
+
+func TestKeyringRejectsInvalidCompactTokens(t *testing.T) {
+	kr, _ := NewKeyring("k1", map[string]string{"k1": "secret"})
+	token, _, _ := kr.IssueCompact(42, PurposeCard, time.Hour)
+	expired, _, _ := kr.IssueCompact(42, PurposeCard, -time.Minute)
+	other, _ := NewKeyring("k1", map[string]string{"k1": "other-secret"})
+	forged, _, _ := other.IssueCompact(42, PurposeCard, time.Hour)
+
+	// Turn the card token into a share token without re-signing it
+	raw, _ := base64.RawURLEncoding.DecodeString(token)
+	raw[1] = byte(PurposeShare)
+	tampered := base64.RawURLEncoding.EncodeToString(raw)
+
+	// Test cases
+	testCases := []struct {
+		name  string
+		token string
+		err   error
+	}{
+		{name: "Tampered purpose", token: tampered, err: ErrTokenSignature},
+		{name: "Wrong secret", token: forged, err: ErrTokenSignature},
+		{name: "Expired token", token: expired, err: ErrTokenExpired},
+		{name: "Truncated token", token: token[:20], err: nil},
+	}
+
+	// Run tests
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := kr.Verify(tc.token)
+			if err == nil {
+				t.Fatal("Expected verification to fail, but it succeeded")
+			}
+			if tc.err != nil && !errors.Is(err, tc.err) {
+				t.Errorf("Expected %v, got %v", tc.err, err)
+			}
+		})
+	}
+
+	if _, _, err := kr.IssueCompact(0, PurposeCard, time.Hour); err == nil {
+		t.Error("Expected a zero member reference to be rejected")
+	}
+}
@@ -174,8 +174,144 @@ function simpleHash(str) {
     return Math.abs(hash);
 }
 
+// CSRF token rendered into the page by the server
+function csrfToken() {
+    const meta = document.querySelector('meta[name="csrf-token"]');
+    return meta ? meta.getAttribute('content') : '';
+}
+
+// Send the CSRF token with every state-changing request
+function csrfFetch(url, options = {}) {
+    const headers = new Headers(options.headers || {});
+    headers.set('X-CSRF-Token', csrfToken());
+    return fetch(url, { ...options, headers, credentials: 'same-origin' });
+}
+
+// Attach the CSRF token to htmx requests
+document.addEventListener('htmx:configRequest', function(event) {
+    event.detail.headers['X-CSRF-Token'] = csrfToken();
+});
+
+// Device-bound live cards keep a non-exportable signing key in IndexedDB, one per member
+const DEVICE_DB = 'multipass-devices';
+
+function deviceStore(mode, operation) {
+    return new Promise((resolve, reject) => {
+        const open = indexedDB.open(DEVICE_DB, 1);
+        open.onupgradeneeded = () => open.result.createObjectStore('keys');
+        open.onerror = () => reject(open.error);
+        open.onsuccess = () => {
+            const request = operation(open.result.transaction('keys', mode).objectStore('keys'));
+            request.onsuccess = () => resolve(request.result);
+            request.onerror = () => reject(request.error);
+        };
+    });
+}
+
+// A short label for the device list, e.g. "Browser on Android"
+function deviceName() {
+    const platform = (navigator.userAgentData && navigator.userAgentData.platform) || navigator.platform || 'unknown platform';
+    return 'Browser on ' + platform;
+}
+
+// Create a key pair whose private half cannot leave this browser and register the public half
+// Registration needs the member's session, so a forwarded card link cannot register itself
+async function registerDevice(owner) {
+    const keyPair = await crypto.subtle.generateKey({ name: 'ECDSA', namedCurve: 'P-256' }, false, ['sign']);
+    const jwk = await crypto.subtle.exportKey('jwk', keyPair.publicKey);
+    const response = await csrfFetch('/devices', {
+        method: 'POST',
+        headers: { 'Content-Type': 'application/json' },
+        body: JSON.stringify({ name: deviceName(), public_key: { kty: jwk.kty, crv: jwk.crv, x: jwk.x, y: jwk.y } }),
+    });
+    const type = response.headers.get('Content-Type') || '';
+    if (!response.ok || !type.includes('application/json')) {
+        throw new Error('Sign in on this device to show your live card');
+    }
+
+    const data = await response.json();
+    const device = { id: data.device.id, privateKey: keyPair.privateKey };
+    await deviceStore('readwrite', store => store.put(device, owner));
+    return device;
+}
+
+// Sign a challenge; WebCrypto returns the raw r || s form the server expects
+async function signChallenge(privateKey, challenge) {
+    const signature = await crypto.subtle.sign({ name: 'ECDSA', hash: 'SHA-256' }, privateKey, new TextEncoder().encode(challenge));
+    return btoa(String.fromCharCode(...new Uint8Array(signature))).replace(/\+/g, '-').replace(/\//g, '_').replace(/=+$/, '');
+}
+
+// Replace the card's QR code with a fresh live code every interval
+// With device binding every request carries the last challenge, signed by this browser's key
+function startLiveCode() {
+    const settings = document.getElementById('live-code');
+    if (!settings) return;
+
+    const url = settings.dataset.url;
+    const interval = (parseInt(settings.dataset.interval, 10) || 30) * 1000;
+    const owner = settings.dataset.deviceOwner;
+    let challenge = settings.dataset.deviceChallenge;
+    let device = null;
+
+    function showStatus(text, stale) {
+        document.querySelectorAll('.qr-code-container img.qr-code').forEach(img => {
+            img.classList.toggle('opacity-25', stale);
+        });
+        document.querySelectorAll('.live-code-status').forEach(status => {
+            status.textContent = text;
+        });
+    }
+
+    async function deviceHeaders() {
+        if (!owner) return {};
+        if (!device) {
+            device = (await deviceStore('readonly', store => store.get(owner))) || (await registerDevice(owner));
+        }
+        return {
+            'X-Device-ID': device.id,
+            'X-Device-Challenge': challenge,
+            'X-Device-Signature': await signChallenge(device.privateKey, challenge),
+        };
+    }
+
+    async function refresh() {
+        try {
+            const response = await fetch(url, { credentials: 'same-origin', headers: await deviceHeaders() });
+            const data = await response.json();
+            if (data.challenge) challenge = data.challenge;
+
+            if (response.status === 403 && data.reason) {
+                // A removed device registers again on the next attempt
+                if (data.reason === 'device_unknown') {
+                    await deviceStore('readwrite', store => store.delete(owner));
+                    device = null;
+                }
+                throw new Error(data.error);
+            }
+            if (!response.ok) throw new Error('HTTP ' + response.status);
+
+            document.querySelectorAll('.qr-code-container img.qr-code').forEach(img => {
+                img.src = data.qr_code;
+            });
+            showStatus('Live code \u00b7 refreshes every ' + interval / 1000 + 's', false);
+            setTimeout(refresh, (data.refresh_in || interval / 1000) * 1000);
+        } catch (err) {
+            // A stale code would be rejected at the desk, so make that visible
+            console.log('Error refreshing live code:', err);
+            showStatus(owner && !device ? err.message : 'Live code unavailable - retrying', true);
+            setTimeout(refresh, interval);
+        }
+    }
+
+    // A device-bound card has no code until this browser proves it is registered
+    setTimeout(refresh, owner ? 0 : interval);
+}
+
 // Initialize card interactions when DOM is loaded
 document.addEventListener('DOMContentLoaded', function() {
+    // Keep the live code fresh on the member's own card
+    startLiveCode();
+
     // Generate QR patterns only for elements that don't already have a real QR code
     const qrElements = document.querySelectorAll('[data-qr]');
     qrElements.forEach(element => {